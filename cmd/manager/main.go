@@ -20,24 +20,34 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
 	dispatcherv1alpha1 "github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
 	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
 	"github.com/ivanvc/dispatcher/pkg/controllers"
 	"github.com/ivanvc/dispatcher/pkg/http"
+	"github.com/ivanvc/dispatcher/pkg/migration"
+	"github.com/ivanvc/dispatcher/pkg/notification"
+	"github.com/ivanvc/dispatcher/pkg/webhooks"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -48,9 +58,11 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(dispatcherv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(dispatcherv1beta1.AddToScheme(scheme))
+	utilruntime.Must(dispatcherv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -60,10 +72,17 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var enableWebhooks bool
+	var webhookCertDir string
 
 	var webServerAddr string
 	var defaultNamespace string
 	var logJobExecutionPayloads bool
+	var maxPayloadSize int64
+	var idempotencyKeyWindow time.Duration
+	var rateLimits http.RateLimitConfig
+	var callbackHosts []string
+	var smtpConfig notification.SMTPConfig
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
 		"The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081",
@@ -75,6 +94,12 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the admission and conversion webhooks are served, and the stored resources are migrated "+
+			"to their storage version. They require the webhook server's certificates.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "",
+		"The directory with the webhook server's certificate (tls.crt) and key (tls.key). "+
+			"Defaults to <temp-dir>/k8s-webhook-server/serving-certs.")
 
 	flag.StringVar(&webServerAddr, "web-server-bind-address", ":8000",
 		"The address the web server endpoint binds to.")
@@ -82,6 +107,40 @@ func main() {
 		"The default namespace to use when no namespace is specified when invoking a job via HTTP.")
 	flag.BoolVar(&logJobExecutionPayloads, "log-job-execution-payloads", false,
 		"Enable logging job execution payloads.")
+	flag.Int64Var(&maxPayloadSize, "max-payload-size", 1<<20,
+		"The maximum size, in bytes, of the payload of the requests to execute jobs via HTTP. "+
+			"It has to fit in a job execution stored in etcd.")
+	flag.DurationVar(&idempotencyKeyWindow, "idempotency-key-window", 24*time.Hour,
+		"The time during which a request with the Idempotency-Key of a previous request returns its job execution, "+
+			"instead of creating a new one. Set to 0 to always create a new job execution.")
+	flag.Float64Var(&rateLimits.Global.Rate, "rate-limit", 0,
+		"The maximum rate, in requests per second, of all the requests to execute jobs via HTTP, in each replica. Set to 0 to disable it.")
+	flag.IntVar(&rateLimits.Global.Burst, "rate-limit-burst", 1,
+		"The maximum burst of all the requests to execute jobs via HTTP.")
+	flag.Float64Var(&rateLimits.JobTemplate.Rate, "job-template-rate-limit", 0,
+		"The maximum rate, in requests per second, of the requests to execute each job template via HTTP. Set to 0 to disable it.")
+	flag.IntVar(&rateLimits.JobTemplate.Burst, "job-template-rate-limit-burst", 1,
+		"The maximum burst of the requests to execute each job template via HTTP.")
+	flag.Float64Var(&rateLimits.Caller.Rate, "caller-rate-limit", 0,
+		"The maximum rate, in requests per second, of the requests to execute jobs via HTTP from each caller. Set to 0 to disable it.")
+	flag.IntVar(&rateLimits.Caller.Burst, "caller-rate-limit-burst", 1,
+		"The maximum burst of the requests to execute jobs via HTTP from each caller.")
+	flag.StringVar(&rateLimits.CallerHeader, "caller-header", "",
+		"The header with the authenticated caller of the requests, e.g. set by an authenticating proxy. "+
+			"Defaults to identifying the caller by its IP address.")
+	flag.Func("callback-hosts",
+		"Comma-separated hosts that the callbacks requested via HTTP can POST to, where *.example.com allows its subdomains. "+
+			"The callbacks requested via HTTP are rejected when it isn't set.",
+		func(hosts string) error {
+			callbackHosts = append(callbackHosts, strings.Split(hosts, ",")...)
+			return nil
+		})
+	flag.StringVar(&smtpConfig.Address, "smtp-address", "",
+		"The address (host:port) of the SMTP server used by email notifications.")
+	flag.StringVar(&smtpConfig.From, "smtp-from", "",
+		"The sender address of email notifications.")
+	flag.StringVar(&smtpConfig.Username, "smtp-username", "",
+		"The username to authenticate with the SMTP server. The password is read from the SMTP_PASSWORD environment variable.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,6 +148,7 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	smtpConfig.Password = os.Getenv("SMTP_PASSWORD")
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -106,12 +166,28 @@ func main() {
 		tlsOpts = append(tlsOpts, disableHTTP2)
 	}
 
+	// The webhook server watches the certificate, and reloads it when it's
+	// renewed.
 	webhookServer := webhook.NewServer(webhook.Options{
+		CertDir: webhookCertDir,
 		TLSOpts: tlsOpts,
 	})
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		// Read the callbacks' Secrets, the Jobs' Pods, the results' ConfigMaps
+		// and the snapshots' ControllerRevisions directly, instead of caching
+		// every one in the cluster.
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{
+					&corev1.Secret{},
+					&corev1.Pod{},
+					&corev1.ConfigMap{},
+					&appsv1.ControllerRevision{},
+				},
+			},
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -139,13 +215,63 @@ func main() {
 	}
 
 	if err = (&controllers.JobExecutionReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("jobexecution-controller"),
+		SMTP:      &smtpConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "JobExecution")
+		os.Exit(1)
+	}
+	if err = (&controllers.JobTemplateReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "JobTemplate")
+		os.Exit(1)
+	}
+	if err = (&controllers.WorkflowExecutionReconciler{
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("jobexecution-controller"),
+		Recorder: mgr.GetEventRecorderFor("workflowexecution-controller"),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "Unable to create controller", "controller", "JobExecution")
+		setupLog.Error(err, "Unable to create controller", "controller", "WorkflowExecution")
 		os.Exit(1)
 	}
+	if err = (&controllers.ScheduledExecutionReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("scheduledexecution-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "ScheduledExecution")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if err = webhooks.SetupConversionWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create webhook", "webhook", "conversion")
+			os.Exit(1)
+		}
+		if err = webhooks.SetupJobTemplateWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create webhook", "webhook", "JobTemplate")
+			os.Exit(1)
+		}
+		if err = webhooks.SetupJobExecutionWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create webhook", "webhook", "JobExecution")
+			os.Exit(1)
+		}
+		if err = webhooks.SetupWorkflowWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create webhook", "webhook", "Workflow")
+			os.Exit(1)
+		}
+		if err = mgr.Add(&migration.StorageVersionMigrator{
+			Client: mgr.GetClient(),
+			Reader: mgr.GetAPIReader(),
+		}); err != nil {
+			setupLog.Error(err, "Unable to create storage version migrator")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -157,7 +283,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := mgr.Add(http.NewServer(webServerAddr, defaultNamespace, logJobExecutionPayloads, mgr.GetClient())); err != nil {
+	if err := mgr.Add(http.NewServer(webServerAddr, defaultNamespace, logJobExecutionPayloads, maxPayloadSize, idempotencyKeyWindow, rateLimits, callbackHosts, mgr.GetClient(), mgr.GetAPIReader())); err != nil {
 		setupLog.Error(err, "Error running Web Server")
 		os.Exit(1)
 	}
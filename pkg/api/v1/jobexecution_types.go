@@ -0,0 +1,618 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// JobExecutionSpec defines the desired state of JobExecution
+type JobExecutionSpec struct {
+	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+
+	//+kubebuilder:validation:Required
+	// The JobTemplate to execute.
+	JobTemplateName string `json:"jobTemplateName"`
+
+	//+optional
+	//+kubebuilder:validation:Enum=JobTemplate;ClusterJobTemplate
+	// The kind of the JobTemplate to execute: a JobTemplate from the
+	// JobExecution's namespace, or a ClusterJobTemplate. Defaults to
+	// JobTemplate.
+	JobTemplateKind string `json:"jobTemplateKind,omitempty"`
+
+	//+optional
+	// The version of the JobTemplate when the JobExecution was created, which
+	// its snapshot is built from. It's set when the JobExecution is created.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="jobTemplateVersion is immutable"
+	JobTemplateVersion *JobTemplateVersion `json:"jobTemplateVersion,omitempty"`
+
+	//+optional
+	// The execution arguments to pass to the JobTemplate's Job.
+	Payload string `json:"payload,omitempty"`
+
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	// The values of the JobTemplate's parameters. The parameters that aren't
+	// set use their default.
+	Parameters []ExecutionParameter `json:"parameters,omitempty"`
+
+	//+optional
+	// Overrides the Job built from the JobTemplate for this execution.
+	Overrides *ExecutionOverrides `json:"overrides,omitempty"`
+
+	//+optional
+	// The user that requested the execution. It's set when the JobExecution
+	// is created, and can't be set by the requester.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="requester is immutable"
+	Requester *ExecutionRequester `json:"requester,omitempty"`
+
+	//+optional
+	// Overrides the JobTemplate's RetryPolicy for this execution.
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	//+optional
+	// Cancels the execution. Its Job is suspended, and deleted once the grace
+	// period elapses.
+	Cancel bool `json:"cancel,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	// The seconds to wait for the Job to stop after it is cancelled or times
+	// out, before deleting it. Defaults to 30.
+	CancelGracePeriodSeconds *int64 `json:"cancelGracePeriodSeconds,omitempty"`
+
+	//+optional
+	// Overrides the JobTemplate's Timeout for this execution.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	//+optional
+	// Notifies a URL when the execution finishes.
+	Callback *Callback `json:"callback,omitempty"`
+
+	//+optional
+	// The finished execution that triggered this one, from its JobTemplate's
+	// OnSuccess or OnFailure hooks.
+	Trigger *ExecutionTrigger `json:"trigger,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:MaxItems=10000
+	// Fans out the execution, running a child JobExecution for every item, with
+	// the item as its payload. The JobExecution doesn't create a Job, and
+	// finishes once all its items finish.
+	Items []string `json:"items,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	// The maximum number of items running at the same time. Defaults to 10.
+	Parallelism *int32 `json:"parallelism,omitempty"`
+
+	//+optional
+	// Delays the execution, its Job isn't created before this time. The
+	// Timeout counts from it.
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+}
+
+// ExecutionParameter describes the value of a JobTemplate's parameter, set
+// either literally or from a ConfigMap or Secret.
+// +kubebuilder:validation:XValidation:rule="!(has(self.value) && has(self.valueFrom))",message="value and valueFrom are mutually exclusive"
+type ExecutionParameter struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	// The name of the parameter.
+	Name string `json:"name"`
+
+	//+optional
+	// The value of the parameter.
+	Value string `json:"value,omitempty"`
+
+	//+optional
+	// Reads the value of the parameter when the Job is created.
+	ValueFrom *ExecutionParameterSource `json:"valueFrom,omitempty"`
+}
+
+// ExecutionParameterSource describes where to read the value of a parameter
+// from. Exactly one of the sources must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.configMapKeyRef) != has(self.secretKeyRef)",message="exactly one of configMapKeyRef or secretKeyRef must be set"
+type ExecutionParameterSource struct {
+	//+optional
+	// A key from a ConfigMap in the JobExecution's namespace.
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	//+optional
+	// A key from a Secret in the JobExecution's namespace.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ExecutionOverrides describes the changes to the Job built from the
+// JobTemplate for a single execution. The JobTemplate declares the fields that
+// can be overridden.
+// +kubebuilder:validation:XValidation:rule="!(has(self.image) && has(self.imageTag))",message="image and imageTag are mutually exclusive"
+type ExecutionOverrides struct {
+	//+optional
+	// The container to override. Defaults to the pod's first container.
+	ContainerName string `json:"containerName,omitempty"`
+
+	//+optional
+	// Replaces the container's image.
+	Image string `json:"image,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Pattern=`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`
+	// Replaces the tag of the container's image, keeping its repository.
+	ImageTag string `json:"imageTag,omitempty"`
+
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	// Sets environment variables in the container, replacing the ones with
+	// the same name.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	//+optional
+	// Replaces the container's resources.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	//+optional
+	//+listType=atomic
+	// Replaces the container's arguments.
+	Args []string `json:"args,omitempty"`
+
+	//+optional
+	// Sets labels of the pod's node selector, replacing the ones with the same
+	// key.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// JobTemplateVersion describes a version of a JobTemplate.
+type JobTemplateVersion struct {
+	// The ResourceVersion of the JobTemplate.
+	ResourceVersion string `json:"resourceVersion"`
+
+	//+optional
+	// The Generation of the JobTemplate.
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// ExecutionRequester describes the user that requested an execution.
+type ExecutionRequester struct {
+	// The name of the user.
+	Username string `json:"username"`
+
+	//+optional
+	// The UID of the user.
+	UID string `json:"uid,omitempty"`
+
+	//+optional
+	// The groups of the user.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// RetryPolicy describes how to retry a JobExecution when its Job fails. Every
+// attempt creates a new Job.
+type RetryPolicy struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Minimum=1
+	// The maximum number of Jobs to create for a JobExecution, including the
+	// first one.
+	MaxAttempts int32 `json:"maxAttempts"`
+
+	//+optional
+	// The time to wait before creating the Job for the second attempt. It
+	// doubles with every subsequent attempt. Defaults to 10s.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	//+optional
+	// The maximum time to wait between attempts. Defaults to 10m.
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+
+	//+optional
+	// The reasons from the Job's Failed condition that are retried, e.g.
+	// "DeadlineExceeded" or "BackoffLimitExceeded". All failures are retried
+	// when it is empty.
+	RetryableReasons []string `json:"retryableReasons,omitempty"`
+}
+
+// ExecutionTrigger describes the execution that triggered a follow-up
+// execution.
+type ExecutionTrigger struct {
+	// The name of the triggering JobExecution.
+	JobExecutionName string `json:"jobExecutionName"`
+
+	// The JobTemplate of the triggering JobExecution.
+	JobTemplateName string `json:"jobTemplateName"`
+
+	// The outcome of the triggering JobExecution: "Succeeded", "Failed" or
+	// "TimedOut".
+	Outcome string `json:"outcome"`
+
+	//+optional
+	// The result of the triggering JobExecution.
+	Result string `json:"result,omitempty"`
+
+	//+optional
+	// The number of follow-ups in the chain that led to this execution, which
+	// is 1 for the follow-up of an execution that wasn't triggered.
+	Depth int32 `json:"depth,omitempty"`
+}
+
+// Callback describes the request sent when a JobExecution finishes. It is a
+// POST request with the final status, timings and result as a JSON body.
+type Callback struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^https?://`
+	// The URL to POST to.
+	URL string `json:"url"`
+
+	//+optional
+	// A key from a Secret in the JobExecution's namespace, used to sign the
+	// request body with HMAC-SHA256. The signature is sent in the
+	// X-Dispatcher-Signature header.
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+}
+
+// JobExecutionStatus defines the observed state of JobExecution
+type JobExecutionStatus struct {
+	// Represents the observations of a JobExecution's state. The state of the JobExecution is tied to the Job it manages.
+	// Conditions.type are: "Waiting", "Scheduled", "Running", "Succeeded", "Cancelled", "TimedOut".
+	// Conditions.status are one of True, False, Unknown.
+	// Conditions.reason defines a camelCase expected values and meanings for this field.
+	// Conditions.Message is a human readable message indicating details about the transition.
+
+	// Phase summarizes the conditions of the JobExecution. It's one of
+	// "Pending", "Running", or once it finishes, "Succeeded", "Failed",
+	// "Cancelled" or "TimedOut".
+	// +optional
+	Phase JobExecutionPhase `json:"phase,omitempty"`
+
+	// Conditions store the status conditions of a JobExecution.
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Job has a reference to the Job from this execution.
+	// +optional
+	Job corev1.ObjectReference `json:"job,omitempty"`
+
+	// PreviousAttempts records the failed Jobs that were retried, in the order
+	// they were created.
+	// +optional
+	PreviousAttempts []JobExecutionAttempt `json:"previousAttempts,omitempty"`
+
+	// StartTime is the time when the Job of the first attempt started running.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time when the JobExecution finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Attempts is the number of Jobs created for the JobExecution.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// Pods has the status of the most recent pods from the current Job.
+	// +optional
+	Pods []JobExecutionPod `json:"pods,omitempty"`
+
+	// TerminationMessage is the termination message of the last container that
+	// failed in the current Job.
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
+	// Result is the result published by the Job when it completed, as
+	// configured by the JobTemplate.
+	// +optional
+	Result string `json:"result,omitempty"`
+
+	// ResultTruncated is true when the Result was larger than the maximum size,
+	// and was truncated.
+	// +optional
+	ResultTruncated bool `json:"resultTruncated,omitempty"`
+
+	// ResultConfigMap references the ConfigMap that stores the result, when it
+	// was larger than the maximum size.
+	// +optional
+	ResultConfigMap *corev1.LocalObjectReference `json:"resultConfigMap,omitempty"`
+
+	// Callback has the delivery status of the Callback.
+	// +optional
+	Callback *CallbackStatus `json:"callback,omitempty"`
+
+	// NotifiedEvents are the lifecycle events already sent to the JobTemplate's
+	// notification sinks.
+	// +optional
+	NotifiedEvents []NotificationEvent `json:"notifiedEvents,omitempty"`
+
+	// FollowUps are the names of the JobExecutions triggered by the
+	// JobTemplate's hooks once this one finished.
+	// +optional
+	FollowUps []string `json:"followUps,omitempty"`
+
+	// Items has the progress of the items of a fanned out JobExecution.
+	// +optional
+	Items *JobExecutionItemsStatus `json:"items,omitempty"`
+
+	// JobTemplate describes the snapshot of the JobTemplate taken before
+	// creating the first Job. The JobExecution's Jobs are built from it, even
+	// if the JobTemplate changes.
+	// +optional
+	JobTemplate *JobTemplateSnapshot `json:"jobTemplate,omitempty"`
+}
+
+// JobTemplateSnapshot describes the JobTemplate that a JobExecution is built
+// from.
+type JobTemplateSnapshot struct {
+	// The name of the JobTemplate.
+	Name string `json:"name"`
+
+	// The ResourceVersion of the JobTemplate when the snapshot was taken.
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// The Generation of the JobTemplate when the snapshot was taken.
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// The ControllerRevision that stores the effective JobTemplate, after
+	// inheriting from its bases.
+	Revision string `json:"revision"`
+
+	// The SHA-256 hash of the effective JobTemplate, as stored in the
+	// Revision.
+	Hash string `json:"hash"`
+}
+
+// JobExecutionItemsStatus describes the progress of the child JobExecutions of
+// a fanned out JobExecution.
+type JobExecutionItemsStatus struct {
+	// Total is the number of items.
+	Total int32 `json:"total"`
+
+	// Active is the number of items running.
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// Succeeded is the number of items that succeeded.
+	// +optional
+	Succeeded int32 `json:"succeeded,omitempty"`
+
+	// Failed is the number of items that failed, were cancelled or timed out.
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+
+	// SucceededIndexes has the indexes of the items that succeeded, as a list
+	// of intervals, e.g. "1,3-5,7".
+	// +optional
+	SucceededIndexes string `json:"succeededIndexes,omitempty"`
+
+	// FailedIndexes has the indexes of the items that failed, in the same
+	// format as SucceededIndexes.
+	// +optional
+	FailedIndexes string `json:"failedIndexes,omitempty"`
+}
+
+// CallbackStatus describes the delivery of a JobExecution's Callback.
+type CallbackStatus struct {
+	// Attempts is the number of times the delivery was attempted.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// DeliveredAt is the time when the Callback was delivered.
+	// +optional
+	DeliveredAt *metav1.Time `json:"deliveredAt,omitempty"`
+
+	// LastError is the error from the last failed attempt.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// JobExecutionPod describes a pod from a JobExecution's Job.
+type JobExecutionPod struct {
+	// Name of the pod.
+	Name string `json:"name"`
+
+	// Phase of the pod.
+	// +optional
+	Phase corev1.PodPhase `json:"phase,omitempty"`
+
+	// Containers has the status of the pod's terminated containers.
+	// +optional
+	Containers []JobExecutionContainer `json:"containers,omitempty"`
+}
+
+// JobExecutionContainer describes a terminated container from a JobExecution's
+// pod.
+type JobExecutionContainer struct {
+	// Name of the container.
+	Name string `json:"name"`
+
+	// ExitCode of the container.
+	ExitCode int32 `json:"exitCode"`
+
+	// Reason why the container terminated.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// JobExecutionAttempt describes a failed Job from a retried JobExecution.
+type JobExecutionAttempt struct {
+	// Job has a reference to the Job that ran the attempt.
+	Job corev1.ObjectReference `json:"job"`
+
+	// Reason of the Job's Failed condition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message of the Job's Failed condition.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// FinishedAt is the time when the Job failed.
+	FinishedAt metav1.Time `json:"finishedAt"`
+}
+
+// NotificationEvent is a lifecycle event of a JobExecution.
+// +kubebuilder:validation:Enum=Created;Started;Succeeded;Failed
+type NotificationEvent string
+
+const (
+	NotificationEventCreated   NotificationEvent = "Created"
+	NotificationEventStarted   NotificationEvent = "Started"
+	NotificationEventSucceeded NotificationEvent = "Succeeded"
+	NotificationEventFailed    NotificationEvent = "Failed"
+)
+
+// JobExecutionPhase is the phase of a JobExecution.
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Cancelled;TimedOut
+type JobExecutionPhase string
+
+const (
+	// The JobExecution's Job isn't running yet.
+	JobExecutionPhasePending JobExecutionPhase = "Pending"
+
+	// The JobExecution's Job, or its items, are running.
+	JobExecutionPhaseRunning JobExecutionPhase = "Running"
+
+	// The JobExecution's Job completed.
+	JobExecutionPhaseSucceeded JobExecutionPhase = "Succeeded"
+
+	// The JobExecution's Job failed.
+	JobExecutionPhaseFailed JobExecutionPhase = "Failed"
+
+	// The JobExecution was cancelled.
+	JobExecutionPhaseCancelled JobExecutionPhase = "Cancelled"
+
+	// The JobExecution exceeded its timeout.
+	JobExecutionPhaseTimedOut JobExecutionPhase = "TimedOut"
+)
+
+// GetJobExecutionPhase returns the phase of a JobExecution from its
+// conditions.
+func GetJobExecutionPhase(conditions []metav1.Condition) JobExecutionPhase {
+	succeeded := meta.FindStatusCondition(conditions, string(JobExecutionSucceeded))
+	switch {
+	case succeeded == nil || succeeded.Status == metav1.ConditionUnknown:
+		if meta.IsStatusConditionTrue(conditions, string(JobExecutionRunning)) {
+			return JobExecutionPhaseRunning
+		}
+		return JobExecutionPhasePending
+	case meta.IsStatusConditionTrue(conditions, string(JobExecutionCancelled)):
+		return JobExecutionPhaseCancelled
+	case meta.IsStatusConditionTrue(conditions, string(JobExecutionTimedOut)):
+		return JobExecutionPhaseTimedOut
+	case succeeded.Status == metav1.ConditionTrue:
+		return JobExecutionPhaseSucceeded
+	default:
+		return JobExecutionPhaseFailed
+	}
+}
+
+// JobExecutionConditionType describes the observed state of a JobExecution and its Job.
+type JobExecutionConditionType string
+
+const (
+	JobExecutionWaiting   JobExecutionConditionType = "Waiting"
+	JobExecutionScheduled JobExecutionConditionType = "Scheduled"
+	JobExecutionRunning   JobExecutionConditionType = "Running"
+	JobExecutionSucceeded JobExecutionConditionType = "Succeeded"
+	JobExecutionCancelled JobExecutionConditionType = "Cancelled"
+	JobExecutionTimedOut  JobExecutionConditionType = "TimedOut"
+)
+
+// The kinds of JobTemplates that a JobExecution can execute.
+const (
+	JobTemplateKind        = "JobTemplate"
+	ClusterJobTemplateKind = "ClusterJobTemplate"
+)
+
+// The key of the result in the data of the JobExecution's ResultConfigMap.
+const JobResultConfigMapKey = "result"
+
+// The standard labels of a JobExecution, with the name of its JobTemplate, who
+// requested it, and the hash of what it executes.
+const (
+	JobTemplateNameLabel       = "job-template-name"
+	JobExecutionRequesterLabel = "job-execution-requester"
+	JobExecutionHashLabel      = "job-execution-hash"
+)
+
+// The annotation with the user that requested the JobExecution. Unlike the
+// label, it isn't truncated.
+const JobExecutionRequesterAnnotation = "job-execution-requester"
+
+// The annotation that keeps the fields of a JobExecution that previous versions
+// don't have, when it's converted to them.
+const JobExecutionConversionAnnotation = "job-execution-conversion-data"
+
+// The label with the hash of the idempotency key of the HTTP request that
+// created the JobExecution.
+const JobExecutionIdempotencyKeyLabel = "job-execution-idempotency-key"
+
+// The label with the hash of the deduplication key of a JobExecution
+// dispatched through the HTTP API.
+const JobExecutionDeduplicationKeyLabel = "job-execution-deduplication-key"
+
+// The label with the name of the JobExecution that triggered a follow-up
+// execution. Names longer than 63 characters are shortened with their hash.
+const JobExecutionParentLabel = "job-execution-parent"
+
+// The labels with the name of the fanned out JobExecution, and the index of the
+// item run by a child JobExecution. Names longer than 63 characters are
+// shortened with their hash.
+const (
+	JobExecutionFanOutLabel    = "job-execution-fan-out"
+	JobExecutionItemIndexLabel = "job-execution-item-index"
+)
+
+//+kubebuilder:storageversion
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Template",type=string,JSONPath=`.spec.jobTemplateName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// JobExecution is the Schema for the jobexecutions API
+type JobExecution struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   JobExecutionSpec   `json:"spec,omitempty"`
+	Status JobExecutionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// JobExecutionList contains a list of JobExecution
+type JobExecutionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JobExecution `json:"items"`
+}
+
+// Set v1 as the Hub.
+func (*JobExecution) Hub() {}
+
+func init() {
+	SchemeBuilder.Register(&JobExecution{}, &JobExecutionList{})
+}
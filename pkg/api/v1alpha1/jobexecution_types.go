@@ -36,15 +36,110 @@ type JobExecutionSpec struct {
 	// The JobTemplate to execute.
 	JobTemplateName string `json:"jobTemplateName"`
 
+	//+optional
+	//+kubebuilder:validation:Enum=JobTemplate;ClusterJobTemplate
+	// The kind of the JobTemplate to execute: a JobTemplate from the
+	// JobExecution's namespace, or a ClusterJobTemplate. Defaults to
+	// JobTemplate.
+	JobTemplateKind string `json:"jobTemplateKind,omitempty"`
+
 	//+optional
 	// The execution arguments to pass to the JobTemplate's Job.
 	Payload string `json:"payload,omitempty"`
+
+	//+optional
+	// The values of the JobTemplate's parameters. The parameters that aren't
+	// set use their default.
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	//+optional
+	// Overrides the JobTemplate's RetryPolicy for this execution.
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	//+optional
+	// Cancels the execution. Its Job is suspended, and deleted once the grace
+	// period elapses.
+	Cancel bool `json:"cancel,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	// The seconds to wait for the Job to stop after it is cancelled or times
+	// out, before deleting it. Defaults to 30.
+	CancelGracePeriodSeconds *int64 `json:"cancelGracePeriodSeconds,omitempty"`
+
+	//+optional
+	// Overrides the JobTemplate's Timeout for this execution.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	//+optional
+	// Notifies a URL when the execution finishes.
+	Callback *Callback `json:"callback,omitempty"`
+
+	//+optional
+	// The finished execution that triggered this one, from its JobTemplate's
+	// OnSuccess or OnFailure hooks.
+	Trigger *ExecutionTrigger `json:"trigger,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:MaxItems=10000
+	// Fans out the execution, running a child JobExecution for every item, with
+	// the item as its payload. The JobExecution doesn't create a Job, and
+	// finishes once all its items finish.
+	Items []string `json:"items,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	// The maximum number of items running at the same time. Defaults to 10.
+	Parallelism *int32 `json:"parallelism,omitempty"`
+
+	//+optional
+	// Delays the execution, its Job isn't created before this time. The
+	// Timeout counts from it.
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+}
+
+// ExecutionTrigger describes the execution that triggered a follow-up
+// execution.
+type ExecutionTrigger struct {
+	// The name of the triggering JobExecution.
+	JobExecutionName string `json:"jobExecutionName"`
+
+	// The JobTemplate of the triggering JobExecution.
+	JobTemplateName string `json:"jobTemplateName"`
+
+	// The outcome of the triggering JobExecution: "Succeeded", "Failed" or
+	// "TimedOut".
+	Outcome string `json:"outcome"`
+
+	//+optional
+	// The result of the triggering JobExecution.
+	Result string `json:"result,omitempty"`
+
+	//+optional
+	// The number of follow-ups in the chain that led to this execution, which
+	// is 1 for the follow-up of an execution that wasn't triggered.
+	Depth int32 `json:"depth,omitempty"`
+}
+
+// Callback describes the request sent when a JobExecution finishes. It is a
+// POST request with the final status, timings and result as a JSON body.
+type Callback struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^https?://`
+	// The URL to POST to.
+	URL string `json:"url"`
+
+	//+optional
+	// A key from a Secret in the JobExecution's namespace, used to sign the
+	// request body with HMAC-SHA256. The signature is sent in the
+	// X-Dispatcher-Signature header.
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
 }
 
 // JobExecutionStatus defines the observed state of JobExecution
 type JobExecutionStatus struct {
 	// Represents the observations of a JobExecution's state. The state of the JobExecution is tied to the Job it manages.
-	// Conditions.type are: "Waiting", "Running", "Succeeded".
+	// Conditions.type are: "Waiting", "Scheduled", "Running", "Succeeded", "Cancelled", "TimedOut".
 	// Conditions.status are one of True, False, Unknown.
 	// Conditions.reason defines a camelCase expected values and meanings for this field.
 	// Conditions.Message is a human readable message indicating details about the transition.
@@ -56,6 +151,183 @@ type JobExecutionStatus struct {
 	// Job has a reference to the Job from this execution.
 	// +optional
 	Job corev1.ObjectReference `json:"job,omitempty"`
+
+	// PreviousAttempts records the failed Jobs that were retried, in the order
+	// they were created.
+	// +optional
+	PreviousAttempts []JobExecutionAttempt `json:"previousAttempts,omitempty"`
+
+	// StartTime is the time when the Job of the first attempt started running.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time when the JobExecution finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Attempts is the number of Jobs created for the JobExecution.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// Pods has the status of the most recent pods from the current Job.
+	// +optional
+	Pods []JobExecutionPod `json:"pods,omitempty"`
+
+	// TerminationMessage is the termination message of the last container that
+	// failed in the current Job.
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
+	// Result is the result published by the Job when it completed, as
+	// configured by the JobTemplate.
+	// +optional
+	Result string `json:"result,omitempty"`
+
+	// ResultTruncated is true when the Result was larger than the maximum size,
+	// and was truncated.
+	// +optional
+	ResultTruncated bool `json:"resultTruncated,omitempty"`
+
+	// ResultConfigMap references the ConfigMap that stores the result, when it
+	// was larger than the maximum size.
+	// +optional
+	ResultConfigMap *corev1.LocalObjectReference `json:"resultConfigMap,omitempty"`
+
+	// Callback has the delivery status of the Callback.
+	// +optional
+	Callback *CallbackStatus `json:"callback,omitempty"`
+
+	// NotifiedEvents are the lifecycle events already sent to the JobTemplate's
+	// notification sinks.
+	// +optional
+	NotifiedEvents []NotificationEvent `json:"notifiedEvents,omitempty"`
+
+	// FollowUps are the names of the JobExecutions triggered by the
+	// JobTemplate's hooks once this one finished.
+	// +optional
+	FollowUps []string `json:"followUps,omitempty"`
+
+	// Items has the progress of the items of a fanned out JobExecution.
+	// +optional
+	Items *JobExecutionItemsStatus `json:"items,omitempty"`
+
+	// JobTemplate describes the snapshot of the JobTemplate taken before
+	// creating the first Job. The JobExecution's Jobs are built from it, even
+	// if the JobTemplate changes.
+	// +optional
+	JobTemplate *JobTemplateSnapshot `json:"jobTemplate,omitempty"`
+}
+
+// JobTemplateSnapshot describes the JobTemplate that a JobExecution is built
+// from.
+type JobTemplateSnapshot struct {
+	// The name of the JobTemplate.
+	Name string `json:"name"`
+
+	// The ResourceVersion of the JobTemplate when the snapshot was taken.
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// The Generation of the JobTemplate when the snapshot was taken.
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// The ControllerRevision that stores the effective JobTemplate, after
+	// inheriting from its bases.
+	Revision string `json:"revision"`
+
+	// The SHA-256 hash of the effective JobTemplate, as stored in the
+	// Revision.
+	Hash string `json:"hash"`
+}
+
+// JobExecutionItemsStatus describes the progress of the child JobExecutions of
+// a fanned out JobExecution.
+type JobExecutionItemsStatus struct {
+	// Total is the number of items.
+	Total int32 `json:"total"`
+
+	// Active is the number of items running.
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// Succeeded is the number of items that succeeded.
+	// +optional
+	Succeeded int32 `json:"succeeded,omitempty"`
+
+	// Failed is the number of items that failed, were cancelled or timed out.
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+
+	// SucceededIndexes has the indexes of the items that succeeded, as a list
+	// of intervals, e.g. "1,3-5,7".
+	// +optional
+	SucceededIndexes string `json:"succeededIndexes,omitempty"`
+
+	// FailedIndexes has the indexes of the items that failed, in the same
+	// format as SucceededIndexes.
+	// +optional
+	FailedIndexes string `json:"failedIndexes,omitempty"`
+}
+
+// CallbackStatus describes the delivery of a JobExecution's Callback.
+type CallbackStatus struct {
+	// Attempts is the number of times the delivery was attempted.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// DeliveredAt is the time when the Callback was delivered.
+	// +optional
+	DeliveredAt *metav1.Time `json:"deliveredAt,omitempty"`
+
+	// LastError is the error from the last failed attempt.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// JobExecutionPod describes a pod from a JobExecution's Job.
+type JobExecutionPod struct {
+	// Name of the pod.
+	Name string `json:"name"`
+
+	// Phase of the pod.
+	// +optional
+	Phase corev1.PodPhase `json:"phase,omitempty"`
+
+	// Containers has the status of the pod's terminated containers.
+	// +optional
+	Containers []JobExecutionContainer `json:"containers,omitempty"`
+}
+
+// JobExecutionContainer describes a terminated container from a JobExecution's
+// pod.
+type JobExecutionContainer struct {
+	// Name of the container.
+	Name string `json:"name"`
+
+	// ExitCode of the container.
+	ExitCode int32 `json:"exitCode"`
+
+	// Reason why the container terminated.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// JobExecutionAttempt describes a failed Job from a retried JobExecution.
+type JobExecutionAttempt struct {
+	// Job has a reference to the Job that ran the attempt.
+	Job corev1.ObjectReference `json:"job"`
+
+	// Reason of the Job's Failed condition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message of the Job's Failed condition.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// FinishedAt is the time when the Job failed.
+	FinishedAt metav1.Time `json:"finishedAt"`
 }
 
 // JobExecutionConditionType describes the observed state of a JobExecution and its Job.
@@ -63,8 +335,43 @@ type JobExecutionConditionType string
 
 const (
 	JobExecutionWaiting   JobExecutionConditionType = "Waiting"
+	JobExecutionScheduled JobExecutionConditionType = "Scheduled"
 	JobExecutionRunning   JobExecutionConditionType = "Running"
 	JobExecutionSucceeded JobExecutionConditionType = "Succeeded"
+	JobExecutionCancelled JobExecutionConditionType = "Cancelled"
+	JobExecutionTimedOut  JobExecutionConditionType = "TimedOut"
+)
+
+// The kinds of JobTemplates that a JobExecution can execute.
+const (
+	JobTemplateKind        = "JobTemplate"
+	ClusterJobTemplateKind = "ClusterJobTemplate"
+)
+
+// The key of the result in the data of the JobExecution's ResultConfigMap.
+const JobResultConfigMapKey = "result"
+
+// The standard labels of a JobExecution, with the name of its JobTemplate, who
+// requested it, and the hash of what it executes.
+const (
+	JobTemplateNameLabel       = "job-template-name"
+	JobExecutionRequesterLabel = "job-execution-requester"
+	JobExecutionHashLabel      = "job-execution-hash"
+)
+
+// The annotation with the user that requested the JobExecution. Unlike the
+// label, it isn't truncated.
+const JobExecutionRequesterAnnotation = "job-execution-requester"
+
+// The label with the name of the JobExecution that triggered a follow-up
+// execution.
+const JobExecutionParentLabel = "job-execution-parent"
+
+// The labels with the name of the fanned out JobExecution, and the index of the
+// item run by a child JobExecution.
+const (
+	JobExecutionFanOutLabel    = "job-execution-fan-out"
+	JobExecutionItemIndexLabel = "job-execution-item-index"
 )
 
 //+kubebuilder:object:root=true
@@ -88,32 +395,135 @@ type JobExecutionList struct {
 	Items           []JobExecution `json:"items"`
 }
 
-// Implements conversion to v1beta1.
+// Implements conversion to v1, through v1beta1.
 func (j *JobExecution) ConvertTo(dstRaw conversion.Hub) error {
-	dst := dstRaw.(*v1beta1.JobExecution)
+	dst := new(v1beta1.JobExecution)
+	j.convertToV1beta1(dst)
+	return dst.ConvertTo(dstRaw)
+}
+
+// Converts from the Hub version (v1) to this version, through v1beta1.
+func (j *JobExecution) ConvertFrom(srcRaw conversion.Hub) error {
+	src := new(v1beta1.JobExecution)
+	if err := src.ConvertFrom(srcRaw); err != nil {
+		return err
+	}
+	j.convertFromV1beta1(src)
+	return nil
+}
+
+// Converts to v1beta1.
+func (j *JobExecution) convertToV1beta1(dst *v1beta1.JobExecution) {
 	dst.ObjectMeta = j.ObjectMeta
 
 	dst.Spec.JobTemplateName = j.Spec.JobTemplateName
+	dst.Spec.JobTemplateKind = j.Spec.JobTemplateKind
 	dst.Spec.Payload = j.Spec.Payload
+	dst.Spec.RetryPolicy = (*v1beta1.RetryPolicy)(j.Spec.RetryPolicy)
+	dst.Spec.Cancel = j.Spec.Cancel
+	dst.Spec.CancelGracePeriodSeconds = j.Spec.CancelGracePeriodSeconds
+	dst.Spec.Timeout = j.Spec.Timeout
+	dst.Spec.Callback = (*v1beta1.Callback)(j.Spec.Callback)
+	dst.Spec.Trigger = (*v1beta1.ExecutionTrigger)(j.Spec.Trigger)
+	dst.Spec.Items = j.Spec.Items
+	dst.Spec.Parameters = j.Spec.Parameters
+	dst.Spec.Parallelism = j.Spec.Parallelism
+	dst.Spec.NotBefore = j.Spec.NotBefore
 
 	dst.Status.Conditions = j.Status.Conditions
 	dst.Status.Job = j.Status.Job
-
-	return nil
+	if j.Status.PreviousAttempts != nil {
+		dst.Status.PreviousAttempts = make([]v1beta1.JobExecutionAttempt, len(j.Status.PreviousAttempts))
+		for i, attempt := range j.Status.PreviousAttempts {
+			dst.Status.PreviousAttempts[i] = v1beta1.JobExecutionAttempt(attempt)
+		}
+	}
+	dst.Status.StartTime = j.Status.StartTime
+	dst.Status.CompletionTime = j.Status.CompletionTime
+	dst.Status.Attempts = j.Status.Attempts
+	if j.Status.Pods != nil {
+		dst.Status.Pods = make([]v1beta1.JobExecutionPod, len(j.Status.Pods))
+		for i, pod := range j.Status.Pods {
+			dst.Status.Pods[i] = v1beta1.JobExecutionPod{Name: pod.Name, Phase: pod.Phase}
+			if pod.Containers != nil {
+				dst.Status.Pods[i].Containers = make([]v1beta1.JobExecutionContainer, len(pod.Containers))
+				for k, container := range pod.Containers {
+					dst.Status.Pods[i].Containers[k] = v1beta1.JobExecutionContainer(container)
+				}
+			}
+		}
+	}
+	dst.Status.TerminationMessage = j.Status.TerminationMessage
+	dst.Status.Result = j.Status.Result
+	dst.Status.ResultTruncated = j.Status.ResultTruncated
+	dst.Status.ResultConfigMap = j.Status.ResultConfigMap
+	dst.Status.Callback = (*v1beta1.CallbackStatus)(j.Status.Callback)
+	dst.Status.FollowUps = j.Status.FollowUps
+	dst.Status.Items = (*v1beta1.JobExecutionItemsStatus)(j.Status.Items)
+	dst.Status.JobTemplate = (*v1beta1.JobTemplateSnapshot)(j.Status.JobTemplate)
+	if j.Status.NotifiedEvents != nil {
+		dst.Status.NotifiedEvents = make([]v1beta1.NotificationEvent, len(j.Status.NotifiedEvents))
+		for i, event := range j.Status.NotifiedEvents {
+			dst.Status.NotifiedEvents[i] = v1beta1.NotificationEvent(event)
+		}
+	}
 }
 
-// Converts from the Hub version (v1beta1) to this version.
-func (j *JobExecution) ConvertFrom(srcRaw conversion.Hub) error {
-	src := srcRaw.(*v1beta1.JobExecution)
+// Converts from v1beta1.
+func (j *JobExecution) convertFromV1beta1(src *v1beta1.JobExecution) {
 	j.ObjectMeta = src.ObjectMeta
 
 	j.Spec.JobTemplateName = src.Spec.JobTemplateName
+	j.Spec.JobTemplateKind = src.Spec.JobTemplateKind
 	j.Spec.Payload = src.Spec.Payload
+	j.Spec.RetryPolicy = (*RetryPolicy)(src.Spec.RetryPolicy)
+	j.Spec.Cancel = src.Spec.Cancel
+	j.Spec.CancelGracePeriodSeconds = src.Spec.CancelGracePeriodSeconds
+	j.Spec.Timeout = src.Spec.Timeout
+	j.Spec.Callback = (*Callback)(src.Spec.Callback)
+	j.Spec.Trigger = (*ExecutionTrigger)(src.Spec.Trigger)
+	j.Spec.Items = src.Spec.Items
+	j.Spec.Parameters = src.Spec.Parameters
+	j.Spec.Parallelism = src.Spec.Parallelism
+	j.Spec.NotBefore = src.Spec.NotBefore
 
 	j.Status.Conditions = src.Status.Conditions
 	j.Status.Job = src.Status.Job
-
-	return nil
+	if src.Status.PreviousAttempts != nil {
+		j.Status.PreviousAttempts = make([]JobExecutionAttempt, len(src.Status.PreviousAttempts))
+		for i, attempt := range src.Status.PreviousAttempts {
+			j.Status.PreviousAttempts[i] = JobExecutionAttempt(attempt)
+		}
+	}
+	j.Status.StartTime = src.Status.StartTime
+	j.Status.CompletionTime = src.Status.CompletionTime
+	j.Status.Attempts = src.Status.Attempts
+	if src.Status.Pods != nil {
+		j.Status.Pods = make([]JobExecutionPod, len(src.Status.Pods))
+		for i, pod := range src.Status.Pods {
+			j.Status.Pods[i] = JobExecutionPod{Name: pod.Name, Phase: pod.Phase}
+			if pod.Containers != nil {
+				j.Status.Pods[i].Containers = make([]JobExecutionContainer, len(pod.Containers))
+				for k, container := range pod.Containers {
+					j.Status.Pods[i].Containers[k] = JobExecutionContainer(container)
+				}
+			}
+		}
+	}
+	j.Status.TerminationMessage = src.Status.TerminationMessage
+	j.Status.Result = src.Status.Result
+	j.Status.ResultTruncated = src.Status.ResultTruncated
+	j.Status.ResultConfigMap = src.Status.ResultConfigMap
+	j.Status.Callback = (*CallbackStatus)(src.Status.Callback)
+	j.Status.FollowUps = src.Status.FollowUps
+	j.Status.Items = (*JobExecutionItemsStatus)(src.Status.Items)
+	j.Status.JobTemplate = (*JobTemplateSnapshot)(src.Status.JobTemplate)
+	if src.Status.NotifiedEvents != nil {
+		j.Status.NotifiedEvents = make([]NotificationEvent, len(src.Status.NotifiedEvents))
+		for i, event := range src.Status.NotifiedEvents {
+			j.Status.NotifiedEvents[i] = NotificationEvent(event)
+		}
+	}
 }
 
 func init() {
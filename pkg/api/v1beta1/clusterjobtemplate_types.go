@@ -0,0 +1,66 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterJobTemplateSpec is the spec of a JobTemplate, with the namespaces
+// that can execute it.
+type ClusterJobTemplateSpec struct {
+	JobTemplateSpec `json:",inline"`
+
+	// The namespaces that can execute the ClusterJobTemplate, or "*" for all of
+	// them. It can't be executed from any namespace if it's empty.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterJobTemplate is a cluster-scoped JobTemplate, which can be executed
+// from its allowed namespaces. Its Jobs are created in the namespace of the
+// JobExecution.
+type ClusterJobTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterJobTemplateSpec `json:"spec"`
+}
+
+// IsNamespaceAllowed returns true if the ClusterJobTemplate can be executed
+// from the namespace.
+func (cjt *ClusterJobTemplate) IsNamespaceAllowed(namespace string) bool {
+	return slices.Contains(cjt.Spec.AllowedNamespaces, "*") || slices.Contains(cjt.Spec.AllowedNamespaces, namespace)
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterJobTemplateList contains a list of ClusterJobTemplate
+type ClusterJobTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterJobTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterJobTemplate{}, &ClusterJobTemplateList{})
+}
@@ -18,23 +18,359 @@ package v1beta1
 
 import (
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // +kubebuilder:storageversion
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Last Execution",type=string,JSONPath=`.status.lastExecution`
+// +kubebuilder:printcolumn:name="Outcome",type=string,JSONPath=`.status.lastOutcome`
+// +kubebuilder:printcolumn:name="Active",type=integer,JSONPath=`.status.active`
+// +kubebuilder:printcolumn:name="Queued",type=integer,JSONPath=`.status.queued`
+// +kubebuilder:printcolumn:name="Success Rate",type=integer,JSONPath=`.status.successRate`
+// +kubebuilder:printcolumn:name="Last Success",type=date,JSONPath=`.status.lastSuccessTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // JobTemplate is the Schema for the jobtemplate API
 type JobTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec JobTemplateSpec `json:"spec"`
+	Spec   JobTemplateSpec   `json:"spec"`
+	Status JobTemplateStatus `json:"status,omitempty"`
 }
 
 // JobTemplateSpec defines the desired state of JobTemplate
+// +kubebuilder:validation:XValidation:rule="has(self.jobTemplate) || has(self.base)",message="either jobTemplate or base must be set"
 type JobTemplateSpec struct {
-	// Specifies the Job that will be created when executing the Job.
-	batchv1.JobTemplateSpec `json:"jobTemplate"`
+	//+optional
+	// Specifies the Job that will be created when executing the Job. It is
+	// ignored when the JobTemplate has a Base.
+	batchv1.JobTemplateSpec `json:"jobTemplate,omitempty"`
+
+	//+optional
+	// Inherits the spec of a base JobTemplate, with a patch applied to its Job.
+	Base *JobTemplateBase `json:"base,omitempty"`
+
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	// The parameters of the executions, available to the Job's templates as
+	// .Parameters.
+	Parameters []JobTemplateParameter `json:"parameters,omitempty"`
+
+	//+optional
+	//+listType=set
+	// The fields of the Job that an execution can override. Executions can't
+	// override any field when it is empty.
+	OverridableFields []OverridableField `json:"overridableFields,omitempty"`
+
+	//+optional
+	// Coalesces the executions dispatched through the HTTP API with the same
+	// key into the one that is waiting or running. It isn't inherited from a
+	// base.
+	Deduplication *Deduplication `json:"deduplication,omitempty"`
+
+	//+optional
+	// Specifies how to retry executions whose Job failed. Executions are not
+	// retried when it is not set.
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	//+optional
+	// The maximum time for an execution to finish, counting from its creation.
+	// It covers both the time waiting for the Job to be scheduled, and the time
+	// running it. Executions that exceed it are stopped, and time out.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	//+optional
+	// Specifies how to capture the result of the Job, which is copied to the
+	// JobExecution's status when the Job completes.
+	Result *JobResult `json:"result,omitempty"`
+
+	//+optional
+	// Notifies the sinks of the lifecycle events of the executions.
+	Notifications []Notification `json:"notifications,omitempty"`
+
+	//+optional
+	// A key from a Secret in the execution's namespace, used to sign the
+	// callbacks requested through the HTTP API with HMAC-SHA256. The HTTP API
+	// doesn't let callers choose the Secret. It isn't inherited from a base.
+	CallbackSecretRef *corev1.SecretKeySelector `json:"callbackSecretRef,omitempty"`
+
+	//+optional
+	// The JobTemplates to execute after an execution succeeds.
+	OnSuccess []ExecutionHook `json:"onSuccess,omitempty"`
+
+	//+optional
+	// The JobTemplates to execute after an execution fails or times out.
+	OnFailure []ExecutionHook `json:"onFailure,omitempty"`
+}
+
+// Deduplication describes the key that identifies the duplicate executions.
+type Deduplication struct {
+	//+optional
+	// The template of the key, rendered with the same data as the Job's
+	// templates, e.g. "{{ (fromJson .Payload).cache }}". Defaults to the
+	// payload. Executions whose key renders empty aren't deduplicated, and
+	// the ones whose key fails to render, e.g. accessing a missing field, are
+	// rejected.
+	Key string `json:"key,omitempty"`
+}
+
+// OverridableField is a field of the Job that an execution can override.
+// +kubebuilder:validation:Enum=image;imageTag;env;resources;args;nodeSelector
+type OverridableField string
+
+const (
+	// The image of the container, replaced by its Image or its ImageTag.
+	OverridableFieldImage OverridableField = "image"
+	// The tag of the container's image.
+	OverridableFieldImageTag OverridableField = "imageTag"
+	// The environment variables of the container.
+	OverridableFieldEnv OverridableField = "env"
+	// The resources of the container.
+	OverridableFieldResources OverridableField = "resources"
+	// The arguments of the container.
+	OverridableFieldArgs OverridableField = "args"
+	// The node selector of the pod.
+	OverridableFieldNodeSelector OverridableField = "nodeSelector"
+)
+
+// JobTemplateStatus defines the observed state of JobTemplate, from its
+// executions.
+type JobTemplateStatus struct {
+	//+optional
+	// The name of the last execution that finished.
+	LastExecution string `json:"lastExecution,omitempty"`
+
+	//+optional
+	// The outcome of the last execution that finished.
+	LastOutcome ExecutionOutcome `json:"lastOutcome,omitempty"`
+
+	//+optional
+	// The last time an execution succeeded.
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+
+	//+optional
+	// The last time an execution failed, was cancelled, or timed out.
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	//+optional
+	// The number of executions running.
+	Active int32 `json:"active,omitempty"`
+
+	//+optional
+	// The number of executions waiting to run.
+	Queued int32 `json:"queued,omitempty"`
+
+	//+optional
+	// The percentage of the RecentExecutions that succeeded.
+	SuccessRate *int32 `json:"successRate,omitempty"`
+
+	//+optional
+	// The most recent executions that finished, newest first.
+	RecentExecutions []JobTemplateExecution `json:"recentExecutions,omitempty"`
+}
+
+// JobTemplateExecution describes a finished execution of a JobTemplate.
+type JobTemplateExecution struct {
+	// The name of the JobExecution.
+	Name string `json:"name"`
+
+	// The outcome of the JobExecution.
+	Outcome ExecutionOutcome `json:"outcome"`
+
+	// The time the JobExecution finished.
+	CompletionTime metav1.Time `json:"completionTime"`
+}
+
+// ExecutionOutcome describes how an execution finished.
+// +kubebuilder:validation:Enum=Succeeded;Failed;Cancelled;TimedOut
+type ExecutionOutcome string
+
+const (
+	// The execution's Job completed.
+	ExecutionSucceeded ExecutionOutcome = "Succeeded"
+
+	// The execution's Job failed.
+	ExecutionFailed ExecutionOutcome = "Failed"
+
+	// The execution was cancelled.
+	ExecutionCancelled ExecutionOutcome = "Cancelled"
+
+	// The execution exceeded its timeout.
+	ExecutionTimedOut ExecutionOutcome = "TimedOut"
+)
+
+// JobTemplateParameter describes a parameter of the JobTemplate's executions.
+type JobTemplateParameter struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	// The name of the parameter.
+	Name string `json:"name"`
+
+	//+optional
+	// Describes the parameter.
+	Description string `json:"description,omitempty"`
+
+	//+optional
+	// The value of the parameter when the execution doesn't set it.
+	Default string `json:"default,omitempty"`
+
+	//+optional
+	// Rejects the executions that don't set the parameter.
+	Required bool `json:"required,omitempty"`
+}
+
+// JobTemplateBase describes the JobTemplate inherited by another one. The
+// inheriting JobTemplate gets the base's Job with the Patch applied, and the
+// rest of the base's spec unless it sets it.
+type JobTemplateBase struct {
+	//+kubebuilder:validation:Required
+	// The base JobTemplate, from the same namespace. The base of a
+	// ClusterJobTemplate is another ClusterJobTemplate.
+	Name string `json:"name"`
+
+	//+optional
+	//+kubebuilder:default=StrategicMerge
+	// How to apply the Patch to the base's Job.
+	PatchType JobTemplatePatchType `json:"patchType,omitempty"`
+
+	//+optional
+	// The patch applied to the base's jobTemplate, in JSON or YAML.
+	Patch string `json:"patch,omitempty"`
+}
+
+// JobTemplatePatchType is the type of patch applied to a base JobTemplate.
+// +kubebuilder:validation:Enum=StrategicMerge;Merge;JSONPatch
+type JobTemplatePatchType string
+
+const (
+	// A Kubernetes strategic merge patch, which merges lists such as the
+	// containers by their name.
+	StrategicMergePatchType JobTemplatePatchType = "StrategicMerge"
+
+	// A JSON merge patch (RFC 7386), which replaces lists.
+	MergePatchType JobTemplatePatchType = "Merge"
+
+	// A JSON patch (RFC 6902), a list of operations.
+	JSONPatchType JobTemplatePatchType = "JSONPatch"
+)
+
+// ExecutionHook describes a follow-up execution. It receives the payload of
+// the finished execution, and its outcome and result in the Trigger.
+type ExecutionHook struct {
+	//+kubebuilder:validation:Required
+	// The JobTemplate to execute, from the same namespace.
+	JobTemplateName string `json:"jobTemplateName"`
+}
+
+// RetryPolicy describes how to retry a JobExecution when its Job fails. Every
+// attempt creates a new Job.
+type RetryPolicy struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Minimum=1
+	// The maximum number of Jobs to create for a JobExecution, including the
+	// first one.
+	MaxAttempts int32 `json:"maxAttempts"`
+
+	//+optional
+	// The time to wait before creating the Job for the second attempt. It
+	// doubles with every subsequent attempt. Defaults to 10s.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	//+optional
+	// The maximum time to wait between attempts. Defaults to 10m.
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+
+	//+optional
+	// The reasons from the Job's Failed condition that are retried, e.g.
+	// "DeadlineExceeded" or "BackoffLimitExceeded". All failures are retried
+	// when it is empty.
+	RetryableReasons []string `json:"retryableReasons,omitempty"`
+}
+
+// JobResult describes how to capture the result of a Job. The result is the
+// termination message of a container from the Job's last succeeded pod, which
+// it writes to its terminationMessagePath (/dev/termination-log by default).
+type JobResult struct {
+	//+optional
+	// The name of the container that writes the result. Defaults to the pod's
+	// first container.
+	ContainerName string `json:"containerName,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	// The maximum size in bytes of the result stored in the JobExecution's
+	// status. Larger results are truncated. Defaults to 1024.
+	MaxBytes *int32 `json:"maxBytes,omitempty"`
+
+	//+optional
+	// Stores results larger than MaxBytes in a ConfigMap owned by the
+	// JobExecution, instead of truncating them.
+	SpillToConfigMap bool `json:"spillToConfigMap,omitempty"`
+}
+
+// NotificationEvent is a lifecycle event of a JobExecution.
+// +kubebuilder:validation:Enum=Created;Started;Succeeded;Failed
+type NotificationEvent string
+
+const (
+	NotificationEventCreated   NotificationEvent = "Created"
+	NotificationEventStarted   NotificationEvent = "Started"
+	NotificationEventSucceeded NotificationEvent = "Succeeded"
+	NotificationEventFailed    NotificationEvent = "Failed"
+)
+
+// Notification describes the sink to notify of a JobExecution's lifecycle
+// events. Exactly one of the sinks must be set.
+// +kubebuilder:validation:XValidation:rule="[has(self.webhook), has(self.slack), has(self.email)].filter(x, x).size() == 1",message="exactly one of webhook, slack or email must be set"
+type Notification struct {
+	//+optional
+	// The events to notify. Defaults to all of them.
+	Events []NotificationEvent `json:"events,omitempty"`
+
+	//+optional
+	// POSTs the events as JSON to a URL.
+	Webhook *WebhookSink `json:"webhook,omitempty"`
+
+	//+optional
+	// Posts the events to a Slack-compatible incoming webhook.
+	Slack *SlackSink `json:"slack,omitempty"`
+
+	//+optional
+	// Emails the events, using the SMTP server configured in the manager.
+	Email *EmailSink `json:"email,omitempty"`
+}
+
+// WebhookSink describes a generic webhook.
+type WebhookSink struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Pattern=`^https?://`
+	// The URL to POST to.
+	URL string `json:"url"`
+
+	//+optional
+	// A key from a Secret in the JobTemplate's namespace, used to sign the
+	// request body with HMAC-SHA256.
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+}
+
+// SlackSink describes a Slack-compatible incoming webhook.
+type SlackSink struct {
+	//+kubebuilder:validation:Required
+	// A key from a Secret in the JobTemplate's namespace, with the URL of the
+	// incoming webhook.
+	URLSecretRef corev1.SecretKeySelector `json:"urlSecretRef"`
+}
+
+// EmailSink describes the recipients of an email.
+type EmailSink struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinItems=1
+	// The addresses to send the email to.
+	To []string `json:"to"`
 }
 
 // +kubebuilder:object:root=true
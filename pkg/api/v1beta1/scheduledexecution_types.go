@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScheduledExecutionSpec defines the desired state of ScheduledExecution
+type ScheduledExecutionSpec struct {
+	//+kubebuilder:validation:Required
+	// The JobTemplate to execute.
+	JobTemplateName string `json:"jobTemplateName"`
+
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	// The schedule in Cron format, e.g. "0 * * * *" or "@hourly".
+	Schedule string `json:"schedule"`
+
+	//+optional
+	// The IANA time zone of the schedule, e.g. "America/Mexico_City". Defaults
+	// to the time zone of the controller manager.
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	//+optional
+	// The execution arguments to pass to the JobTemplate's Job.
+	Payload string `json:"payload,omitempty"`
+
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	// The seconds after its scheduled time to start a missed execution. Missed
+	// executions that exceed it are skipped. Without it, the last missed
+	// execution is always started.
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	//+optional
+	//+kubebuilder:default=Allow
+	// How to treat an execution scheduled while the previous one is still
+	// running.
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	//+optional
+	// Suspends the subsequent executions. It doesn't apply to the ones that
+	// already started.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ConcurrencyPolicy describes how to treat concurrent executions.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// Allows executions to run concurrently.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+
+	// Skips the next execution if the previous one is still running.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+
+	// Cancels the running execution, and replaces it with the next one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// ScheduledExecutionStatus defines the observed state of ScheduledExecution
+type ScheduledExecutionStatus struct {
+	// Active has the names of the running JobExecutions.
+	// +optional
+	Active []string `json:"active,omitempty"`
+
+	// LastScheduleTime is the last time an execution was started.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// LastSuccessfulTime is the last time an execution succeeded.
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+}
+
+// The label with the name of the ScheduledExecution that created a
+// JobExecution. Names longer than 63 characters are shortened with their hash.
+const ScheduledExecutionNameLabel = "scheduled-execution-name"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ScheduledExecution is the Schema for the scheduledexecutions API
+type ScheduledExecution struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScheduledExecutionSpec   `json:"spec,omitempty"`
+	Status ScheduledExecutionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ScheduledExecutionList contains a list of ScheduledExecution
+type ScheduledExecutionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduledExecution `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduledExecution{}, &ScheduledExecutionList{})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// Workflow is the Schema for the workflows API. It composes JobTemplates into
+// a directed acyclic graph of steps.
+type Workflow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WorkflowSpec `json:"spec"`
+}
+
+// WorkflowSpec defines the desired state of Workflow
+type WorkflowSpec struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinItems=1
+	// The steps of the Workflow. A step runs once the steps it depends on
+	// finish.
+	Steps []WorkflowStep `json:"steps"`
+}
+
+// WorkflowStep describes a step of a Workflow, which executes a JobTemplate.
+type WorkflowStep struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MaxLength=20
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// The name of the step, unique in the Workflow.
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Required
+	// The JobTemplate to execute, from the Workflow's namespace.
+	JobTemplateName string `json:"jobTemplateName"`
+
+	//+optional
+	// The names of the steps that must finish before this one runs.
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	//+optional
+	// A template for the payload of the step's execution. It has access to the
+	// WorkflowExecution's .Name and .Payload, and to the .Steps it depends on,
+	// with their .Outcome and .Result. Defaults to the WorkflowExecution's
+	// payload.
+	Payload string `json:"payload,omitempty"`
+
+	//+optional
+	// A template with the condition to run the step, which runs when it
+	// renders to "true". It has access to the same data as the Payload.
+	// Without it, the step only runs when all the steps it depends on
+	// succeeded.
+	When string `json:"when,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// WorkflowList contains a list of Workflow
+type WorkflowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Workflow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Workflow{}, &WorkflowList{})
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkflowExecutionSpec defines the desired state of WorkflowExecution
+type WorkflowExecutionSpec struct {
+	//+kubebuilder:validation:Required
+	// The Workflow to execute.
+	WorkflowName string `json:"workflowName"`
+
+	//+optional
+	// The execution arguments, available to the Workflow's steps.
+	Payload string `json:"payload,omitempty"`
+}
+
+// WorkflowExecutionStatus defines the observed state of WorkflowExecution
+type WorkflowExecutionStatus struct {
+	// Conditions store the status conditions of a WorkflowExecution. Their
+	// types are "Running" and "Succeeded".
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// Steps has the status of every step of the Workflow.
+	// +optional
+	Steps []WorkflowStepStatus `json:"steps,omitempty"`
+
+	// StartTime is the time when the first step started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time when the last step finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// WorkflowStepPhase is the phase of a WorkflowExecution's step.
+type WorkflowStepPhase string
+
+const (
+	WorkflowStepPending   WorkflowStepPhase = "Pending"
+	WorkflowStepRunning   WorkflowStepPhase = "Running"
+	WorkflowStepSucceeded WorkflowStepPhase = "Succeeded"
+	WorkflowStepFailed    WorkflowStepPhase = "Failed"
+	WorkflowStepSkipped   WorkflowStepPhase = "Skipped"
+)
+
+// WorkflowStepStatus describes the state of a WorkflowExecution's step.
+type WorkflowStepStatus struct {
+	// The name of the step.
+	Name string `json:"name"`
+
+	// The phase of the step.
+	Phase WorkflowStepPhase `json:"phase"`
+
+	// The JobExecution that runs the step.
+	// +optional
+	JobExecutionName string `json:"jobExecutionName,omitempty"`
+
+	// The result of the step's JobExecution.
+	// +optional
+	Result string `json:"result,omitempty"`
+
+	// A human readable message about the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// The label with the name of the WorkflowExecution that created a
+// JobExecution. Names longer than 63 characters are shortened with their hash.
+const WorkflowExecutionNameLabel = "workflow-execution-name"
+
+// The label with the name of the Workflow's step run by a JobExecution.
+const WorkflowStepLabel = "workflow-step"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// WorkflowExecution is the Schema for the workflowexecutions API
+type WorkflowExecution struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WorkflowExecutionSpec   `json:"spec,omitempty"`
+	Status WorkflowExecutionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WorkflowExecutionList contains a list of WorkflowExecution
+type WorkflowExecutionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WorkflowExecution `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WorkflowExecution{}, &WorkflowExecutionList{})
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// Returns a fake client with the objects, which updates the status of the
+// types with a status subresource only through it, like the API server.
+func newFakeClient(objs ...client.Object) client.WithWatch {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(dispatcherv1.AddToScheme(scheme)).To(Succeed())
+	Expect(dispatcherv1beta1.AddToScheme(scheme)).To(Succeed())
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(
+			&dispatcherv1.JobExecution{},
+			&dispatcherv1beta1.JobTemplate{},
+			&dispatcherv1beta1.ScheduledExecution{},
+			&dispatcherv1beta1.WorkflowExecution{},
+		).
+		Build()
+}
+
+// Returns a JobExecutionReconciler backed by a fake client with the objects.
+func newFakeReconciler(objs ...client.Object) *JobExecutionReconciler {
+	c := newFakeClient(objs...)
+	return &JobExecutionReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(1024),
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/notification"
+)
+
+const (
+	maxCallbackAttempts    = 5
+	defaultCallbackBackoff = 10 * time.Second
+)
+
+// Returns true if the JobExecution finished, and its Callback wasn't
+// delivered yet, nor it ran out of attempts.
+func shouldDeliverCallback(jobExecution *dispatcherv1.JobExecution) bool {
+	if jobExecution.Spec.Callback == nil || !isJobExecutionFinished(jobExecution) {
+		return false
+	}
+	status := jobExecution.Status.Callback
+	return status == nil || (status.DeliveredAt == nil && status.Attempts < maxCallbackAttempts)
+}
+
+// POSTs the final status of the JobExecution to its Callback's URL. Failed
+// deliveries are retried with an exponential backoff.
+func (r *JobExecutionReconciler) deliverCallback(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if jobExecution.Status.Callback == nil {
+		jobExecution.Status.Callback = new(dispatcherv1.CallbackStatus)
+	}
+	status := jobExecution.Status.Callback
+	status.Attempts++
+
+	sendErr := r.sendCallback(ctx, jobExecution)
+	if sendErr == nil {
+		now := metav1.Now()
+		status.DeliveredAt = &now
+		status.LastError = ""
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "CallbackDelivered", "Callback delivered to %s", jobExecution.Spec.Callback.URL)
+		jobExecutionsCallbacksTotal.Inc()
+	} else {
+		log.Error(sendErr, "Failed to deliver callback", "attempt", status.Attempts)
+		status.LastError = sendErr.Error()
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "CallbackFailed", "Failed delivering callback on attempt %d: %s", status.Attempts, sendErr.Error())
+		jobExecutionsCallbacksFailuresTotal.Inc()
+	}
+
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to update JobExecution status when delivering callback")
+		return ctrl.Result{}, err
+	}
+
+	if sendErr != nil && status.Attempts < maxCallbackAttempts {
+		return ctrl.Result{RequeueAfter: defaultCallbackBackoff << (status.Attempts - 1)}, nil
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// Sends the JobExecution's Callback, signed with the secret from its
+// SecretRef.
+func (r *JobExecutionReconciler) sendCallback(ctx context.Context, jobExecution *dispatcherv1.JobExecution) error {
+	payload, err := r.getNotificationPayload(ctx, jobExecution)
+	if err != nil {
+		return err
+	}
+
+	webhook := &notification.Webhook{URL: jobExecution.Spec.Callback.URL, Client: notificationClient}
+	if secretRef := jobExecution.Spec.Callback.SecretRef; secretRef != nil {
+		if webhook.Secret, err = r.getSecretKey(ctx, jobExecution.Namespace, secretRef); err != nil {
+			return err
+		}
+	}
+
+	return webhook.Send(ctx, payload)
+}
+
+// Returns the notification payload of a JobExecution, reading its result from
+// the ResultConfigMap when it was spilled.
+func (r *JobExecutionReconciler) getNotificationPayload(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*notification.Payload, error) {
+	result, err := getJobExecutionResult(ctx, r, jobExecution)
+	if err != nil {
+		return nil, err
+	}
+	payload := newNotificationPayload(jobExecution)
+	payload.Result = result
+	return payload, nil
+}
+
+// Returns the notification payload of a JobExecution, with its outcome once it
+// finished.
+func newNotificationPayload(jobExecution *dispatcherv1.JobExecution) *notification.Payload {
+	payload := &notification.Payload{
+		Name:            jobExecution.Name,
+		Namespace:       jobExecution.Namespace,
+		JobTemplateName: jobExecution.Spec.JobTemplateName,
+		StartTime:       jobExecution.Status.StartTime,
+		CompletionTime:  jobExecution.Status.CompletionTime,
+		Attempts:        jobExecution.Status.Attempts,
+		Result:          jobExecution.Status.Result,
+		ResultTruncated: jobExecution.Status.ResultTruncated,
+	}
+	if !isJobExecutionFinished(jobExecution) {
+		return payload
+	}
+
+	payload.Outcome = string(getJobExecutionOutcome(jobExecution))
+	if condition := meta.FindStatusCondition(jobExecution.Status.Conditions, succeededCondition); condition != nil {
+		payload.Reason = condition.Reason
+		payload.Message = condition.Message
+	}
+	return payload
+}
+
+// Returns the outcome of a finished JobExecution.
+func getJobExecutionOutcome(jobExecution *dispatcherv1.JobExecution) dispatcherv1beta1.ExecutionOutcome {
+	switch {
+	case meta.IsStatusConditionTrue(jobExecution.Status.Conditions, cancelledCondition):
+		return dispatcherv1beta1.ExecutionCancelled
+	case meta.IsStatusConditionTrue(jobExecution.Status.Conditions, timedOutCondition):
+		return dispatcherv1beta1.ExecutionTimedOut
+	case meta.IsStatusConditionTrue(jobExecution.Status.Conditions, succeededCondition):
+		return dispatcherv1beta1.ExecutionSucceeded
+	default:
+		return dispatcherv1beta1.ExecutionFailed
+	}
+}
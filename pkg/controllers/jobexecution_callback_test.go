@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+var _ = Describe("JobExecution callbacks", func() {
+	finished := func(conditionType string, status metav1.ConditionStatus) *dispatcherv1.JobExecution {
+		je := &dispatcherv1.JobExecution{}
+		je.Name = "test-abcde"
+		je.Spec.JobTemplateName = "test"
+		je.Spec.Callback = &dispatcherv1.Callback{URL: "https://example.com"}
+		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
+			Type:    succeededCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "JobFailed",
+			Message: "Job completed with a failed exit status",
+		})
+		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
+			Type:   conditionType,
+			Status: status,
+			Reason: "Test",
+		})
+		return je
+	}
+
+	It("delivers the callback once the JobExecution finishes", func() {
+		je := finished(succeededCondition, metav1.ConditionTrue)
+		Expect(shouldDeliverCallback(je)).To(BeTrue())
+
+		je.Status.Callback = &dispatcherv1.CallbackStatus{Attempts: 1, DeliveredAt: ptr.To(metav1.Now())}
+		Expect(shouldDeliverCallback(je)).To(BeFalse())
+
+		je.Status.Callback = &dispatcherv1.CallbackStatus{Attempts: maxCallbackAttempts}
+		Expect(shouldDeliverCallback(je)).To(BeFalse())
+
+		je = finished(succeededCondition, metav1.ConditionUnknown)
+		Expect(shouldDeliverCallback(je)).To(BeFalse())
+
+		je = finished(succeededCondition, metav1.ConditionTrue)
+		je.Spec.Callback = nil
+		Expect(shouldDeliverCallback(je)).To(BeFalse())
+	})
+
+	It("sets the outcome of the JobExecution", func() {
+		Expect(newNotificationPayload(finished(succeededCondition, metav1.ConditionTrue)).Outcome).To(Equal("Succeeded"))
+		Expect(newNotificationPayload(finished(cancelledCondition, metav1.ConditionTrue)).Outcome).To(Equal("Cancelled"))
+		Expect(newNotificationPayload(finished(timedOutCondition, metav1.ConditionTrue)).Outcome).To(Equal("TimedOut"))
+
+		payload := newNotificationPayload(finished(runningCondition, metav1.ConditionFalse))
+		Expect(payload.Outcome).To(Equal("Failed"))
+		Expect(payload.Reason).To(Equal("JobFailed"))
+		Expect(payload.Name).To(Equal("test-abcde"))
+		Expect(payload.JobTemplateName).To(Equal("test"))
+	})
+})
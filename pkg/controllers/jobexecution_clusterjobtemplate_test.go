@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution ClusterJobTemplates", func() {
+	It("gets the ClusterJobTemplate as a JobTemplate in the JobExecution's namespace", func() {
+		je := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup-abcde", Namespace: "team"},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: "backup",
+				JobTemplateKind: dispatcherv1.ClusterJobTemplateKind,
+			},
+		}
+		reconciler := newFakeReconciler(&dispatcherv1beta1.ClusterJobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup"},
+			Spec: dispatcherv1beta1.ClusterJobTemplateSpec{
+				JobTemplateSpec: dispatcherv1beta1.JobTemplateSpec{
+					Timeout: &metav1.Duration{Duration: time.Minute},
+				},
+				AllowedNamespaces: []string{"team"},
+			},
+		}, je)
+
+		jt, err := reconciler.getJobTemplate(context.Background(), je)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(jt.Name).To(Equal("backup"))
+		Expect(jt.Namespace).To(Equal("team"))
+		Expect(jt.Spec.Timeout).To(Equal(&metav1.Duration{Duration: time.Minute}))
+
+		By("Not falling back to a ClusterJobTemplate")
+		je.Spec.JobTemplateKind = ""
+		je.Status.JobTemplate = nil
+		_, err = reconciler.getJobTemplate(context.Background(), je)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("doesn't get the ClusterJobTemplate from a namespace it doesn't allow", func() {
+		je := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup-abcde", Namespace: "other"},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: "backup",
+				JobTemplateKind: dispatcherv1.ClusterJobTemplateKind,
+			},
+		}
+		reconciler := newFakeReconciler(&dispatcherv1beta1.ClusterJobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup"},
+			Spec:       dispatcherv1beta1.ClusterJobTemplateSpec{AllowedNamespaces: []string{"team"}},
+		}, je)
+
+		_, err := reconciler.getJobTemplate(context.Background(), je)
+		Expect(apierrors.IsForbidden(err)).To(BeTrue())
+	})
+})
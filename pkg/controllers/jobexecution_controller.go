@@ -18,6 +18,8 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -26,23 +28,28 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ref "k8s.io/client-go/tools/reference"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
 	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/notification"
 	"github.com/ivanvc/dispatcher/pkg/template"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
-	waitingCondition   = string(dispatcherv1beta1.JobExecutionWaiting)
-	runningCondition   = string(dispatcherv1beta1.JobExecutionRunning)
-	succeededCondition = string(dispatcherv1beta1.JobExecutionSucceeded)
+	waitingCondition   = string(dispatcherv1.JobExecutionWaiting)
+	scheduledCondition = string(dispatcherv1.JobExecutionScheduled)
+	runningCondition   = string(dispatcherv1.JobExecutionRunning)
+	succeededCondition = string(dispatcherv1.JobExecutionSucceeded)
+	cancelledCondition = string(dispatcherv1.JobExecutionCancelled)
+	timedOutCondition  = string(dispatcherv1.JobExecutionTimedOut)
 )
 
 var (
@@ -58,6 +65,34 @@ var (
 		Name: "job_executions_success_total",
 		Help: "The total number of successful JobExecutions.",
 	})
+	jobExecutionsRetriesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_retries_total",
+		Help: "The total number of Jobs created to retry a JobExecution.",
+	})
+	jobExecutionsCancelledTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_cancelled_total",
+		Help: "The total number of cancelled JobExecutions.",
+	})
+	jobExecutionsTimedOutTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_timed_out_total",
+		Help: "The total number of JobExecutions that exceeded their timeout.",
+	})
+	jobExecutionsCallbacksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_callbacks_total",
+		Help: "The total number of delivered JobExecution callbacks.",
+	})
+	jobExecutionsCallbacksFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_callbacks_failures_total",
+		Help: "The total number of failed JobExecution callback deliveries.",
+	})
+	jobExecutionsNotificationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_notifications_total",
+		Help: "The total number of sent JobExecution notifications.",
+	})
+	jobExecutionsNotificationsFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_executions_notifications_failures_total",
+		Help: "The total number of failed JobExecution notifications.",
+	})
 )
 
 func init() {
@@ -65,18 +100,31 @@ func init() {
 		jobExecutionsTotal,
 		jobExecutionsFailuresTotal,
 		jobExecutionsSuccessTotal,
+		jobExecutionsRetriesTotal,
+		jobExecutionsCancelledTotal,
+		jobExecutionsTimedOutTotal,
+		jobExecutionsCallbacksTotal,
+		jobExecutionsCallbacksFailuresTotal,
+		jobExecutionsNotificationsTotal,
+		jobExecutionsNotificationsFailuresTotal,
 	)
 }
 
 // JobExecutionReconciler reconciles a JobExecution object
 type JobExecutionReconciler struct {
 	client.Client
-	Scheme   *runtime.Scheme
-	Recorder record.EventRecorder
+	// Reads the JobTemplates at the version recorded by the JobExecutions,
+	// bypassing the cache.
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	// The SMTP server used by the email notifications.
+	SMTP *notification.SMTPConfig
 }
 
 //+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobexecutions,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobtemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=clusterjobtemplates,verbs=get;list;watch
 //+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobexecutions/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobexecutions/finalizers,verbs=update
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
@@ -88,7 +136,7 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	log := ctrllog.FromContext(ctx)
 
 	// Fetch the JobExecution
-	je := new(dispatcherv1beta1.JobExecution)
+	je := new(dispatcherv1.JobExecution)
 	if err := r.Get(ctx, req.NamespacedName, je); err != nil {
 		if errors.IsNotFound(err) {
 			log.Info("JobExecution resource not found, ignoring as resouce must be deleted")
@@ -107,7 +155,7 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Reason:  "Reconciling",
 			Message: "Starting reconciliation",
 		})
-		if err := r.Status().Update(ctx, je); err != nil {
+		if err := r.updateJobExecutionStatus(ctx, je); err != nil {
 			log.Error(err, "Failed to set initial JobExecution status")
 			return ctrl.Result{}, err
 		}
@@ -119,7 +167,42 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
+	// Fetch owned Job
+	job, err := r.getJob(ctx, je)
+	if err != nil {
+		log.Error(err, "Failed to get Job")
+		return ctrl.Result{}, err
+	}
+
+	// Cancel the execution, unless it already finished
+	if je.Spec.Cancel && !isJobExecutionFinished(je) {
+		return r.cancelJobExecution(ctx, je, job)
+	}
+	// Notify the caller once the execution finishes, before cleaning up
+	if shouldDeliverCallback(je) {
+		return r.deliverCallback(ctx, je)
+	}
+	if stopped := getStoppedCondition(je); job != nil && stopped != nil {
+		return r.deleteStoppedJob(ctx, je, job, stopped)
+	}
+
+	// If job is not found, and it is not running anymore, don't care about
+	// succeeded condition, as it may or not finished successfully.
+	// Fanned out executions don't have a Job, and are kept once they finish.
+	if job == nil && meta.IsStatusConditionFalse(je.Status.Conditions, runningCondition) && !isFanOutJobExecution(je) {
+		log.Info("JobExecution is already completed", "JobExecution", je.Name)
+		if err := r.Delete(ctx, je); err != nil {
+			log.Error(err, "Failed to delete JobExecution")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
 	jt, err := r.getJobTemplate(ctx, je)
+	if changed := getJobTemplateChangedError(err); changed != nil {
+		return r.failChangedJobTemplate(ctx, je, changed)
+	}
 	if err != nil {
 		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
 			Type:    waitingCondition,
@@ -127,7 +210,7 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Reason:  "FetchJobTemplateError",
 			Message: "Failed fetching JobTemplate",
 		})
-		if err := r.Status().Update(ctx, je); err != nil {
+		if err := r.updateJobExecutionStatus(ctx, je); err != nil {
 			log.Error(err, "Failed to set JobExecution status to failed fetching job template")
 			return ctrl.Result{}, err
 		}
@@ -144,27 +227,23 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
-	// Fetch owned Job
-	job, err := r.getJob(ctx, je)
-	if err != nil {
-		log.Error(err, "Failed to get Job")
-		return ctrl.Result{}, err
+	// Stop the execution once it exceeds its timeout
+	if deadline := getJobExecutionDeadline(je, jt); deadline != nil && time.Now().After(*deadline) && !isJobExecutionFinished(je) {
+		return r.timeOutJobExecution(ctx, je, jt, job)
 	}
 
-	// If job is not found
-	if job == nil {
-		// If job is not running anymore, don't care about succeeded condition, as
-		// it may or not finished successfully.
-		if meta.IsStatusConditionFalse(je.Status.Conditions, runningCondition) {
-			log.Info("JobExecution is already completed", "JobExecution", je.Name)
-			if err := r.Delete(ctx, je); err != nil {
-				log.Error(err, "Failed to delete JobExecution")
-				return ctrl.Result{}, err
-			}
+	// Wait until the execution's NotBefore time to start it
+	if wait := getJobExecutionDelay(je, time.Now()); job == nil && wait > 0 && !isJobExecutionFinished(je) {
+		return r.delayJobExecution(ctx, je, wait)
+	}
 
-			return ctrl.Result{}, nil
-		}
+	// Run the items of a fanned out execution, instead of a Job
+	if isFanOutJobExecution(je) {
+		return r.reconcileFanOut(ctx, je, jt)
+	}
 
+	// If job is not found
+	if job == nil {
 		// Create a job
 		createdJob, err := r.createJob(ctx, je, jt)
 		if err != nil {
@@ -178,12 +257,15 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Reason:  "JobCreated",
 			Message: "Job created, waiting to be executed",
 		})
+		je.Status.Attempts = int32(getJobAttempt(createdJob))
+		clearScheduledCondition(je, "NotBeforeReached", "Job was created")
+		r.notify(ctx, je, jt, dispatcherv1.NotificationEventCreated)
 
 		r.Recorder.Eventf(je, corev1.EventTypeNormal, "Created", "Job %s created", createdJob.Name)
 		log.Info("Created Job, requeueing")
 		jobExecutionsTotal.Inc()
 
-		if err := r.Status().Update(ctx, je); err != nil {
+		if err := r.updateJobExecutionStatus(ctx, je); err != nil {
 			log.Error(err, "Failed to update JobExecution status when creating job")
 			return ctrl.Result{}, err
 		}
@@ -193,6 +275,16 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	// Check status of JobExecution's owned Job
 	if isJobStatusConditionTrue(job, batchv1.JobComplete) {
+		if !isJobExecutionFinished(je) {
+			if err := r.captureJobResult(ctx, je, jt, job); err != nil {
+				log.Error(err, "Failed to capture the Job's result")
+				return ctrl.Result{}, err
+			}
+			if err := r.triggerFollowUps(ctx, je, jt.Spec.OnSuccess, dispatcherv1beta1.ExecutionSucceeded); err != nil {
+				log.Error(err, "Failed to trigger the follow-up executions")
+				return ctrl.Result{}, err
+			}
+		}
 		r.Recorder.Eventf(je, corev1.EventTypeNormal, "Completed", "Job %s completed running", job.Name)
 		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
 			Type:    succeededCondition,
@@ -206,8 +298,20 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Reason:  "JobCompleted",
 			Message: "Job completed running",
 		})
+		setJobExecutionCompletionTime(je, ptr.Deref(job.Status.CompletionTime, metav1.Time{}))
+		r.notify(ctx, je, jt, dispatcherv1.NotificationEventSucceeded)
 		jobExecutionsSuccessTotal.Inc()
 	} else if isJobStatusConditionTrue(job, batchv1.JobFailed) {
+		if policy := getRetryPolicy(je, jt); shouldRetryJob(policy, job) {
+			return r.retryJob(ctx, je, jt, job, policy)
+		}
+		if !isJobExecutionFinished(je) {
+			if err := r.triggerFollowUps(ctx, je, jt.Spec.OnFailure, dispatcherv1beta1.ExecutionFailed); err != nil {
+				log.Error(err, "Failed to trigger the follow-up executions")
+				return ctrl.Result{}, err
+			}
+		}
+
 		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
 			Type:    succeededCondition,
 			Status:  metav1.ConditionFalse,
@@ -220,6 +324,8 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Reason:  "JobCompleted",
 			Message: "Job completed running",
 		})
+		setJobExecutionCompletionTime(je, getJobStatusCondition(job, batchv1.JobFailed).LastTransitionTime)
+		r.notify(ctx, je, jt, dispatcherv1.NotificationEventFailed)
 		r.Recorder.Eventf(je, corev1.EventTypeWarning, "Failed", "Job %s failed running", job.Name)
 		jobExecutionsFailuresTotal.Inc()
 	} else if job.Status.StartTime != nil {
@@ -236,9 +342,10 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Message: "Job is running",
 		})
 		r.Recorder.Eventf(je, corev1.EventTypeNormal, "Started", "Job %s started running", job.Name)
+		r.notify(ctx, je, jt, dispatcherv1.NotificationEventStarted)
 	}
 
-	if len(je.Status.Job.Name) == 0 {
+	if je.Status.Job.UID != job.UID {
 		jobRef, err := ref.GetReference(r.Scheme, job)
 		if err != nil {
 			log.Error(err, "Unable to make reference to job", "job", job)
@@ -246,36 +353,60 @@ func (r *JobExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 		je.Status.Job = *jobRef
 	}
+	if err := r.setJobExecutionStatusFromJob(ctx, je, job); err != nil {
+		log.Error(err, "Failed to get the status of the Job's pods")
+		return ctrl.Result{}, err
+	}
 
-	if err := r.Status().Update(ctx, je); err != nil {
+	if err := r.updateJobExecutionStatus(ctx, je); err != nil {
 		log.Error(err, "Failed to update JobExecution status")
 		return ctrl.Result{}, err
 	}
 
+	if shouldDeliverCallback(je) {
+		return ctrl.Result{Requeue: true}, nil
+	}
 	return ctrl.Result{RequeueAfter: time.Second * 15}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *JobExecutionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&dispatcherv1beta1.JobExecution{}).
+		For(&dispatcherv1.JobExecution{}).
+		Owns(&dispatcherv1.JobExecution{}).
 		Complete(r)
 }
 
 // Generates a Job from a JobTemplate, by applying JobExecution's fields.
-func (r *JobExecutionReconciler) generateJobFromTemplate(jobExecution *dispatcherv1beta1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) (*batchv1.Job, error) {
-	jobTpl, err := template.BuildJob(&jobTemplate.Spec.JobTemplateSpec, jobExecution)
+func (r *JobExecutionReconciler) generateJobFromTemplate(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) (*batchv1.Job, error) {
+	parameters, err := r.getJobExecutionParameters(ctx, jobExecution, jobTemplate)
+	if err != nil {
+		return nil, err
+	}
+	withParameters := jobExecution.DeepCopy()
+	withParameters.Spec.Parameters = parameters
+	jobTpl, err := template.BuildJob(&jobTemplate.Spec.JobTemplateSpec, withParameters)
 	if err != nil {
 		return nil, err
 	}
+	if err := template.ValidateOverrides(jobTemplate.Spec.OverridableFields, jobExecution.Spec.Overrides); err != nil {
+		return nil, err
+	}
+	if err := applyJobExecutionOverrides(&jobTpl.Spec.Template.Spec, jobExecution.Spec.Overrides); err != nil {
+		return nil, err
+	}
 	job := &batchv1.Job{
 		ObjectMeta: jobTpl.ObjectMeta,
 		Spec:       jobTpl.Spec,
 	}
 
+	attempt := len(jobExecution.Status.PreviousAttempts) + 1
 	job.Namespace = jobTemplate.Namespace
 	if len(job.Name) == 0 && len(job.GenerateName) == 0 {
 		job.GenerateName = jobExecution.Name + "-"
+	} else if len(job.Name) > 0 && attempt > 1 {
+		// The Jobs from previous attempts are kept, avoid a name collision.
+		job.Name = fmt.Sprintf("%s-%d", job.Name, attempt)
 	}
 
 	if job.Labels == nil {
@@ -283,25 +414,23 @@ func (r *JobExecutionReconciler) generateJobFromTemplate(jobExecution *dispatche
 	}
 	job.Labels["controller-uid"] = string(jobExecution.GetUID())
 	job.Labels["job-execution-name"] = jobExecution.Name
+	job.Labels[jobAttemptLabel] = strconv.Itoa(attempt)
 
 	ctrl.SetControllerReference(jobExecution, job, r.Scheme)
 	return job, nil
 }
 
-// Gets the JobTemplate from a jobExecution.
-func (r *JobExecutionReconciler) getJobTemplate(ctx context.Context, jobExecution *dispatcherv1beta1.JobExecution) (*dispatcherv1beta1.JobTemplate, error) {
-	jt := new(dispatcherv1beta1.JobTemplate)
-	if err := r.Get(ctx, types.NamespacedName{
-		Name:      jobExecution.Spec.JobTemplateName,
-		Namespace: jobExecution.Namespace,
-	}, jt); err != nil {
-		return nil, err
-	}
-	return jt, nil
+// Gets the effective JobTemplate from a jobExecution, after inheriting from
+// its bases. A ClusterJobTemplate is returned as a JobTemplate in the
+// JobExecution's namespace, where its Job is created. It's read from the
+// JobExecution's snapshot, so later changes to the JobTemplate don't affect
+// it.
+func (r *JobExecutionReconciler) getJobTemplate(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*dispatcherv1beta1.JobTemplate, error) {
+	return r.getJobTemplateSnapshot(ctx, jobExecution)
 }
 
 // Gets the Job from a jobExecution
-func (r *JobExecutionReconciler) getJob(ctx context.Context, jobExecution *dispatcherv1beta1.JobExecution) (*batchv1.Job, error) {
+func (r *JobExecutionReconciler) getJob(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*batchv1.Job, error) {
 	opts := []client.ListOption{
 		client.InNamespace(jobExecution.Namespace),
 		client.MatchingLabels{"controller-uid": string(jobExecution.GetUID())},
@@ -315,12 +444,20 @@ func (r *JobExecutionReconciler) getJob(ctx context.Context, jobExecution *dispa
 		return nil, nil
 	}
 
-	return &jobList.Items[0], nil
+	// When the JobExecution was retried, the latest attempt is the current Job.
+	job := &jobList.Items[0]
+	for i := range jobList.Items {
+		if getJobAttempt(&jobList.Items[i]) > getJobAttempt(job) {
+			job = &jobList.Items[i]
+		}
+	}
+
+	return job, nil
 }
 
 // Creates a Job from a jobExecution and its jobTemplate.
-func (r *JobExecutionReconciler) createJob(ctx context.Context, jobExecution *dispatcherv1beta1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) (*batchv1.Job, error) {
-	job, err := r.generateJobFromTemplate(jobExecution, jobTemplate)
+func (r *JobExecutionReconciler) createJob(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) (*batchv1.Job, error) {
+	job, err := r.generateJobFromTemplate(ctx, jobExecution, jobTemplate)
 	if err != nil {
 		return nil, err
 	}
@@ -330,6 +467,13 @@ func (r *JobExecutionReconciler) createJob(ctx context.Context, jobExecution *di
 	return job, nil
 }
 
+// Returns true if the JobExecution reached its final state, either succeeding
+// or failing.
+func isJobExecutionFinished(jobExecution *dispatcherv1.JobExecution) bool {
+	condition := meta.FindStatusCondition(jobExecution.Status.Conditions, succeededCondition)
+	return condition != nil && condition.Status != metav1.ConditionUnknown
+}
+
 // Returns true if the Job has a condition that matches the given status.
 func isJobStatusConditionTrue(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
 	if job.Status.Conditions == nil {
@@ -342,3 +486,14 @@ func isJobStatusConditionTrue(job *batchv1.Job, conditionType batchv1.JobConditi
 	}
 	return false
 }
+
+// Returns the Job's condition with the given type, or nil if it doesn't have
+// it.
+func getJobStatusCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if job.Status.Conditions[i].Type == conditionType {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -14,11 +17,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
 	dispatcherv1alpha1 "github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
 	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/notification"
 )
 
 var _ = Describe("JobExecution controller", func() {
@@ -110,19 +116,19 @@ var _ = Describe("JobExecution controller", func() {
 
 	It("reconciles a custom resource for JobTemplate", func() {
 		By("Creating the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+		jobExecution := &dispatcherv1.JobExecution{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: jobTemplateName,
 			},
 		}
 		err := k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		if err != nil && errors.IsNotFound(err) {
-			jobExecution := &dispatcherv1beta1.JobExecution{
+			jobExecution := &dispatcherv1.JobExecution{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      jobExecutionName,
 					Namespace: namespace.Name,
 				},
-				Spec: dispatcherv1beta1.JobExecutionSpec{
+				Spec: dispatcherv1.JobExecutionSpec{
 					JobTemplateName: jobTemplateName,
 					Payload:         "test",
 				},
@@ -134,7 +140,7 @@ var _ = Describe("JobExecution controller", func() {
 
 		By("Checking if the custom resource was created")
 		Eventually(func() error {
-			found := &dispatcherv1beta1.JobExecution{}
+			found := &dispatcherv1.JobExecution{}
 			return k8sClient.Get(ctx, typeNamespaceName, found)
 		}, time.Minute, time.Second).Should(Succeed())
 
@@ -224,19 +230,19 @@ var _ = Describe("JobExecution controller", func() {
 		Expect(err).To(Not(HaveOccurred()))
 
 		By("Creating the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+		jobExecution := &dispatcherv1.JobExecution{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: jobTemplateName,
 			},
 		}
 		err = k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		if err != nil && errors.IsNotFound(err) {
-			jobExecution := &dispatcherv1beta1.JobExecution{
+			jobExecution := &dispatcherv1.JobExecution{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      jobExecutionName,
 					Namespace: namespace.Name,
 				},
-				Spec: dispatcherv1beta1.JobExecutionSpec{
+				Spec: dispatcherv1.JobExecutionSpec{
 					JobTemplateName: jobTemplateName,
 					Payload:         "test",
 				},
@@ -248,7 +254,7 @@ var _ = Describe("JobExecution controller", func() {
 
 		By("Checking if the custom resource was created")
 		Eventually(func() error {
-			found := &dispatcherv1beta1.JobExecution{}
+			found := &dispatcherv1.JobExecution{}
 			return k8sClient.Get(ctx, typeNamespaceName, found)
 		}, time.Minute, time.Second).Should(Succeed())
 
@@ -331,12 +337,12 @@ var _ = Describe("JobExecution controller", func() {
 		k8sClient.Update(ctx, jobTemplate)
 
 		By("Setting up the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
+		jobExecution := &dispatcherv1.JobExecution{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      jobExecutionName,
 				Namespace: namespace.Name,
 			},
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: jobTemplateName,
 				Payload:         "test",
 			},
@@ -379,12 +385,12 @@ var _ = Describe("JobExecution controller", func() {
 		k8sClient.Update(ctx, jobTemplate)
 
 		By("Setting up the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
+		jobExecution := &dispatcherv1.JobExecution{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      jobExecutionName,
 				Namespace: namespace.Name,
 			},
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: jobTemplateName,
 				Payload:         "test",
 			},
@@ -421,19 +427,19 @@ var _ = Describe("JobExecution controller", func() {
 
 	It("sets its state as fail if Job fails to run", func() {
 		By("Creating the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+		jobExecution := &dispatcherv1.JobExecution{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: jobTemplateName,
 			},
 		}
 		err := k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		if err != nil && errors.IsNotFound(err) {
-			jobExecution := &dispatcherv1beta1.JobExecution{
+			jobExecution := &dispatcherv1.JobExecution{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      jobExecutionName,
 					Namespace: namespace.Name,
 				},
-				Spec: dispatcherv1beta1.JobExecutionSpec{
+				Spec: dispatcherv1.JobExecutionSpec{
 					JobTemplateName: jobTemplateName,
 					Payload:         "test",
 				},
@@ -445,7 +451,7 @@ var _ = Describe("JobExecution controller", func() {
 
 		By("Checking if the custom resource was created")
 		Eventually(func() error {
-			found := &dispatcherv1beta1.JobExecution{}
+			found := &dispatcherv1.JobExecution{}
 			return k8sClient.Get(ctx, typeNamespaceName, found)
 		}, time.Minute, time.Second).Should(Succeed())
 
@@ -473,6 +479,9 @@ var _ = Describe("JobExecution controller", func() {
 		Expect(res.RequeueAfter).To(Not(BeNil()))
 		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		Expect(meta.IsStatusConditionTrue(jobExecution.Status.Conditions, runningCondition)).To(BeTrue())
+		Expect(jobExecution.Status.StartTime).To(Not(BeNil()))
+		Expect(jobExecution.Status.Attempts).To(Equal(int32(1)))
+		Expect(jobExecution.Status.CompletionTime).To(BeNil())
 
 		By("Updating the JobExecution status when Job finished running")
 		job.Status.Conditions = []batchv1.JobCondition{{
@@ -489,6 +498,7 @@ var _ = Describe("JobExecution controller", func() {
 		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, runningCondition)).To(BeTrue())
 		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, succeededCondition)).To(BeTrue())
+		Expect(jobExecution.Status.CompletionTime).To(Not(BeNil()))
 
 		By("Deleting the JobExecution once the Job is removed")
 		job.Labels["controller-uid"] = ""
@@ -501,21 +511,263 @@ var _ = Describe("JobExecution controller", func() {
 		Expect(errors.IsNotFound(err)).To(BeTrue())
 	})
 
+	It("creates a new Job when a failed Job is retried", func() {
+		By("Creating the JobExecution with a RetryPolicy")
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobExecutionName,
+				Namespace: namespace.Name,
+			},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: jobTemplateName,
+				Payload:         "test",
+				RetryPolicy: &dispatcherv1.RetryPolicy{
+					MaxAttempts: 2,
+					Backoff:     &metav1.Duration{Duration: time.Millisecond},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+
+		By("Running the reconciliation")
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		job := &batchv1.Job{}
+		By("Checking if the Job from the JobExecution was created")
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespaceName, job)
+		}, time.Minute, time.Second).Should(Succeed())
+		Expect(job.ObjectMeta.Labels).To(HaveKeyWithValue(jobAttemptLabel, "1"))
+
+		By("Failing the first attempt")
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type:               batchv1.JobFailed,
+			Status:             corev1.ConditionTrue,
+			Reason:             "DeadlineExceeded",
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+		}}
+		k8sClient.Status().Update(ctx, job)
+		res, err := jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(res.Requeue).To(BeTrue())
+
+		By("Checking the failed attempt was recorded")
+		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
+		Expect(jobExecution.Status.PreviousAttempts).To(HaveLen(1))
+		Expect(jobExecution.Status.PreviousAttempts[0].Job.UID).To(Equal(job.UID))
+		Expect(jobExecution.Status.PreviousAttempts[0].Reason).To(Equal("DeadlineExceeded"))
+		Expect(meta.IsStatusConditionTrue(jobExecution.Status.Conditions, waitingCondition)).To(BeTrue())
+		Expect(meta.FindStatusCondition(jobExecution.Status.Conditions, succeededCondition)).To(BeNil())
+
+		By("Checking the Job for the second attempt was created")
+		retriedJob := &batchv1.Job{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{
+				Name:      jobExecutionName + "-2",
+				Namespace: namespaceName,
+			}, retriedJob)
+		}, time.Minute, time.Second).Should(Succeed())
+		Expect(retriedJob.ObjectMeta.Labels).To(HaveKeyWithValue(jobAttemptLabel, "2"))
+		Expect(jobExecution.Status.Job.UID).To(Equal(retriedJob.UID))
+
+		By("Failing the last attempt")
+		retriedJob.Status.Conditions = []batchv1.JobCondition{{
+			Type:   batchv1.JobFailed,
+			Status: corev1.ConditionTrue,
+			Reason: "DeadlineExceeded",
+		}}
+		k8sClient.Status().Update(ctx, retriedJob)
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
+		Expect(jobExecution.Status.PreviousAttempts).To(HaveLen(1))
+		Expect(jobExecution.Status.Attempts).To(Equal(int32(2)))
+		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, succeededCondition)).To(BeTrue())
+	})
+
+	It("suspends and deletes the Job when the JobExecution is cancelled", func() {
+		By("Creating the JobExecution")
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobExecutionName,
+				Namespace: namespace.Name,
+			},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: jobTemplateName,
+				Payload:         "test",
+			},
+		}
+		err := k8sClient.Create(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+
+		By("Running the reconciliation")
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		job := &batchv1.Job{}
+		By("Checking if the Job from the JobExecution was created")
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespaceName, job)
+		}, time.Minute, time.Second).Should(Succeed())
+
+		By("Cancelling the JobExecution")
+		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
+		jobExecution.Spec.Cancel = true
+		jobExecution.Spec.CancelGracePeriodSeconds = ptr.To(int64(0))
+		err = k8sClient.Update(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		By("Checking the JobExecution is cancelled, and the Job suspended")
+		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
+		Expect(meta.IsStatusConditionTrue(jobExecution.Status.Conditions, cancelledCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, runningCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, succeededCondition)).To(BeTrue())
+		k8sClient.Get(ctx, typeNamespaceName, job)
+		Expect(job.Spec.Suspend).To(HaveValue(BeTrue()))
+
+		By("Deleting the Job once the grace period elapses")
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, typeNamespaceName, job)
+			return errors.IsNotFound(err) || job.DeletionTimestamp != nil
+		}, time.Minute, time.Second).Should(BeTrue())
+	})
+
+	It("times out a JobExecution whose Job doesn't finish in time", func() {
+		By("Creating the JobExecution with a timeout")
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobExecutionName,
+				Namespace: namespace.Name,
+			},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: jobTemplateName,
+				Payload:         "test",
+				Timeout:         &metav1.Duration{Duration: 2 * time.Second},
+			},
+		}
+		err := k8sClient.Create(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+
+		By("Running the reconciliation")
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		job := &batchv1.Job{}
+		By("Checking if the Job from the JobExecution was created")
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespaceName, job)
+		}, time.Minute, time.Second).Should(Succeed())
+
+		By("Reconciling once the timeout elapses")
+		time.Sleep(2 * time.Second)
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		By("Checking the JobExecution timed out, and the Job was suspended")
+		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
+		Expect(meta.IsStatusConditionTrue(jobExecution.Status.Conditions, timedOutCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, runningCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(jobExecution.Status.Conditions, succeededCondition)).To(BeTrue())
+		k8sClient.Get(ctx, typeNamespaceName, job)
+		Expect(job.Spec.Suspend).To(HaveValue(BeTrue()))
+	})
+
+	It("delivers the callback when the JobExecution finishes", func() {
+		received := make(chan notification.Payload, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var payload notification.Payload
+			json.NewDecoder(req.Body).Decode(&payload)
+			received <- payload
+		}))
+		defer server.Close()
+
+		By("Creating the JobExecution with a callback")
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobExecutionName,
+				Namespace: namespace.Name,
+			},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: jobTemplateName,
+				Payload:         "test",
+				Callback:        &dispatcherv1.Callback{URL: server.URL},
+			},
+		}
+		err := k8sClient.Create(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+
+		By("Running the reconciliation")
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		job := &batchv1.Job{}
+		By("Checking if the Job from the JobExecution was created")
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespaceName, job)
+		}, time.Minute, time.Second).Should(Succeed())
+
+		By("Completing the Job")
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type:   batchv1.JobComplete,
+			Status: corev1.ConditionTrue,
+		}}
+		k8sClient.Status().Update(ctx, job)
+		res, err := jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(res.Requeue).To(BeTrue())
+
+		By("Delivering the callback")
+		_, err = jobExecutionReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: typeNamespaceName,
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Eventually(received).Should(Receive(HaveField("Outcome", "Succeeded")))
+		k8sClient.Get(ctx, typeNamespaceName, jobExecution)
+		Expect(jobExecution.Status.Callback).To(Not(BeNil()))
+		Expect(jobExecution.Status.Callback.DeliveredAt).To(Not(BeNil()))
+	})
+
 	It("fails if no jobTemplate is found", func() {
 		By("Creating the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+		jobExecution := &dispatcherv1.JobExecution{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: "not-found",
 			},
 		}
 		err := k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		if err != nil && errors.IsNotFound(err) {
-			jobExecution := &dispatcherv1beta1.JobExecution{
+			jobExecution := &dispatcherv1.JobExecution{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      jobExecutionName,
 					Namespace: namespace.Name,
 				},
-				Spec: dispatcherv1beta1.JobExecutionSpec{
+				Spec: dispatcherv1.JobExecutionSpec{
 					JobTemplateName: "not-found",
 					Payload:         "test",
 				},
@@ -527,7 +779,7 @@ var _ = Describe("JobExecution controller", func() {
 
 		By("Checking if the custom resource was created")
 		Eventually(func() error {
-			found := &dispatcherv1beta1.JobExecution{}
+			found := &dispatcherv1.JobExecution{}
 			return k8sClient.Get(ctx, typeNamespaceName, found)
 		}, time.Minute, time.Second).Should(Succeed())
 
@@ -552,19 +804,19 @@ var _ = Describe("JobExecution controller", func() {
 		k8sClient.Update(ctx, jobTemplate)
 
 		By("Creating the JobExecution")
-		jobExecution := &dispatcherv1beta1.JobExecution{
-			Spec: dispatcherv1beta1.JobExecutionSpec{
+		jobExecution := &dispatcherv1.JobExecution{
+			Spec: dispatcherv1.JobExecutionSpec{
 				JobTemplateName: jobTemplateName,
 			},
 		}
 		err := k8sClient.Get(ctx, typeNamespaceName, jobExecution)
 		if err != nil && errors.IsNotFound(err) {
-			jobExecution := &dispatcherv1beta1.JobExecution{
+			jobExecution := &dispatcherv1.JobExecution{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      jobExecutionName,
 					Namespace: namespace.Name,
 				},
-				Spec: dispatcherv1beta1.JobExecutionSpec{
+				Spec: dispatcherv1.JobExecutionSpec{
 					JobTemplateName: jobTemplateName,
 					Payload:         "test",
 				},
@@ -576,7 +828,7 @@ var _ = Describe("JobExecution controller", func() {
 
 		By("Checking if the custom resource was created")
 		Eventually(func() error {
-			found := &dispatcherv1beta1.JobExecution{}
+			found := &dispatcherv1.JobExecution{}
 			return k8sClient.Get(ctx, typeNamespaceName, found)
 		}, time.Minute, time.Second).Should(Succeed())
 
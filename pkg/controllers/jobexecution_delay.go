@@ -0,0 +1,75 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+// Returns the time left until the JobExecution's NotBefore, or zero if it
+// isn't delayed.
+func getJobExecutionDelay(jobExecution *dispatcherv1.JobExecution, now time.Time) time.Duration {
+	if jobExecution.Spec.NotBefore == nil {
+		return 0
+	}
+	return max(jobExecution.Spec.NotBefore.Sub(now), 0)
+}
+
+// Marks the JobExecution as scheduled, and requeues it once its NotBefore
+// time arrives.
+func (r *JobExecutionReconciler) delayJobExecution(ctx context.Context, jobExecution *dispatcherv1.JobExecution, wait time.Duration) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	notBefore := jobExecution.Spec.NotBefore.UTC().Format(time.RFC3339)
+	if changed := meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+		Type:    scheduledCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "NotBefore",
+		Message: fmt.Sprintf("Job will be created at %s", notBefore),
+	}); changed {
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "Scheduled", "JobExecution %s scheduled for %s", jobExecution.Name, notBefore)
+		if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+			log.Error(err, "Failed to update JobExecution status when scheduling")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: wait}, nil
+}
+
+// Marks a scheduled JobExecution as no longer waiting for its NotBefore time.
+func clearScheduledCondition(jobExecution *dispatcherv1.JobExecution, reason, message string) {
+	if !meta.IsStatusConditionTrue(jobExecution.Status.Conditions, scheduledCondition) {
+		return
+	}
+	meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+		Type:    scheduledCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution delays", func() {
+	const namespace = "default"
+	ctx := context.Background()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "maintenance-abcde", Namespace: namespace}}
+
+	It("doesn't delay a JobExecution without a NotBefore time", func() {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		je := &dispatcherv1.JobExecution{}
+		Expect(getJobExecutionDelay(je, now)).To(BeZero())
+
+		je.Spec.NotBefore = &metav1.Time{Time: now.Add(-time.Minute)}
+		Expect(getJobExecutionDelay(je, now)).To(BeZero())
+
+		je.Spec.NotBefore = &metav1.Time{Time: now.Add(time.Minute)}
+		Expect(getJobExecutionDelay(je, now)).To(Equal(time.Minute))
+	})
+
+	It("creates the Job once the NotBefore time arrives", func() {
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "maintenance-abcde", Namespace: namespace},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: "maintenance",
+				NotBefore:       &metav1.Time{Time: time.Now().Add(time.Hour)},
+			},
+		}
+		reconciler := newFakeReconciler(&dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "maintenance", Namespace: namespace},
+		}, jobExecution)
+		k8sClient := reconciler.Client
+
+		By("Waiting for the NotBefore time")
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(je.Status.Conditions, scheduledCondition)).To(BeTrue())
+		jobs := new(batchv1.JobList)
+		Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace))).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+
+		By("Creating the Job")
+		je.Spec.NotBefore = &metav1.Time{Time: time.Now().Add(-time.Second)}
+		Expect(k8sClient.Update(ctx, je)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(je.Status.Conditions, scheduledCondition)).To(BeTrue())
+		Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace))).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+	})
+})
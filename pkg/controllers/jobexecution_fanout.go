@@ -0,0 +1,309 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+const defaultFanOutParallelism = 10
+
+// Returns true if the JobExecution runs its items as child JobExecutions,
+// instead of creating a Job.
+func isFanOutJobExecution(jobExecution *dispatcherv1.JobExecution) bool {
+	return len(jobExecution.Spec.Items) > 0
+}
+
+// Runs the items of a fanned out JobExecution as child JobExecutions, up to its
+// parallelism, and aggregates their outcomes into its status. The outcomes are
+// recorded by index, so they are kept once the children are deleted.
+func (r *JobExecutionReconciler) reconcileFanOut(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if isJobExecutionFinished(jobExecution) {
+		return ctrl.Result{}, nil
+	}
+
+	children, err := r.listFanOutItems(ctx, jobExecution)
+	if err != nil {
+		log.Error(err, "Failed to list the items' JobExecutions")
+		return ctrl.Result{}, err
+	}
+
+	status := jobExecution.Status.Items
+	if status == nil {
+		status = &dispatcherv1.JobExecutionItemsStatus{}
+		jobExecution.Status.Items = status
+		jobExecution.Status.StartTime = ptr.To(metav1.Now())
+		clearScheduledCondition(jobExecution, "NotBeforeReached", "Items were created")
+		r.notify(ctx, jobExecution, jobTemplate, dispatcherv1.NotificationEventCreated)
+		jobExecutionsTotal.Inc()
+	}
+	status.Total = int32(len(jobExecution.Spec.Items))
+	succeeded := parseIndexes(status.SucceededIndexes)
+	failed := parseIndexes(status.FailedIndexes)
+
+	active := make(map[int]bool)
+	for i := range children {
+		child := &children[i]
+		index, err := strconv.Atoi(child.Labels[dispatcherv1.JobExecutionItemIndexLabel])
+		if err != nil || succeeded[index] || failed[index] {
+			continue
+		}
+		if !isJobExecutionFinished(child) {
+			active[index] = true
+		} else if meta.IsStatusConditionTrue(child.Status.Conditions, succeededCondition) {
+			succeeded[index] = true
+		} else {
+			failed[index] = true
+		}
+	}
+
+	parallelism := int(ptr.Deref(jobExecution.Spec.Parallelism, defaultFanOutParallelism))
+	for index, item := range jobExecution.Spec.Items {
+		if len(active) >= parallelism {
+			break
+		}
+		if succeeded[index] || failed[index] || active[index] {
+			continue
+		}
+		child, err := r.newFanOutItemJobExecution(jobExecution, item, index)
+		if err != nil {
+			log.Error(err, "Failed to generate the item's JobExecution")
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, child); err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "Failed to create the item's JobExecution")
+			return ctrl.Result{}, err
+		}
+		active[index] = true
+	}
+
+	status.Active = int32(len(active))
+	status.Succeeded = int32(len(succeeded))
+	status.Failed = int32(len(failed))
+	status.SucceededIndexes = formatIndexes(succeeded)
+	status.FailedIndexes = formatIndexes(failed)
+
+	if status.Succeeded+status.Failed < status.Total {
+		if !meta.IsStatusConditionTrue(jobExecution.Status.Conditions, runningCondition) {
+			r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "Started", "Running %d items", status.Total)
+			r.notify(ctx, jobExecution, jobTemplate, dispatcherv1.NotificationEventStarted)
+		}
+		meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+			Type:    waitingCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ItemsRunning",
+			Message: "Items are running",
+		})
+		meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+			Type:    runningCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ItemsRunning",
+			Message: fmt.Sprintf("%d of %d items finished", status.Succeeded+status.Failed, status.Total),
+		})
+	} else if err := r.finishFanOut(ctx, jobExecution, jobTemplate); err != nil {
+		log.Error(err, "Failed to trigger the follow-up executions")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to update JobExecution status")
+		return ctrl.Result{}, err
+	}
+
+	if shouldDeliverCallback(jobExecution) {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if isJobExecutionFinished(jobExecution) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: time.Second * 15}, nil
+}
+
+// Sets the final conditions of a fanned out JobExecution whose items
+// finished. It succeeds only if all its items succeeded.
+func (r *JobExecutionReconciler) finishFanOut(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) error {
+	status := jobExecution.Status.Items
+	succeeded := metav1.Condition{
+		Type:    succeededCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ItemsSucceeded",
+		Message: fmt.Sprintf("All %d items succeeded", status.Total),
+	}
+	hooks, outcome, event := jobTemplate.Spec.OnSuccess, dispatcherv1beta1.ExecutionSucceeded, dispatcherv1.NotificationEventSucceeded
+	if status.Failed > 0 {
+		succeeded.Status = metav1.ConditionFalse
+		succeeded.Reason = "ItemsFailed"
+		succeeded.Message = fmt.Sprintf("%d of %d items failed", status.Failed, status.Total)
+		hooks, outcome, event = jobTemplate.Spec.OnFailure, dispatcherv1beta1.ExecutionFailed, dispatcherv1.NotificationEventFailed
+	}
+
+	if err := r.triggerFollowUps(ctx, jobExecution, hooks, outcome); err != nil {
+		return err
+	}
+
+	meta.SetStatusCondition(&jobExecution.Status.Conditions, succeeded)
+	meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+		Type:    runningCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ItemsCompleted",
+		Message: "Items completed running",
+	})
+	setJobExecutionCompletionTime(jobExecution, metav1.Now())
+	r.notify(ctx, jobExecution, jobTemplate, event)
+	if status.Failed > 0 {
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "Failed", "%s", succeeded.Message)
+		jobExecutionsFailuresTotal.Inc()
+	} else {
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "Completed", "%s", succeeded.Message)
+		jobExecutionsSuccessTotal.Inc()
+	}
+	return nil
+}
+
+// Returns the JobExecution that runs the item at the index. It inherits the
+// fanned out JobExecution's options, except for its timeout, which applies to
+// all the items.
+func (r *JobExecutionReconciler) newFanOutItemJobExecution(jobExecution *dispatcherv1.JobExecution, item string, index int) (*dispatcherv1.JobExecution, error) {
+	child := &dispatcherv1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getChildJobExecutionName(jobExecution.Name, strconv.Itoa(index)),
+			Namespace: jobExecution.Namespace,
+			Labels: map[string]string{
+				dispatcherv1.JobExecutionFanOutLabel:    getNameLabelValue(jobExecution.Name),
+				dispatcherv1.JobExecutionItemIndexLabel: strconv.Itoa(index),
+			},
+		},
+		Spec: dispatcherv1.JobExecutionSpec{
+			JobTemplateName:          jobExecution.Spec.JobTemplateName,
+			JobTemplateKind:          jobExecution.Spec.JobTemplateKind,
+			Payload:                  item,
+			RetryPolicy:              jobExecution.Spec.RetryPolicy,
+			CancelGracePeriodSeconds: jobExecution.Spec.CancelGracePeriodSeconds,
+		},
+	}
+	if err := ctrl.SetControllerReference(jobExecution, child, r.Scheme); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// Returns the child JobExecutions of a fanned out JobExecution. The label value
+// can be shared by JobExecutions with long names, so the children are the ones
+// it controls.
+func (r *JobExecutionReconciler) listFanOutItems(ctx context.Context, jobExecution *dispatcherv1.JobExecution) ([]dispatcherv1.JobExecution, error) {
+	list := new(dispatcherv1.JobExecutionList)
+	if err := r.List(
+		ctx,
+		list,
+		client.InNamespace(jobExecution.Namespace),
+		client.MatchingLabels{dispatcherv1.JobExecutionFanOutLabel: getNameLabelValue(jobExecution.Name)},
+	); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(list.Items, func(child dispatcherv1.JobExecution) bool {
+		return !metav1.IsControlledBy(&child, jobExecution)
+	}), nil
+}
+
+// Cancels the running items of a fanned out JobExecution.
+func (r *JobExecutionReconciler) cancelFanOutItems(ctx context.Context, jobExecution *dispatcherv1.JobExecution) error {
+	if !isFanOutJobExecution(jobExecution) {
+		return nil
+	}
+
+	children, err := r.listFanOutItems(ctx, jobExecution)
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		child := &children[i]
+		if child.Spec.Cancel || isJobExecutionFinished(child) {
+			continue
+		}
+		patch := client.MergeFrom(child.DeepCopy())
+		child.Spec.Cancel = true
+		if err := r.Patch(ctx, child, patch); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parses a list of intervals in the form of "1,3-5,7" into a set of indexes.
+// Invalid intervals are ignored.
+func parseIndexes(indexes string) map[int]bool {
+	result := make(map[int]bool)
+	if len(indexes) == 0 {
+		return result
+	}
+	for _, interval := range strings.Split(indexes, ",") {
+		first, last, found := strings.Cut(interval, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		to := from
+		if found {
+			if to, err = strconv.Atoi(last); err != nil {
+				continue
+			}
+		}
+		for i := from; i <= to; i++ {
+			result[i] = true
+		}
+	}
+	return result
+}
+
+// Formats a set of indexes as a list of intervals, in the form of "1,3-5,7".
+func formatIndexes(indexes map[int]bool) string {
+	sorted := slices.Sorted(maps.Keys(indexes))
+	var intervals []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			intervals = append(intervals, strconv.Itoa(sorted[i]))
+		} else {
+			intervals = append(intervals, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(intervals, ",")
+}
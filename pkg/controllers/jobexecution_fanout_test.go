@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution fan-out", func() {
+	const namespace = "default"
+	ctx := context.Background()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reprocess-abcde", Namespace: namespace}}
+
+	var (
+		k8sClient  client.Client
+		reconciler *JobExecutionReconciler
+	)
+
+	BeforeEach(func() {
+		jobTemplate := &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "reprocess", Namespace: namespace},
+		}
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "reprocess-abcde", Namespace: namespace},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: "reprocess",
+				Items:           []string{"a", "b", "c"},
+				Parallelism:     ptr.To[int32](2),
+			},
+		}
+		reconciler = newFakeReconciler(jobTemplate, jobExecution)
+		k8sClient = reconciler.Client
+	})
+
+	finishItem := func(index string, status metav1.ConditionStatus) {
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "reprocess-abcde-" + index, Namespace: namespace}, je)).To(Succeed())
+		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
+			Type:   succeededCondition,
+			Status: status,
+			Reason: "Finished",
+		})
+		Expect(k8sClient.Status().Update(ctx, je)).To(Succeed())
+	}
+
+	getItems := func() *dispatcherv1.JobExecutionItemsStatus {
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		return je.Status.Items
+	}
+
+	It("runs the items up to the parallelism, and aggregates their outcomes", func() {
+		By("Creating the first items")
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(getItems()).To(Equal(&dispatcherv1.JobExecutionItemsStatus{Total: 3, Active: 2}))
+
+		child := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "reprocess-abcde-1", Namespace: namespace}, child)).To(Succeed())
+		Expect(child.Spec.Payload).To(Equal("b"))
+		Expect(child.Labels).To(HaveKeyWithValue(dispatcherv1.JobExecutionFanOutLabel, "reprocess-abcde"))
+		Expect(child.Labels).To(HaveKeyWithValue(dispatcherv1.JobExecutionItemIndexLabel, "1"))
+		Expect(metav1.GetControllerOf(child).Name).To(Equal("reprocess-abcde"))
+
+		By("Creating the next item once one finishes")
+		finishItem("0", metav1.ConditionTrue)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(getItems()).To(Equal(&dispatcherv1.JobExecutionItemsStatus{Total: 3, Active: 2, Succeeded: 1, SucceededIndexes: "0"}))
+
+		By("Keeping the outcomes of the deleted items")
+		Expect(k8sClient.Delete(ctx, &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "reprocess-abcde-0", Namespace: namespace},
+		})).To(Succeed())
+		finishItem("1", metav1.ConditionFalse)
+		finishItem("2", metav1.ConditionTrue)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(getItems()).To(Equal(&dispatcherv1.JobExecutionItemsStatus{
+			Total:            3,
+			Succeeded:        2,
+			Failed:           1,
+			SucceededIndexes: "0,2",
+			FailedIndexes:    "1",
+		}))
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(je.Status.Conditions, succeededCondition)).To(BeTrue())
+		Expect(meta.FindStatusCondition(je.Status.Conditions, succeededCondition).Reason).To(Equal("ItemsFailed"))
+		Expect(meta.IsStatusConditionFalse(je.Status.Conditions, runningCondition)).To(BeTrue())
+		Expect(je.Status.CompletionTime).To(Not(BeNil()))
+		Expect(je.Status.Phase).To(Equal(dispatcherv1.JobExecutionPhaseFailed))
+
+		By("Keeping the finished execution")
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+	})
+
+	It("cancels the running items", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		je.Spec.Cancel = true
+		Expect(k8sClient.Update(ctx, je)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		children := new(dispatcherv1.JobExecutionList)
+		Expect(k8sClient.List(ctx, children, client.MatchingLabels{dispatcherv1.JobExecutionFanOutLabel: "reprocess-abcde"})).To(Succeed())
+		Expect(children.Items).To(HaveLen(2))
+		for _, child := range children.Items {
+			Expect(child.Spec.Cancel).To(BeTrue())
+		}
+	})
+
+	It("selects the items of a JobExecution with a long name by their owner", func() {
+		name := strings.Repeat("reprocess-", 7) + "abcde"
+		jobExecution := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: "reprocess",
+				Items:           []string{"a", "b"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, jobExecution)).To(Succeed())
+		Expect(k8sClient.Create(ctx, &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "unrelated",
+				Namespace: namespace,
+				Labels:    map[string]string{dispatcherv1.JobExecutionFanOutLabel: getNameLabelValue(name)},
+			},
+		})).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}})
+		Expect(err).To(Not(HaveOccurred()))
+
+		children, err := reconciler.listFanOutItems(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(children).To(HaveLen(2))
+		for _, child := range children {
+			Expect(len(child.Labels[dispatcherv1.JobExecutionFanOutLabel])).To(BeNumerically("<=", validation.LabelValueMaxLength))
+			Expect(metav1.GetControllerOf(&child).Name).To(Equal(name))
+		}
+	})
+
+	It("formats and parses the indexes as intervals", func() {
+		indexes := map[int]bool{0: true, 1: true, 2: true, 5: true, 7: true, 8: true}
+		Expect(formatIndexes(indexes)).To(Equal("0-2,5,7-8"))
+		Expect(parseIndexes("0-2,5,7-8")).To(Equal(indexes))
+		Expect(formatIndexes(parseIndexes(""))).To(BeEmpty())
+	})
+})
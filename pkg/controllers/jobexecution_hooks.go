@@ -0,0 +1,140 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// The JobExecution's name is used as a label value, which has a limit of 63
+// characters.
+const maxJobExecutionNameLength = 63
+
+// The maximum depth of a chain of follow-ups, which stops the hooks that
+// trigger each other in a cycle.
+const maxFollowUpDepth = 10
+
+// Creates the follow-up JobExecutions of a finished JobExecution, from its
+// JobTemplate's hooks. Their names are deterministic, so a follow-up is
+// created only once.
+func (r *JobExecutionReconciler) triggerFollowUps(ctx context.Context, jobExecution *dispatcherv1.JobExecution, hooks []dispatcherv1beta1.ExecutionHook, outcome dispatcherv1beta1.ExecutionOutcome) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	if getFollowUpDepth(jobExecution) > maxFollowUpDepth {
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "FollowUpsSkipped", "Skipped the follow-ups, which exceed the maximum depth of %d", maxFollowUpDepth)
+		return nil
+	}
+
+	result, err := getJobExecutionResult(ctx, r, jobExecution)
+	if err != nil {
+		return err
+	}
+
+	for i, hook := range hooks {
+		followUp := newFollowUpJobExecution(jobExecution, hook, outcome, result, i)
+		if err := r.Create(ctx, followUp); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		if !slices.Contains(jobExecution.Status.FollowUps, followUp.Name) {
+			jobExecution.Status.FollowUps = append(jobExecution.Status.FollowUps, followUp.Name)
+			r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "Triggered", "Triggered JobExecution %s from JobTemplate %s", followUp.Name, hook.JobTemplateName)
+		}
+	}
+	return nil
+}
+
+// Returns the follow-up JobExecution for the hook, which receives the payload
+// of the finished JobExecution. The hooks of a ClusterJobTemplate refer to
+// other ClusterJobTemplates.
+func newFollowUpJobExecution(jobExecution *dispatcherv1.JobExecution, hook dispatcherv1beta1.ExecutionHook, outcome dispatcherv1beta1.ExecutionOutcome, result string, index int) *dispatcherv1.JobExecution {
+	return &dispatcherv1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getFollowUpJobExecutionName(jobExecution.Name, outcome, index),
+			Namespace: jobExecution.Namespace,
+			Labels: map[string]string{
+				dispatcherv1.JobExecutionParentLabel: getNameLabelValue(jobExecution.Name),
+			},
+		},
+		Spec: dispatcherv1.JobExecutionSpec{
+			JobTemplateName: hook.JobTemplateName,
+			JobTemplateKind: jobExecution.Spec.JobTemplateKind,
+			Payload:         jobExecution.Spec.Payload,
+			Trigger: &dispatcherv1.ExecutionTrigger{
+				JobExecutionName: jobExecution.Name,
+				JobTemplateName:  jobExecution.Spec.JobTemplateName,
+				Outcome:          string(outcome),
+				Result:           result,
+				Depth:            getFollowUpDepth(jobExecution),
+			},
+		},
+	}
+}
+
+// Returns the depth of the follow-ups of the JobExecution, one more than its
+// own.
+func getFollowUpDepth(jobExecution *dispatcherv1.JobExecution) int32 {
+	if jobExecution.Spec.Trigger == nil {
+		return 1
+	}
+	return jobExecution.Spec.Trigger.Depth + 1
+}
+
+// Returns the name of the follow-up JobExecution, in the form of
+// <parent>-<outcome>-<index>.
+func getFollowUpJobExecutionName(parent string, outcome dispatcherv1beta1.ExecutionOutcome, index int) string {
+	return getChildJobExecutionName(parent, fmt.Sprintf("%s-%d", strings.ToLower(string(outcome)), index))
+}
+
+// Returns the name of a JobExecution created for a parent resource, in the
+// form of <parent>-<suffix>. Long parent names are shortened, and suffixed with
+// their hash to keep them unique.
+func getChildJobExecutionName(parent, suffix string) string {
+	suffix = "-" + suffix
+	return shortenName(parent, maxJobExecutionNameLength-len(suffix)) + suffix
+}
+
+// Returns the name of a parent resource as a label value of its children. Long
+// names are shortened like the children's names.
+func getNameLabelValue(name string) string {
+	return shortenName(name, validation.LabelValueMaxLength)
+}
+
+// Returns the name if it fits in the length, or otherwise its prefix suffixed
+// with its hash.
+func shortenName(name string, length int) string {
+	if len(name) <= length {
+		return name
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hash := fmt.Sprintf("-%08x", h.Sum32())
+	return strings.TrimRight(name[:length-len(hash)], "-.") + hash
+}
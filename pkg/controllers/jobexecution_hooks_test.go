@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution hooks", func() {
+	It("names the follow-ups deterministically", func() {
+		Expect(getFollowUpJobExecutionName("build-abcde", dispatcherv1beta1.ExecutionSucceeded, 0)).To(Equal("build-abcde-succeeded-0"))
+
+		long := strings.Repeat("a", 60)
+		name := getFollowUpJobExecutionName(long, dispatcherv1beta1.ExecutionTimedOut, 1)
+		Expect(len(name)).To(BeNumerically("<=", maxJobExecutionNameLength))
+		Expect(name).To(HaveSuffix("-timedout-1"))
+		Expect(name).To(Equal(getFollowUpJobExecutionName(long, dispatcherv1beta1.ExecutionTimedOut, 1)))
+		Expect(name).To(Not(Equal(getFollowUpJobExecutionName(long+"b", dispatcherv1beta1.ExecutionTimedOut, 1))))
+	})
+
+	It("shortens the long names used as label values", func() {
+		Expect(getNameLabelValue("build-abcde")).To(Equal("build-abcde"))
+
+		long := strings.Repeat("a", 70)
+		value := getNameLabelValue(long)
+		Expect(validation.IsValidLabelValue(value)).To(BeEmpty())
+		Expect(value).To(Equal(getNameLabelValue(long)))
+		Expect(value).To(Not(Equal(getNameLabelValue(long + "b"))))
+	})
+
+	It("creates the follow-ups once, with the trigger", func() {
+		reconciler := newFakeReconciler()
+
+		je := &dispatcherv1.JobExecution{}
+		je.Name = "build-abcde"
+		je.Namespace = "default"
+		je.Spec.JobTemplateName = "build"
+		je.Spec.Payload = "payload"
+		je.Status.Result = "42"
+		hooks := []dispatcherv1beta1.ExecutionHook{{JobTemplateName: "deploy"}}
+
+		ctx := context.Background()
+		Expect(reconciler.triggerFollowUps(ctx, je, hooks, dispatcherv1beta1.ExecutionSucceeded)).To(Succeed())
+		Expect(reconciler.triggerFollowUps(ctx, je, hooks, dispatcherv1beta1.ExecutionSucceeded)).To(Succeed())
+		Expect(je.Status.FollowUps).To(Equal([]string{"build-abcde-succeeded-0"}))
+
+		followUp := new(dispatcherv1.JobExecution)
+		Expect(reconciler.Get(ctx, types.NamespacedName{Name: "build-abcde-succeeded-0", Namespace: "default"}, followUp)).To(Succeed())
+		Expect(followUp.Spec.JobTemplateName).To(Equal("deploy"))
+		Expect(followUp.Spec.Payload).To(Equal("payload"))
+		Expect(followUp.Labels).To(HaveKeyWithValue(dispatcherv1.JobExecutionParentLabel, "build-abcde"))
+		Expect(followUp.Spec.Trigger).To(Equal(&dispatcherv1.ExecutionTrigger{
+			JobExecutionName: "build-abcde",
+			JobTemplateName:  "build",
+			Outcome:          "Succeeded",
+			Result:           "42",
+			Depth:            1,
+		}))
+	})
+
+	It("stops triggering the follow-ups past the maximum depth", func() {
+		reconciler := newFakeReconciler()
+		hooks := []dispatcherv1beta1.ExecutionHook{{JobTemplateName: "build"}}
+		ctx := context.Background()
+
+		je := &dispatcherv1.JobExecution{}
+		je.Name = "build-abcde"
+		je.Namespace = "default"
+		je.Spec.JobTemplateName = "build"
+		je.Spec.Trigger = &dispatcherv1.ExecutionTrigger{JobExecutionName: "build-fghij", JobTemplateName: "build", Depth: maxFollowUpDepth - 1}
+		Expect(reconciler.triggerFollowUps(ctx, je, hooks, dispatcherv1beta1.ExecutionSucceeded)).To(Succeed())
+		Expect(je.Status.FollowUps).To(HaveLen(1))
+
+		followUp := new(dispatcherv1.JobExecution)
+		Expect(reconciler.Get(ctx, types.NamespacedName{Name: je.Status.FollowUps[0], Namespace: "default"}, followUp)).To(Succeed())
+		Expect(followUp.Spec.Trigger.Depth).To(Equal(int32(maxFollowUpDepth)))
+		Expect(reconciler.triggerFollowUps(ctx, followUp, hooks, dispatcherv1beta1.ExecutionSucceeded)).To(Succeed())
+		Expect(followUp.Status.FollowUps).To(BeEmpty())
+
+		list := new(dispatcherv1.JobExecutionList)
+		Expect(reconciler.List(ctx, list)).To(Succeed())
+		Expect(list.Items).To(HaveLen(1))
+	})
+})
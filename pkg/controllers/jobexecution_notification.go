@@ -0,0 +1,114 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/notification"
+)
+
+var notificationClient = &http.Client{Timeout: 10 * time.Second}
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Notifies the JobTemplate's sinks of a lifecycle event of the JobExecution,
+// unless it was already notified. Notifications are best effort, failures are
+// recorded as events, and don't stop the reconciliation.
+func (r *JobExecutionReconciler) notify(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate, event dispatcherv1.NotificationEvent) {
+	if len(jobTemplate.Spec.Notifications) == 0 || slices.Contains(jobExecution.Status.NotifiedEvents, event) {
+		return
+	}
+	log := ctrllog.FromContext(ctx)
+	jobExecution.Status.NotifiedEvents = append(jobExecution.Status.NotifiedEvents, event)
+
+	payload, err := r.getNotificationPayload(ctx, jobExecution)
+	if err != nil {
+		log.Error(err, "Failed to get the JobExecution's result for the notification")
+		payload = newNotificationPayload(jobExecution)
+	}
+	payload.Event = string(event)
+
+	for i := range jobTemplate.Spec.Notifications {
+		n := &jobTemplate.Spec.Notifications[i]
+		if len(n.Events) > 0 && !slices.Contains(n.Events, dispatcherv1beta1.NotificationEvent(event)) {
+			continue
+		}
+
+		sink, err := r.getNotificationSink(ctx, jobTemplate, n)
+		if err == nil {
+			err = sink.Send(ctx, payload)
+		}
+		if err != nil {
+			log.Error(err, "Failed to send notification", "event", event, "notification", i)
+			r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "NotificationFailed", "Failed sending %s notification %d: %s", event, i, err.Error())
+			jobExecutionsNotificationsFailuresTotal.Inc()
+			continue
+		}
+		jobExecutionsNotificationsTotal.Inc()
+	}
+}
+
+// Returns the sink of the notification, reading its secrets from the
+// JobTemplate's namespace.
+func (r *JobExecutionReconciler) getNotificationSink(ctx context.Context, jobTemplate *dispatcherv1beta1.JobTemplate, n *dispatcherv1beta1.Notification) (notification.Sink, error) {
+	switch {
+	case n.Webhook != nil:
+		webhook := &notification.Webhook{URL: n.Webhook.URL, Client: notificationClient}
+		if n.Webhook.SecretRef != nil {
+			secret, err := r.getSecretKey(ctx, jobTemplate.Namespace, n.Webhook.SecretRef)
+			if err != nil {
+				return nil, err
+			}
+			webhook.Secret = secret
+		}
+		return webhook, nil
+	case n.Slack != nil:
+		url, err := r.getSecretKey(ctx, jobTemplate.Namespace, &n.Slack.URLSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return &notification.Slack{URL: string(url), Client: notificationClient}, nil
+	case n.Email != nil:
+		return &notification.Email{Config: r.SMTP, To: n.Email.To, Timeout: notificationClient.Timeout}, nil
+	}
+	return nil, errors.New("notification doesn't have a sink")
+}
+
+// Returns the value of the Secret's key.
+func (r *JobExecutionReconciler) getSecretKey(ctx context.Context, namespace string, selector *corev1.SecretKeySelector) ([]byte, error) {
+	secret := new(corev1.Secret)
+	if err := r.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in Secret %s", selector.Key, selector.Name)
+	}
+	return value, nil
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/notification"
+)
+
+var _ = Describe("JobExecution notifications", func() {
+	var (
+		server     *httptest.Server
+		received   []notification.Payload
+		signatures []string
+		reconciler *JobExecutionReconciler
+	)
+
+	BeforeEach(func() {
+		received, signatures = nil, nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var payload notification.Payload
+			json.NewDecoder(req.Body).Decode(&payload)
+			received = append(received, payload)
+			signatures = append(signatures, req.Header.Get(notification.SignatureHeader))
+		}))
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "signing", Namespace: "default"},
+			Data:       map[string][]byte{"secret": []byte("value")},
+		}
+		reconciler = newFakeReconciler(secret)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("notifies the sinks of the selected events once", func() {
+		je := &dispatcherv1.JobExecution{}
+		je.Name = "test-abcde"
+		je.Namespace = "default"
+		jt := &dispatcherv1beta1.JobTemplate{}
+		jt.Namespace = "default"
+		jt.Spec.Notifications = []dispatcherv1beta1.Notification{{
+			Webhook: &dispatcherv1beta1.WebhookSink{URL: server.URL},
+		}, {
+			Events: []dispatcherv1beta1.NotificationEvent{dispatcherv1beta1.NotificationEventStarted},
+			Webhook: &dispatcherv1beta1.WebhookSink{
+				URL: server.URL,
+				SecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "signing"},
+					Key:                  "secret",
+				},
+			},
+		}}
+
+		reconciler.notify(context.Background(), je, jt, dispatcherv1.NotificationEventCreated)
+		reconciler.notify(context.Background(), je, jt, dispatcherv1.NotificationEventCreated)
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Event).To(Equal("Created"))
+		Expect(signatures[0]).To(BeEmpty())
+
+		reconciler.notify(context.Background(), je, jt, dispatcherv1.NotificationEventStarted)
+		Expect(received).To(HaveLen(3))
+		Expect(signatures[2]).To(HavePrefix("sha256="))
+		Expect(je.Status.NotifiedEvents).To(Equal([]dispatcherv1.NotificationEvent{
+			dispatcherv1.NotificationEventCreated,
+			dispatcherv1.NotificationEventStarted,
+		}))
+	})
+
+	It("doesn't stop when a sink fails", func() {
+		je := &dispatcherv1.JobExecution{}
+		jt := &dispatcherv1beta1.JobTemplate{}
+		jt.Namespace = "default"
+		jt.Spec.Notifications = []dispatcherv1beta1.Notification{{
+			Slack: &dispatcherv1beta1.SlackSink{
+				URLSecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "missing"},
+					Key:                  "url",
+				},
+			},
+		}, {
+			Email: &dispatcherv1beta1.EmailSink{To: []string{"team@example.com"}},
+		}, {
+			Webhook: &dispatcherv1beta1.WebhookSink{URL: server.URL},
+		}}
+
+		reconciler.notify(context.Background(), je, jt, dispatcherv1.NotificationEventFailed)
+		Expect(received).To(HaveLen(1))
+		Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(HaveLen(2))
+	})
+})
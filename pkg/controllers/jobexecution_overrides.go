@@ -0,0 +1,99 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+// Applies the JobExecution's overrides to the pod of its Job.
+func applyJobExecutionOverrides(podSpec *corev1.PodSpec, overrides *dispatcherv1.ExecutionOverrides) error {
+	if overrides == nil {
+		return nil
+	}
+
+	container, err := getOverriddenContainer(podSpec, overrides.ContainerName)
+	if err != nil {
+		return err
+	}
+	if len(overrides.Image) > 0 {
+		container.Image = overrides.Image
+	}
+	if len(overrides.ImageTag) > 0 {
+		container.Image = setImageTag(container.Image, overrides.ImageTag)
+	}
+	for _, env := range overrides.Env {
+		container.Env = setEnvVar(container.Env, env)
+	}
+	if overrides.Resources != nil {
+		container.Resources = *overrides.Resources.DeepCopy()
+	}
+	if overrides.Args != nil {
+		container.Args = slices.Clone(overrides.Args)
+	}
+
+	if len(overrides.NodeSelector) > 0 && podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = make(map[string]string, len(overrides.NodeSelector))
+	}
+	for key, value := range overrides.NodeSelector {
+		podSpec.NodeSelector[key] = value
+	}
+	return nil
+}
+
+// Returns the container with the name, or the pod's first container if the
+// name is empty.
+func getOverriddenContainer(podSpec *corev1.PodSpec, name string) (*corev1.Container, error) {
+	if len(name) == 0 {
+		if len(podSpec.Containers) == 0 {
+			return nil, fmt.Errorf("pod doesn't have containers to override")
+		}
+		return &podSpec.Containers[0], nil
+	}
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == name {
+			return &podSpec.Containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("container %q to override not found", name)
+}
+
+// Sets the environment variable, replacing the one with the same name.
+func setEnvVar(env []corev1.EnvVar, envVar corev1.EnvVar) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == envVar.Name {
+			env[i] = *envVar.DeepCopy()
+			return env
+		}
+	}
+	return append(env, *envVar.DeepCopy())
+}
+
+// Returns the image with the tag, replacing its tag or digest.
+func setImageTag(image, tag string) string {
+	repository, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return repository + ":" + tag
+}
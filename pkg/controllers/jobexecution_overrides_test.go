@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+var _ = Describe("JobExecution overrides", func() {
+	var podSpec *corev1.PodSpec
+
+	BeforeEach(func() {
+		podSpec = &corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "main",
+					Image: "busybox:1.36",
+					Env:   []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}},
+				},
+				{Name: "sidecar", Image: "envoy:1.30"},
+			},
+			NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+		}
+	})
+
+	It("overrides the first container by default", func() {
+		resources := &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		}
+		Expect(applyJobExecutionOverrides(podSpec, &dispatcherv1.ExecutionOverrides{
+			Image: "busybox:1.37",
+			Env: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "debug"},
+				{Name: "DRY_RUN", Value: "true"},
+			},
+			Resources:    resources,
+			NodeSelector: map[string]string{"disktype": "ssd"},
+		})).To(Succeed())
+
+		Expect(podSpec.Containers[0].Image).To(Equal("busybox:1.37"))
+		Expect(podSpec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "DRY_RUN", Value: "true"},
+		}))
+		Expect(podSpec.Containers[0].Resources).To(Equal(*resources))
+		Expect(podSpec.Containers[1].Image).To(Equal("envoy:1.30"))
+		Expect(podSpec.NodeSelector).To(Equal(map[string]string{
+			"kubernetes.io/os": "linux",
+			"disktype":         "ssd",
+		}))
+	})
+
+	It("overrides the container with the name", func() {
+		Expect(applyJobExecutionOverrides(podSpec, &dispatcherv1.ExecutionOverrides{
+			ContainerName: "sidecar",
+			Image:         "envoy:1.31",
+		})).To(Succeed())
+		Expect(podSpec.Containers[0].Image).To(Equal("busybox:1.36"))
+		Expect(podSpec.Containers[1].Image).To(Equal("envoy:1.31"))
+	})
+
+	It("overrides the image's tag and the arguments", func() {
+		podSpec.Containers[0].Args = []string{"--verbose"}
+		Expect(applyJobExecutionOverrides(podSpec, &dispatcherv1.ExecutionOverrides{
+			ImageTag: "sha-1234567",
+			Args:     []string{"--dry-run"},
+		})).To(Succeed())
+		Expect(podSpec.Containers[0].Image).To(Equal("busybox:sha-1234567"))
+		Expect(podSpec.Containers[0].Args).To(Equal([]string{"--dry-run"}))
+	})
+
+	It("replaces the tag or digest of the image", func() {
+		Expect(setImageTag("busybox", "v2")).To(Equal("busybox:v2"))
+		Expect(setImageTag("registry.local:5000/team/app:v1", "v2")).To(Equal("registry.local:5000/team/app:v2"))
+		Expect(setImageTag("registry.local:5000/team/app", "v2")).To(Equal("registry.local:5000/team/app:v2"))
+		Expect(setImageTag("app:v1@sha256:abcdef", "v2")).To(Equal("app:v2"))
+	})
+
+	It("fails if the container doesn't exist", func() {
+		Expect(applyJobExecutionOverrides(podSpec, &dispatcherv1.ExecutionOverrides{
+			ContainerName: "worker",
+		})).To(MatchError(`container "worker" to override not found`))
+	})
+})
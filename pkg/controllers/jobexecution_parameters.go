@@ -0,0 +1,98 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+)
+
+// Returns the JobExecution's parameters, with the values read from ConfigMaps
+// and Secrets, and the default of the JobTemplate's parameters it doesn't
+// set. An optional value that doesn't exist leaves its parameter unset.
+func (r *JobExecutionReconciler) getJobExecutionParameters(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) ([]dispatcherv1.ExecutionParameter, error) {
+	parameters := make([]dispatcherv1.ExecutionParameter, 0, len(jobExecution.Spec.Parameters))
+	for _, parameter := range jobExecution.Spec.Parameters {
+		value := parameter.Value
+		if parameter.ValueFrom != nil {
+			var found bool
+			var err error
+			value, found, err = r.getParameterValue(ctx, jobExecution.Namespace, parameter.ValueFrom)
+			if err != nil {
+				return nil, fmt.Errorf("reading parameter %s: %w", parameter.Name, err)
+			}
+			if !found {
+				continue
+			}
+		}
+		parameters = append(parameters, dispatcherv1.ExecutionParameter{Name: parameter.Name, Value: value})
+	}
+	return template.GetParameters(jobTemplate.Spec.Parameters, parameters)
+}
+
+// Reads the value of a parameter from its source. Returns false if the source
+// is optional and doesn't exist.
+func (r *JobExecutionReconciler) getParameterValue(ctx context.Context, namespace string, source *dispatcherv1.ExecutionParameterSource) (string, bool, error) {
+	var name, key, kind string
+	var optional *bool
+	var obj client.Object
+	switch {
+	case source.SecretKeyRef != nil:
+		name, key, kind, optional = source.SecretKeyRef.Name, source.SecretKeyRef.Key, "Secret", source.SecretKeyRef.Optional
+		obj = new(corev1.Secret)
+	case source.ConfigMapKeyRef != nil:
+		name, key, kind, optional = source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key, "ConfigMap", source.ConfigMapKeyRef.Optional
+		obj = new(corev1.ConfigMap)
+	default:
+		return "", false, errors.New("parameter doesn't have a source")
+	}
+
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj); err != nil {
+		if apierrors.IsNotFound(err) && ptr.Deref(optional, false) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	var value string
+	var ok bool
+	switch obj := obj.(type) {
+	case *corev1.Secret:
+		var data []byte
+		data, ok = obj.Data[key]
+		value = string(data)
+	case *corev1.ConfigMap:
+		value, ok = obj.Data[key]
+	}
+	if !ok {
+		if ptr.Deref(optional, false) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("key %q not found in %s %s", key, kind, name)
+	}
+	return value, true, nil
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution parameters", func() {
+	const namespace = "default"
+	ctx := context.Background()
+
+	var (
+		reconciler  *JobExecutionReconciler
+		jobTemplate *dispatcherv1beta1.JobTemplate
+	)
+
+	BeforeEach(func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: namespace},
+			Data:       map[string]string{"region": "us-east-1"},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: namespace},
+			Data:       map[string][]byte{"token": []byte("s3cr3t")},
+		}
+		reconciler = newFakeReconciler(configMap, secret)
+		jobTemplate = &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: namespace},
+			Spec: dispatcherv1beta1.JobTemplateSpec{
+				Parameters: []dispatcherv1beta1.JobTemplateParameter{
+					{Name: "region"},
+					{Name: "token"},
+					{Name: "environment", Default: "staging"},
+				},
+			},
+		}
+	})
+
+	newJobExecution := func(parameters ...dispatcherv1.ExecutionParameter) *dispatcherv1.JobExecution {
+		return &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "deploy-abcde", Namespace: namespace},
+			Spec: dispatcherv1.JobExecutionSpec{
+				JobTemplateName: "deploy",
+				Parameters:      parameters,
+			},
+		}
+	}
+
+	It("reads the values from ConfigMaps and Secrets, and applies the defaults", func() {
+		je := newJobExecution(
+			dispatcherv1.ExecutionParameter{
+				Name: "region",
+				ValueFrom: &dispatcherv1.ExecutionParameterSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "settings"},
+						Key:                  "region",
+					},
+				},
+			},
+			dispatcherv1.ExecutionParameter{
+				Name: "token",
+				ValueFrom: &dispatcherv1.ExecutionParameterSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"},
+						Key:                  "token",
+					},
+				},
+			},
+		)
+
+		parameters, err := reconciler.getJobExecutionParameters(ctx, je, jobTemplate)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(parameters).To(ConsistOf(
+			dispatcherv1.ExecutionParameter{Name: "region", Value: "us-east-1"},
+			dispatcherv1.ExecutionParameter{Name: "token", Value: "s3cr3t"},
+			dispatcherv1.ExecutionParameter{Name: "environment", Value: "staging"},
+		))
+	})
+
+	It("skips the optional values that don't exist", func() {
+		jobTemplate.Spec.Parameters = []dispatcherv1beta1.JobTemplateParameter{
+			{Name: "region", Default: "us-west-2"},
+		}
+		je := newJobExecution(dispatcherv1.ExecutionParameter{
+			Name: "region",
+			ValueFrom: &dispatcherv1.ExecutionParameterSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "missing"},
+					Key:                  "region",
+					Optional:             ptr.To(true),
+				},
+			},
+		})
+
+		parameters, err := reconciler.getJobExecutionParameters(ctx, je, jobTemplate)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(parameters).To(ConsistOf(dispatcherv1.ExecutionParameter{Name: "region", Value: "us-west-2"}))
+	})
+
+	It("fails if a required value doesn't exist", func() {
+		je := newJobExecution(dispatcherv1.ExecutionParameter{
+			Name: "token",
+			ValueFrom: &dispatcherv1.ExecutionParameterSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"},
+					Key:                  "password",
+				},
+			},
+		})
+
+		_, err := reconciler.getJobExecutionParameters(ctx, je, jobTemplate)
+		Expect(err).To(MatchError(ContainSubstring(`key "password" not found in Secret credentials`)))
+	})
+})
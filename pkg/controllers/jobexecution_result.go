@@ -0,0 +1,133 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+const defaultJobResultMaxBytes = 1024
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create
+
+// Copies the result published by the completed Job into the JobExecution's
+// status. Results larger than the maximum size are truncated, or stored in a
+// ConfigMap.
+func (r *JobExecutionReconciler) captureJobResult(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate, job *batchv1.Job) error {
+	config := jobTemplate.Spec.Result
+	if config == nil {
+		return nil
+	}
+
+	pods, err := r.listJobPods(ctx, job)
+	if err != nil {
+		return err
+	}
+	result, ok := getJobResult(config, pods)
+	if !ok {
+		return nil
+	}
+
+	maxBytes := int(ptr.Deref(config.MaxBytes, defaultJobResultMaxBytes))
+	if len(result) <= maxBytes {
+		jobExecution.Status.Result = result
+		return nil
+	}
+	if !config.SpillToConfigMap {
+		// Drop any rune that was cut in half.
+		jobExecution.Status.Result = strings.ToValidUTF8(result[:maxBytes], "")
+		jobExecution.Status.ResultTruncated = true
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobExecution.Name + "-result",
+			Namespace: jobExecution.Namespace,
+			Labels: map[string]string{
+				"controller-uid":     string(jobExecution.GetUID()),
+				"job-execution-name": jobExecution.Name,
+			},
+		},
+		Data: map[string]string{dispatcherv1.JobResultConfigMapKey: result},
+	}
+	if err := ctrl.SetControllerReference(jobExecution, configMap, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, configMap); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	jobExecution.Status.ResultConfigMap = &corev1.LocalObjectReference{Name: configMap.Name}
+	return nil
+}
+
+// Returns the JobExecution's result, reading it from the ResultConfigMap when
+// it was spilled.
+func getJobExecutionResult(ctx context.Context, c client.Reader, jobExecution *dispatcherv1.JobExecution) (string, error) {
+	configMapRef := jobExecution.Status.ResultConfigMap
+	if configMapRef == nil {
+		return jobExecution.Status.Result, nil
+	}
+	configMap := new(corev1.ConfigMap)
+	if err := c.Get(ctx, types.NamespacedName{Name: configMapRef.Name, Namespace: jobExecution.Namespace}, configMap); err != nil {
+		return "", err
+	}
+	return configMap.Data[dispatcherv1.JobResultConfigMapKey], nil
+}
+
+// Returns the termination message of the result's container, from the most
+// recent succeeded pod.
+func getJobResult(config *dispatcherv1beta1.JobResult, pods []corev1.Pod) (string, bool) {
+	var latest *corev1.Pod
+	for i := range pods {
+		if pods[i].Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		if latest == nil || latest.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			latest = &pods[i]
+		}
+	}
+	if latest == nil {
+		return "", false
+	}
+
+	containerName := config.ContainerName
+	if len(containerName) == 0 && len(latest.Spec.Containers) > 0 {
+		containerName = latest.Spec.Containers[0].Name
+	}
+	i := slices.IndexFunc(latest.Status.ContainerStatuses, func(status corev1.ContainerStatus) bool {
+		return status.Name == containerName
+	})
+	if i < 0 || latest.Status.ContainerStatuses[i].State.Terminated == nil {
+		return "", false
+	}
+	return latest.Status.ContainerStatuses[i].State.Terminated.Message, true
+}
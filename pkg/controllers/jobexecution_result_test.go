@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution results", func() {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	pod := func(phase corev1.PodPhase, offset time.Duration, messages map[string]string) corev1.Pod {
+		p := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(created.Add(offset)),
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "main"}, {Name: "sidecar"}},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+		for _, name := range []string{"sidecar", "main"} {
+			p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name: name,
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Message: messages[name]},
+				},
+			})
+		}
+		return p
+	}
+
+	It("reads the result from the most recent succeeded pod", func() {
+		pods := []corev1.Pod{
+			pod(corev1.PodSucceeded, 0, map[string]string{"main": "old"}),
+			pod(corev1.PodSucceeded, time.Minute, map[string]string{"main": "new", "sidecar": "other"}),
+			pod(corev1.PodFailed, time.Hour, map[string]string{"main": "failed"}),
+		}
+
+		result, ok := getJobResult(&dispatcherv1beta1.JobResult{}, pods)
+		Expect(ok).To(BeTrue())
+		Expect(result).To(Equal("new"))
+
+		result, ok = getJobResult(&dispatcherv1beta1.JobResult{ContainerName: "sidecar"}, pods)
+		Expect(ok).To(BeTrue())
+		Expect(result).To(Equal("other"))
+	})
+
+	It("doesn't have a result without succeeded pods", func() {
+		_, ok := getJobResult(&dispatcherv1beta1.JobResult{}, []corev1.Pod{
+			pod(corev1.PodFailed, 0, map[string]string{"main": "failed"}),
+		})
+		Expect(ok).To(BeFalse())
+
+		_, ok = getJobResult(&dispatcherv1beta1.JobResult{ContainerName: "missing"}, []corev1.Pod{
+			pod(corev1.PodSucceeded, 0, nil),
+		})
+		Expect(ok).To(BeFalse())
+	})
+})
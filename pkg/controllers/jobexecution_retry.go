@@ -0,0 +1,171 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ref "k8s.io/client-go/tools/reference"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+const (
+	jobAttemptLabel = "job-execution-attempt"
+
+	defaultRetryBackoff    = 10 * time.Second
+	defaultRetryMaxBackoff = 10 * time.Minute
+)
+
+// Returns the RetryPolicy for the JobExecution, which takes precedence over
+// the one from the JobTemplate.
+func getRetryPolicy(jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) *dispatcherv1.RetryPolicy {
+	if jobExecution.Spec.RetryPolicy != nil {
+		return jobExecution.Spec.RetryPolicy
+	}
+	return (*dispatcherv1.RetryPolicy)(jobTemplate.Spec.RetryPolicy)
+}
+
+// Returns true if the failed Job has attempts left, and failed for a
+// retryable reason.
+func shouldRetryJob(policy *dispatcherv1.RetryPolicy, job *batchv1.Job) bool {
+	if policy == nil || int32(getJobAttempt(job)) >= policy.MaxAttempts {
+		return false
+	}
+	if len(policy.RetryableReasons) == 0 {
+		return true
+	}
+	condition := getJobStatusCondition(job, batchv1.JobFailed)
+	return condition != nil && slices.Contains(policy.RetryableReasons, condition.Reason)
+}
+
+// Returns the time to wait before creating the Job for the attempt following
+// the given one. It doubles for every attempt, up to the maximum backoff.
+func getRetryBackoff(policy *dispatcherv1.RetryPolicy, attempt int) time.Duration {
+	backoff, maxBackoff := defaultRetryBackoff, defaultRetryMaxBackoff
+	if policy.Backoff != nil {
+		backoff = policy.Backoff.Duration
+	}
+	if policy.MaxBackoff != nil {
+		maxBackoff = policy.MaxBackoff.Duration
+	}
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// Returns the attempt number from a Job created by a JobExecution. Jobs
+// created before retries existed don't have the label, and are the first
+// attempt.
+func getJobAttempt(job *batchv1.Job) int {
+	attempt, err := strconv.Atoi(job.Labels[jobAttemptLabel])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// Records the failed Job as a previous attempt, and creates the Job for the
+// next attempt once the backoff elapses.
+func (r *JobExecutionReconciler) retryJob(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate, job *batchv1.Job, policy *dispatcherv1.RetryPolicy) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	failedCondition := getJobStatusCondition(job, batchv1.JobFailed)
+	if !slices.ContainsFunc(jobExecution.Status.PreviousAttempts, func(a dispatcherv1.JobExecutionAttempt) bool {
+		return a.Job.UID == job.UID
+	}) {
+		jobRef, err := ref.GetReference(r.Scheme, job)
+		if err != nil {
+			log.Error(err, "Unable to make reference to job", "job", job)
+			return ctrl.Result{}, err
+		}
+		jobExecution.Status.PreviousAttempts = append(jobExecution.Status.PreviousAttempts, dispatcherv1.JobExecutionAttempt{
+			Job:        *jobRef,
+			Reason:     failedCondition.Reason,
+			Message:    failedCondition.Message,
+			FinishedAt: failedCondition.LastTransitionTime,
+		})
+		meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+			Type:    waitingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "RetryBackoff",
+			Message: fmt.Sprintf("Job failed on attempt %d of %d, waiting to retry", getJobAttempt(job), policy.MaxAttempts),
+		})
+		meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+			Type:    runningCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "RetryBackoff",
+			Message: "Job failed, waiting to retry",
+		})
+		r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "Retrying", "Job %s failed running, retrying", job.Name)
+
+		if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+			log.Error(err, "Failed to update JobExecution status when recording failed attempt")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if wait := getRetryBackoff(policy, getJobAttempt(job)) - time.Since(failedCondition.LastTransitionTime.Time); wait > 0 {
+		log.Info("Waiting to retry Job", "job", job.Name, "wait", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	createdJob, err := r.createJob(ctx, jobExecution, jobTemplate)
+	if err != nil {
+		log.Error(err, "Error generating Job")
+		return ctrl.Result{}, err
+	}
+
+	jobRef, err := ref.GetReference(r.Scheme, createdJob)
+	if err != nil {
+		log.Error(err, "Unable to make reference to job", "job", createdJob)
+		return ctrl.Result{}, err
+	}
+	jobExecution.Status.Job = *jobRef
+	jobExecution.Status.Attempts = int32(getJobAttempt(createdJob))
+	jobExecution.Status.Pods = nil
+	meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+		Type:    waitingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "JobCreated",
+		Message: fmt.Sprintf("Job created for attempt %d, waiting to be executed", getJobAttempt(createdJob)),
+	})
+
+	r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "Created", "Job %s created", createdJob.Name)
+	log.Info("Created Job to retry JobExecution, requeueing")
+	jobExecutionsRetriesTotal.Inc()
+
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to update JobExecution status when retrying job")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution retries", func() {
+	failedJob := func(attempt, reason string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{jobAttemptLabel: attempt},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{
+					Type:   batchv1.JobFailed,
+					Status: corev1.ConditionTrue,
+					Reason: reason,
+				}},
+			},
+		}
+	}
+
+	It("prefers the JobExecution's RetryPolicy", func() {
+		jePolicy := &dispatcherv1.RetryPolicy{MaxAttempts: 2}
+		jtPolicy := &dispatcherv1beta1.RetryPolicy{MaxAttempts: 3}
+		je := &dispatcherv1.JobExecution{}
+		jt := &dispatcherv1beta1.JobTemplate{}
+		jt.Spec.RetryPolicy = jtPolicy
+
+		Expect(getRetryPolicy(je, jt)).To(Equal((*dispatcherv1.RetryPolicy)(jtPolicy)))
+		je.Spec.RetryPolicy = jePolicy
+		Expect(getRetryPolicy(je, jt)).To(Equal(jePolicy))
+	})
+
+	It("retries until it reaches the maximum attempts", func() {
+		policy := &dispatcherv1.RetryPolicy{MaxAttempts: 3}
+
+		Expect(shouldRetryJob(nil, failedJob("1", "BackoffLimitExceeded"))).To(BeFalse())
+		Expect(shouldRetryJob(policy, failedJob("", "BackoffLimitExceeded"))).To(BeTrue())
+		Expect(shouldRetryJob(policy, failedJob("2", "BackoffLimitExceeded"))).To(BeTrue())
+		Expect(shouldRetryJob(policy, failedJob("3", "BackoffLimitExceeded"))).To(BeFalse())
+	})
+
+	It("only retries the retryable reasons", func() {
+		policy := &dispatcherv1.RetryPolicy{
+			MaxAttempts:      3,
+			RetryableReasons: []string{"DeadlineExceeded"},
+		}
+
+		Expect(shouldRetryJob(policy, failedJob("1", "DeadlineExceeded"))).To(BeTrue())
+		Expect(shouldRetryJob(policy, failedJob("1", "BackoffLimitExceeded"))).To(BeFalse())
+	})
+
+	It("backs off exponentially", func() {
+		policy := &dispatcherv1.RetryPolicy{
+			MaxAttempts: 10,
+			Backoff:     &metav1.Duration{Duration: time.Second},
+			MaxBackoff:  &metav1.Duration{Duration: 5 * time.Second},
+		}
+
+		Expect(getRetryBackoff(policy, 1)).To(Equal(time.Second))
+		Expect(getRetryBackoff(policy, 2)).To(Equal(2 * time.Second))
+		Expect(getRetryBackoff(policy, 3)).To(Equal(4 * time.Second))
+		Expect(getRetryBackoff(policy, 4)).To(Equal(5 * time.Second))
+		Expect(getRetryBackoff(&dispatcherv1.RetryPolicy{}, 1)).To(Equal(defaultRetryBackoff))
+	})
+})
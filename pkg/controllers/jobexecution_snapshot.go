@@ -0,0 +1,290 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create
+
+// Returns the JobTemplate snapshotted for the JobExecution. The first time,
+// it resolves the JobTemplate, stores it in a ControllerRevision owned by the
+// JobExecution, and records it in the JobExecution's status. Fanned out items
+// share the snapshot of their JobExecution.
+func (r *JobExecutionReconciler) getJobTemplateSnapshot(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*dispatcherv1beta1.JobTemplate, error) {
+	if jobExecution.Status.JobTemplate != nil {
+		return r.getJobTemplateRevision(ctx, jobExecution.Namespace, jobExecution.Status.JobTemplate)
+	}
+
+	snapshot, jt, err := r.getFanOutJobTemplateSnapshot(ctx, jobExecution)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		if snapshot, jt, err = r.createJobTemplateSnapshot(ctx, jobExecution); err != nil {
+			return nil, err
+		}
+	}
+
+	jobExecution.Status.JobTemplate = snapshot
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		return nil, err
+	}
+	return jt, nil
+}
+
+// Returns the snapshot of the JobExecution that fanned out the item, if it
+// has one.
+func (r *JobExecutionReconciler) getFanOutJobTemplateSnapshot(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*dispatcherv1.JobTemplateSnapshot, *dispatcherv1beta1.JobTemplate, error) {
+	if _, ok := jobExecution.Labels[dispatcherv1.JobExecutionFanOutLabel]; !ok {
+		return nil, nil, nil
+	}
+	owner := metav1.GetControllerOf(jobExecution)
+	if owner == nil || owner.Kind != "JobExecution" {
+		return nil, nil, nil
+	}
+
+	parent := new(dispatcherv1.JobExecution)
+	if err := r.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: jobExecution.Namespace}, parent); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if parent.Status.JobTemplate == nil {
+		return nil, nil, nil
+	}
+
+	jt, err := r.getJobTemplateRevision(ctx, jobExecution.Namespace, parent.Status.JobTemplate)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent.Status.JobTemplate.DeepCopy(), jt, nil
+}
+
+// Resolves the JobExecution's JobTemplate, and stores it in a
+// ControllerRevision. The revision's name is deterministic, so an existing
+// one is reused if the status update failed after creating it.
+func (r *JobExecutionReconciler) createJobTemplateSnapshot(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*dispatcherv1.JobTemplateSnapshot, *dispatcherv1beta1.JobTemplate, error) {
+	source, err := r.fetchRecordedJobTemplate(ctx, jobExecution)
+	if err != nil {
+		return nil, nil, err
+	}
+	jt, err := resolveJobTemplate(ctx, r, jobExecution.Spec.JobTemplateKind, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(newJobTemplateSnapshotData(jt))
+	if err != nil {
+		return nil, nil, err
+	}
+	revision := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getChildJobExecutionName(jobExecution.Name, "template"),
+			Namespace: jobExecution.Namespace,
+			Labels: map[string]string{
+				"job-execution-name": jobExecution.Name,
+			},
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: 1,
+	}
+	if err := ctrl.SetControllerReference(jobExecution, revision, r.Scheme); err != nil {
+		return nil, nil, err
+	}
+	if err := r.Create(ctx, revision); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, nil, err
+		}
+		if err := r.Get(ctx, types.NamespacedName{Name: revision.Name, Namespace: revision.Namespace}, revision); err != nil {
+			return nil, nil, err
+		}
+		if jt, err = decodeJobTemplateRevision(revision); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	snapshot := &dispatcherv1.JobTemplateSnapshot{
+		Name:            source.Name,
+		ResourceVersion: source.ResourceVersion,
+		Generation:      source.Generation,
+		Revision:        revision.Name,
+		Hash:            hashJobTemplateRevision(revision),
+	}
+	return snapshot, jt, nil
+}
+
+// Returns the JobExecution's JobTemplate at the version recorded when it was
+// created. If the JobTemplate changed since, it's read at that version from the
+// API server, which fails once the version is compacted. The bases are
+// resolved as they are.
+func (r *JobExecutionReconciler) fetchRecordedJobTemplate(ctx context.Context, jobExecution *dispatcherv1.JobExecution) (*dispatcherv1beta1.JobTemplate, error) {
+	kind, name, namespace := jobExecution.Spec.JobTemplateKind, jobExecution.Spec.JobTemplateName, jobExecution.Namespace
+	jt, err := fetchJobTemplate(ctx, r, kind, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	version := jobExecution.Spec.JobTemplateVersion
+	if version == nil || jt.Generation == version.Generation {
+		return jt, nil
+	}
+
+	changed := &jobTemplateChangedError{name: name, recorded: version.Generation, current: jt.Generation}
+	if r.APIReader == nil {
+		return nil, changed
+	}
+	recorded, err := fetchJobTemplateAt(ctx, r.APIReader, kind, name, namespace, version.ResourceVersion)
+	if apierrors.IsGone(err) || apierrors.IsNotFound(err) || apierrors.IsResourceExpired(err) {
+		return nil, changed
+	} else if err != nil {
+		return nil, err
+	}
+	if recorded.Generation != version.Generation {
+		return nil, changed
+	}
+	return recorded, nil
+}
+
+// Returns the JobTemplate or ClusterJobTemplate exactly at the resource
+// version, as a JobTemplate in the namespace.
+func fetchJobTemplateAt(ctx context.Context, c client.Reader, kind, name, namespace, resourceVersion string) (*dispatcherv1beta1.JobTemplate, error) {
+	opts := []client.ListOption{
+		client.MatchingFields{"metadata.name": name},
+		&client.ListOptions{Raw: &metav1.ListOptions{
+			ResourceVersion:      resourceVersion,
+			ResourceVersionMatch: metav1.ResourceVersionMatchExact,
+		}},
+	}
+	if kind == dispatcherv1.ClusterJobTemplateKind {
+		list := new(dispatcherv1beta1.ClusterJobTemplateList)
+		if err := c.List(ctx, list, opts...); err != nil {
+			return nil, err
+		}
+		if len(list.Items) == 0 {
+			return nil, apierrors.NewNotFound(dispatcherv1beta1.GroupVersion.WithResource("clusterjobtemplates").GroupResource(), name)
+		}
+		jt := &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: list.Items[0].ObjectMeta,
+			Spec:       list.Items[0].Spec.JobTemplateSpec,
+		}
+		jt.Namespace = namespace
+		return jt, nil
+	}
+
+	list := new(dispatcherv1beta1.JobTemplateList)
+	if err := c.List(ctx, list, append(opts, client.InNamespace(namespace))...); err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, apierrors.NewNotFound(dispatcherv1beta1.GroupVersion.WithResource("jobtemplates").GroupResource(), name)
+	}
+	return &list.Items[0], nil
+}
+
+// The error when the JobExecution's JobTemplate changed since it was created,
+// and the recorded version isn't available anymore.
+type jobTemplateChangedError struct {
+	name              string
+	recorded, current int64
+}
+
+func (e *jobTemplateChangedError) Error() string {
+	return fmt.Sprintf("JobTemplate %s changed from generation %d to %d since the JobExecution was created", e.name, e.recorded, e.current)
+}
+
+// Returns the error if it's a jobTemplateChangedError, or nil otherwise.
+func getJobTemplateChangedError(err error) *jobTemplateChangedError {
+	var changed *jobTemplateChangedError
+	if errors.As(err, &changed) {
+		return changed
+	}
+	return nil
+}
+
+// Fails the JobExecution whose JobTemplate changed since it was created,
+// instead of running it with a JobTemplate that the requester didn't see.
+func (r *JobExecutionReconciler) failChangedJobTemplate(ctx context.Context, jobExecution *dispatcherv1.JobExecution, changed *jobTemplateChangedError) (ctrl.Result, error) {
+	setStoppedConditions(jobExecution, "JobTemplateChanged", changed.Error())
+	r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "JobTemplateChanged", "%s", changed.Error())
+	jobExecutionsFailuresTotal.Inc()
+
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		ctrllog.FromContext(ctx).Error(err, "Failed to update JobExecution status when its JobTemplate changed")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// Returns the JobTemplate stored in the snapshot's ControllerRevision.
+func (r *JobExecutionReconciler) getJobTemplateRevision(ctx context.Context, namespace string, snapshot *dispatcherv1.JobTemplateSnapshot) (*dispatcherv1beta1.JobTemplate, error) {
+	revision := new(appsv1.ControllerRevision)
+	if err := r.Get(ctx, types.NamespacedName{Name: snapshot.Revision, Namespace: namespace}, revision); err != nil {
+		return nil, fmt.Errorf("failed fetching JobTemplate snapshot %s: %w", snapshot.Revision, err)
+	}
+	if hash := hashJobTemplateRevision(revision); hash != snapshot.Hash {
+		return nil, fmt.Errorf("JobTemplate snapshot %s has hash %s, expected %s", snapshot.Revision, hash, snapshot.Hash)
+	}
+	return decodeJobTemplateRevision(revision)
+}
+
+// Returns the parts of the JobTemplate stored in its snapshot.
+func newJobTemplateSnapshotData(jobTemplate *dispatcherv1beta1.JobTemplate) *dispatcherv1beta1.JobTemplate {
+	return &dispatcherv1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        jobTemplate.Name,
+			Namespace:   jobTemplate.Namespace,
+			Labels:      jobTemplate.Labels,
+			Annotations: jobTemplate.Annotations,
+		},
+		Spec: jobTemplate.Spec,
+	}
+}
+
+// Decodes the JobTemplate stored in a ControllerRevision.
+func decodeJobTemplateRevision(revision *appsv1.ControllerRevision) (*dispatcherv1beta1.JobTemplate, error) {
+	jt := new(dispatcherv1beta1.JobTemplate)
+	if err := json.Unmarshal(revision.Data.Raw, jt); err != nil {
+		return nil, fmt.Errorf("failed decoding JobTemplate snapshot %s: %w", revision.Name, err)
+	}
+	return jt, nil
+}
+
+// Returns the SHA-256 hash of the JobTemplate stored in a ControllerRevision.
+func hashJobTemplateRevision(revision *appsv1.ControllerRevision) string {
+	sum := sha256.Sum256(revision.Data.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution JobTemplate snapshots", func() {
+	const namespace = "default"
+	ctx := context.Background()
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "report-abcde", Namespace: namespace}}
+
+	var (
+		k8sClient    client.Client
+		reconciler   *JobExecutionReconciler
+		jobTemplate  *dispatcherv1beta1.JobTemplate
+		jobExecution *dispatcherv1.JobExecution
+	)
+
+	BeforeEach(func() {
+		jobTemplate = &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: namespace, Generation: 3},
+			Spec: dispatcherv1beta1.JobTemplateSpec{
+				Timeout: &metav1.Duration{Duration: time.Minute},
+				JobTemplateSpec: batchv1.JobTemplateSpec{
+					Spec: batchv1.JobSpec{
+						Template: corev1.PodTemplateSpec{
+							Spec: corev1.PodSpec{
+								Containers:    []corev1.Container{{Name: "report", Image: "busybox:1.36"}},
+								RestartPolicy: corev1.RestartPolicyNever,
+							},
+						},
+					},
+				},
+			},
+		}
+		jobExecution = &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-abcde", Namespace: namespace, UID: "report-abcde", CreationTimestamp: metav1.Now()},
+			Spec:       dispatcherv1.JobExecutionSpec{JobTemplateName: "report"},
+		}
+		reconciler = newFakeReconciler(jobTemplate, jobExecution)
+		k8sClient = reconciler.Client
+	})
+
+	It("builds from the snapshot after the JobTemplate changes", func() {
+		jt, err := reconciler.getJobTemplate(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(jt.Spec.Timeout).To(Equal(&metav1.Duration{Duration: time.Minute}))
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(jobExecution), je)).To(Succeed())
+		snapshot := je.Status.JobTemplate
+		Expect(snapshot).To(Not(BeNil()))
+		Expect(snapshot.Name).To(Equal("report"))
+		Expect(snapshot.Generation).To(Equal(int64(3)))
+		Expect(snapshot.ResourceVersion).To(Not(BeEmpty()))
+		Expect(snapshot.Hash).To(HaveLen(64))
+
+		revision := new(appsv1.ControllerRevision)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: snapshot.Revision, Namespace: namespace}, revision)).To(Succeed())
+		Expect(metav1.IsControlledBy(revision, jobExecution)).To(BeTrue())
+
+		By("Changing the JobTemplate")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(jobTemplate), jobTemplate)).To(Succeed())
+		jobTemplate.Spec.Timeout = &metav1.Duration{Duration: time.Hour}
+		Expect(k8sClient.Update(ctx, jobTemplate)).To(Succeed())
+
+		jt, err = reconciler.getJobTemplate(ctx, je)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(jt.Spec.Timeout).To(Equal(&metav1.Duration{Duration: time.Minute}))
+
+		By("Building after the JobTemplate is deleted")
+		Expect(k8sClient.Delete(ctx, jobTemplate)).To(Succeed())
+		_, err = reconciler.getJobTemplate(ctx, je)
+		Expect(err).To(Not(HaveOccurred()))
+	})
+
+	It("reuses the revision when the status wasn't recorded", func() {
+		_, err := reconciler.getJobTemplate(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+		hash := jobExecution.Status.JobTemplate.Hash
+
+		jobTemplate.Spec.Timeout = &metav1.Duration{Duration: time.Hour}
+		Expect(k8sClient.Update(ctx, jobTemplate)).To(Succeed())
+		jobExecution.Status.JobTemplate = nil
+		jt, err := reconciler.getJobTemplate(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(jt.Spec.Timeout).To(Equal(&metav1.Duration{Duration: time.Minute}))
+		Expect(jobExecution.Status.JobTemplate.Hash).To(Equal(hash))
+	})
+
+	It("fails when the revision doesn't match its hash", func() {
+		_, err := reconciler.getJobTemplate(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+
+		jobExecution.Status.JobTemplate.Hash = "modified"
+		_, err = reconciler.getJobTemplate(ctx, jobExecution)
+		Expect(err).To(MatchError(ContainSubstring("expected modified")))
+	})
+
+	It("shares the snapshot with fanned out items", func() {
+		_, err := reconciler.getJobTemplate(ctx, jobExecution)
+		Expect(err).To(Not(HaveOccurred()))
+
+		item, err := reconciler.newFanOutItemJobExecution(jobExecution, "a", 0)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(k8sClient.Create(ctx, item)).To(Succeed())
+		_, err = reconciler.getJobTemplate(ctx, item)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(item.Status.JobTemplate).To(Equal(jobExecution.Status.JobTemplate))
+
+		revisions := new(appsv1.ControllerRevisionList)
+		Expect(k8sClient.List(ctx, revisions, client.InNamespace(namespace))).To(Succeed())
+		Expect(revisions.Items).To(HaveLen(1))
+	})
+
+	// Records the JobTemplate's current version in the JobExecution, as when
+	// it's created, and then changes the JobTemplate's image.
+	recordAndChangeJobTemplate := func() *dispatcherv1beta1.JobTemplate {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(jobTemplate), jobTemplate)).To(Succeed())
+		recorded := jobTemplate.DeepCopy()
+		jobExecution.Spec.JobTemplateVersion = &dispatcherv1.JobTemplateVersion{
+			ResourceVersion: recorded.ResourceVersion,
+			Generation:      recorded.Generation,
+		}
+		Expect(k8sClient.Update(ctx, jobExecution)).To(Succeed())
+
+		jobTemplate.Spec.JobTemplateSpec.Spec.Template.Spec.Containers[0].Image = "busybox:1.37"
+		jobTemplate.Generation++
+		Expect(k8sClient.Update(ctx, jobTemplate)).To(Succeed())
+		return recorded
+	}
+
+	It("builds from the version recorded at creation when the JobTemplate changes before the first reconcile", func() {
+		recorded := recordAndChangeJobTemplate()
+		// Serves the JobTemplate at the recorded version, as the API server
+		// does until it's compacted.
+		reconciler.APIReader = fake.NewClientBuilder().
+			WithScheme(reconciler.Scheme).
+			WithObjects(recorded).
+			WithIndex(&dispatcherv1beta1.JobTemplate{}, "metadata.name", func(obj client.Object) []string {
+				return []string{obj.GetName()}
+			}).
+			Build()
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		jobs := new(batchv1.JobList)
+		Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace))).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+		Expect(jobs.Items[0].Spec.Template.Spec.Containers[0].Image).To(Equal("busybox:1.36"))
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		Expect(je.Status.JobTemplate.Generation).To(Equal(recorded.Generation))
+	})
+
+	It("fails when the JobTemplate changed and the recorded version isn't available", func() {
+		recordAndChangeJobTemplate()
+		reconciler.APIReader = fake.NewClientBuilder().
+			WithScheme(reconciler.Scheme).
+			WithInterceptorFuncs(interceptor.Funcs{
+				List: func(context.Context, client.WithWatch, client.ObjectList, ...client.ListOption) error {
+					return apierrors.NewResourceExpired("too old resource version")
+				},
+			}).
+			Build()
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		jobs := new(batchv1.JobList)
+		Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace))).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, je)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(je.Status.Conditions, succeededCondition)).To(BeTrue())
+		Expect(meta.FindStatusCondition(je.Status.Conditions, succeededCondition).Reason).To(Equal("JobTemplateChanged"))
+		Expect(je.Status.JobTemplate).To(BeNil())
+	})
+})
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+// The maximum number of pods recorded in the JobExecution's status.
+const maxJobExecutionPods = 10
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// Updates the JobExecution's status with the timing of its Job, and the
+// outcome of the Job's pods.
+func (r *JobExecutionReconciler) setJobExecutionStatusFromJob(ctx context.Context, jobExecution *dispatcherv1.JobExecution, job *batchv1.Job) error {
+	jobExecution.Status.Attempts = int32(getJobAttempt(job))
+	if jobExecution.Status.StartTime == nil && job.Status.StartTime != nil {
+		jobExecution.Status.StartTime = job.Status.StartTime.DeepCopy()
+	}
+
+	jobPods, err := r.listJobPods(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	pods, terminationMessage := getJobExecutionPods(jobPods)
+	jobExecution.Status.Pods = pods
+	if len(terminationMessage) > 0 {
+		jobExecution.Status.TerminationMessage = terminationMessage
+	}
+	return nil
+}
+
+// Updates the JobExecution's status, with the phase matching its conditions.
+func (r *JobExecutionReconciler) updateJobExecutionStatus(ctx context.Context, jobExecution *dispatcherv1.JobExecution) error {
+	jobExecution.Status.Phase = dispatcherv1.GetJobExecutionPhase(jobExecution.Status.Conditions)
+	return r.Status().Update(ctx, jobExecution)
+}
+
+// Returns the pods created by the Job.
+func (r *JobExecutionReconciler) listJobPods(ctx context.Context, job *batchv1.Job) ([]corev1.Pod, error) {
+	podList := new(corev1.PodList)
+	if err := r.List(
+		ctx,
+		podList,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.ControllerUidLabel: string(job.UID)},
+	); err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// Sets the JobExecution's completion time, unless it's already set. A zero
+// time means the Job didn't record when it finished, and uses the current time.
+func setJobExecutionCompletionTime(jobExecution *dispatcherv1.JobExecution, completionTime metav1.Time) {
+	if jobExecution.Status.CompletionTime != nil {
+		return
+	}
+	if completionTime.IsZero() {
+		completionTime = metav1.Now()
+	}
+	jobExecution.Status.CompletionTime = &completionTime
+}
+
+// Returns the status of the most recent pods, and the termination message of
+// the last container that failed.
+func getJobExecutionPods(pods []corev1.Pod) ([]dispatcherv1.JobExecutionPod, string) {
+	pods = slices.Clone(pods)
+	slices.SortStableFunc(pods, func(a, b corev1.Pod) int {
+		return b.CreationTimestamp.Compare(a.CreationTimestamp.Time)
+	})
+
+	var terminationMessage string
+	var failedAt *corev1.ContainerStateTerminated
+	result := make([]dispatcherv1.JobExecutionPod, 0, min(len(pods), maxJobExecutionPods))
+	for i, pod := range pods {
+		jePod := dispatcherv1.JobExecutionPod{
+			Name:  pod.Name,
+			Phase: pod.Status.Phase,
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated == nil {
+				continue
+			}
+			jePod.Containers = append(jePod.Containers, dispatcherv1.JobExecutionContainer{
+				Name:     status.Name,
+				ExitCode: terminated.ExitCode,
+				Reason:   terminated.Reason,
+			})
+			if terminated.ExitCode != 0 && (failedAt == nil || failedAt.FinishedAt.Before(&terminated.FinishedAt)) {
+				failedAt = terminated
+				terminationMessage = terminated.Message
+			}
+		}
+		if i < maxJobExecutionPods {
+			result = append(result, jePod)
+		}
+	}
+	return result, terminationMessage
+}
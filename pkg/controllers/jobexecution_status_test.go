@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+var _ = Describe("JobExecution status", func() {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	terminatedPod := func(name string, offset time.Duration, exitCode int32, message string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created.Add(offset)),
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "main",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode:   exitCode,
+							Reason:     "Error",
+							Message:    message,
+							FinishedAt: metav1.NewTime(created.Add(offset + time.Minute)),
+						},
+					},
+				}},
+			},
+		}
+	}
+
+	It("records the pods' containers, newest first", func() {
+		pods, terminationMessage := getJobExecutionPods([]corev1.Pod{
+			terminatedPod("first", 0, 1, "first failure"),
+			terminatedPod("second", time.Minute, 2, "second failure"),
+			{ObjectMeta: metav1.ObjectMeta{Name: "pending", CreationTimestamp: metav1.NewTime(created.Add(time.Hour))}},
+		})
+
+		Expect(pods).To(Equal([]dispatcherv1.JobExecutionPod{
+			{Name: "pending"},
+			{Name: "second", Phase: corev1.PodFailed, Containers: []dispatcherv1.JobExecutionContainer{{Name: "main", ExitCode: 2, Reason: "Error"}}},
+			{Name: "first", Phase: corev1.PodFailed, Containers: []dispatcherv1.JobExecutionContainer{{Name: "main", ExitCode: 1, Reason: "Error"}}},
+		}))
+		Expect(terminationMessage).To(Equal("second failure"))
+	})
+
+	It("ignores the termination message of successful containers", func() {
+		_, terminationMessage := getJobExecutionPods([]corev1.Pod{
+			terminatedPod("success", 0, 0, "done"),
+		})
+		Expect(terminationMessage).To(BeEmpty())
+	})
+
+	It("limits the number of pods", func() {
+		var podList []corev1.Pod
+		for i := range maxJobExecutionPods + 5 {
+			podList = append(podList, terminatedPod(fmt.Sprintf("pod-%d", i), time.Duration(i)*time.Minute, 1, fmt.Sprintf("failure %d", i)))
+		}
+
+		pods, terminationMessage := getJobExecutionPods(podList)
+		Expect(pods).To(HaveLen(maxJobExecutionPods))
+		Expect(pods[0].Name).To(Equal(fmt.Sprintf("pod-%d", maxJobExecutionPods+4)))
+		Expect(terminationMessage).To(Equal(fmt.Sprintf("failure %d", maxJobExecutionPods+4)))
+	})
+})
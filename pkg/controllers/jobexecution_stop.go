@@ -0,0 +1,199 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+const defaultCancelGracePeriod = 30 * time.Second
+
+// Returns the time to wait for the Job of a cancelled or timed out
+// JobExecution to stop, before deleting it.
+func getCancelGracePeriod(jobExecution *dispatcherv1.JobExecution) time.Duration {
+	if jobExecution.Spec.CancelGracePeriodSeconds != nil {
+		return time.Duration(*jobExecution.Spec.CancelGracePeriodSeconds) * time.Second
+	}
+	return defaultCancelGracePeriod
+}
+
+// Returns the time when the JobExecution times out, using its Timeout or the
+// one from the JobTemplate, counting from its creation or its NotBefore time,
+// whichever is later. Returns nil if there is no timeout. The
+// JobTemplate's Timeout applies to every item of a fanned out JobExecution, and
+// not to the JobExecution itself.
+func getJobExecutionDeadline(jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate) *time.Time {
+	timeout := jobTemplate.Spec.Timeout
+	if isFanOutJobExecution(jobExecution) {
+		timeout = nil
+	}
+	if jobExecution.Spec.Timeout != nil {
+		timeout = jobExecution.Spec.Timeout
+	}
+	if timeout == nil {
+		return nil
+	}
+	start := jobExecution.CreationTimestamp.Time
+	if notBefore := jobExecution.Spec.NotBefore; notBefore != nil && notBefore.After(start) {
+		start = notBefore.Time
+	}
+	deadline := start.Add(timeout.Duration)
+	return &deadline
+}
+
+// Returns the condition that stopped the JobExecution, if it was cancelled or
+// timed out.
+func getStoppedCondition(jobExecution *dispatcherv1.JobExecution) *metav1.Condition {
+	for _, conditionType := range []string{cancelledCondition, timedOutCondition} {
+		if condition := meta.FindStatusCondition(jobExecution.Status.Conditions, conditionType); condition != nil && condition.Status == metav1.ConditionTrue {
+			return condition
+		}
+	}
+	return nil
+}
+
+// Suspends the JobExecution's Job, if any, which terminates its running pods,
+// and marks the JobExecution as cancelled.
+func (r *JobExecutionReconciler) cancelJobExecution(ctx context.Context, jobExecution *dispatcherv1.JobExecution, job *batchv1.Job) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if err := r.suspendJob(ctx, job); err != nil {
+		log.Error(err, "Failed to suspend cancelled Job")
+		return ctrl.Result{}, err
+	}
+	if err := r.cancelFanOutItems(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to cancel the running items")
+		return ctrl.Result{}, err
+	}
+
+	meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+		Type:    cancelledCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Cancelled",
+		Message: "JobExecution was cancelled",
+	})
+	setStoppedConditions(jobExecution, "JobCancelled", "Job was cancelled")
+	r.Recorder.Eventf(jobExecution, corev1.EventTypeNormal, "Cancelled", "JobExecution %s was cancelled", jobExecution.Name)
+	jobExecutionsCancelledTotal.Inc()
+
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to update JobExecution status when cancelling")
+		return ctrl.Result{}, err
+	}
+
+	if job == nil || shouldDeliverCallback(jobExecution) {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{RequeueAfter: getCancelGracePeriod(jobExecution)}, nil
+}
+
+// Suspends the JobExecution's Job, if any, which terminates its running pods,
+// and marks the JobExecution as timed out.
+func (r *JobExecutionReconciler) timeOutJobExecution(ctx context.Context, jobExecution *dispatcherv1.JobExecution, jobTemplate *dispatcherv1beta1.JobTemplate, job *batchv1.Job) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if err := r.suspendJob(ctx, job); err != nil {
+		log.Error(err, "Failed to suspend timed out Job")
+		return ctrl.Result{}, err
+	}
+	if err := r.cancelFanOutItems(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to cancel the running items")
+		return ctrl.Result{}, err
+	}
+	if err := r.triggerFollowUps(ctx, jobExecution, jobTemplate.Spec.OnFailure, dispatcherv1beta1.ExecutionTimedOut); err != nil {
+		log.Error(err, "Failed to trigger the follow-up executions")
+		return ctrl.Result{}, err
+	}
+
+	meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+		Type:    timedOutCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "TimedOut",
+		Message: "JobExecution exceeded its timeout",
+	})
+	setStoppedConditions(jobExecution, "JobTimedOut", "Job didn't finish before the timeout")
+	r.Recorder.Eventf(jobExecution, corev1.EventTypeWarning, "TimedOut", "JobExecution %s exceeded its timeout", jobExecution.Name)
+	jobExecutionsTimedOutTotal.Inc()
+	r.notify(ctx, jobExecution, jobTemplate, dispatcherv1.NotificationEventFailed)
+
+	if err := r.updateJobExecutionStatus(ctx, jobExecution); err != nil {
+		log.Error(err, "Failed to update JobExecution status when timing out")
+		return ctrl.Result{}, err
+	}
+
+	if job == nil || shouldDeliverCallback(jobExecution) {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{RequeueAfter: getCancelGracePeriod(jobExecution)}, nil
+}
+
+// Sets the conditions and completion time of a JobExecution whose Job was
+// stopped before it finished.
+func setStoppedConditions(jobExecution *dispatcherv1.JobExecution, reason, message string) {
+	setJobExecutionCompletionTime(jobExecution, metav1.Now())
+	clearScheduledCondition(jobExecution, reason, message)
+	for _, conditionType := range []string{waitingCondition, runningCondition, succeededCondition} {
+		meta.SetStatusCondition(&jobExecution.Status.Conditions, metav1.Condition{
+			Type:    conditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		})
+	}
+}
+
+// Suspends the Job, unless it is nil or already suspended.
+func (r *JobExecutionReconciler) suspendJob(ctx context.Context, job *batchv1.Job) error {
+	if job == nil || ptr.Deref(job.Spec.Suspend, false) {
+		return nil
+	}
+	patch := client.MergeFrom(job.DeepCopy())
+	job.Spec.Suspend = ptr.To(true)
+	return r.Patch(ctx, job, patch)
+}
+
+// Deletes the Job of a cancelled or timed out JobExecution, once its grace
+// period elapses.
+func (r *JobExecutionReconciler) deleteStoppedJob(ctx context.Context, jobExecution *dispatcherv1.JobExecution, job *batchv1.Job, stopped *metav1.Condition) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if wait := getCancelGracePeriod(jobExecution) - time.Since(stopped.LastTransitionTime.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to delete stopped Job")
+		return ctrl.Result{}, err
+	}
+	log.Info("Deleted stopped Job", "job", job.Name, "reason", stopped.Reason)
+
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobExecution timeouts", func() {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("doesn't have a deadline without a timeout", func() {
+		je := &dispatcherv1.JobExecution{}
+		Expect(getJobExecutionDeadline(je, &dispatcherv1beta1.JobTemplate{})).To(BeNil())
+	})
+
+	It("counts the timeout from the JobExecution's creation", func() {
+		je := &dispatcherv1.JobExecution{}
+		je.CreationTimestamp = metav1.NewTime(created)
+		jt := &dispatcherv1beta1.JobTemplate{}
+		jt.Spec.Timeout = &metav1.Duration{Duration: time.Hour}
+
+		Expect(getJobExecutionDeadline(je, jt)).To(HaveValue(Equal(created.Add(time.Hour))))
+
+		je.Spec.Timeout = &metav1.Duration{Duration: time.Minute}
+		Expect(getJobExecutionDeadline(je, jt)).To(HaveValue(Equal(created.Add(time.Minute))))
+	})
+
+	It("counts the timeout from the JobExecution's NotBefore time", func() {
+		je := &dispatcherv1.JobExecution{}
+		je.CreationTimestamp = metav1.NewTime(created)
+		je.Spec.NotBefore = &metav1.Time{Time: created.Add(time.Hour)}
+		je.Spec.Timeout = &metav1.Duration{Duration: time.Minute}
+
+		Expect(getJobExecutionDeadline(je, &dispatcherv1beta1.JobTemplate{})).To(HaveValue(Equal(created.Add(time.Hour + time.Minute))))
+	})
+})
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// The maximum number of bases a JobTemplate can inherit from.
+const maxJobTemplateBases = 5
+
+// Gets the JobTemplate or ClusterJobTemplate with the name. A
+// ClusterJobTemplate is returned as a JobTemplate in the namespace, where its
+// Jobs are created, and has to allow the namespace.
+func fetchJobTemplate(ctx context.Context, c client.Reader, kind, name, namespace string) (*dispatcherv1beta1.JobTemplate, error) {
+	if kind == dispatcherv1.ClusterJobTemplateKind {
+		cjt := new(dispatcherv1beta1.ClusterJobTemplate)
+		if err := c.Get(ctx, types.NamespacedName{Name: name}, cjt); err != nil {
+			return nil, err
+		}
+		if !cjt.IsNamespaceAllowed(namespace) {
+			return nil, apierrors.NewForbidden(dispatcherv1beta1.GroupVersion.WithResource("clusterjobtemplates").GroupResource(), name, fmt.Errorf("namespace %s isn't allowed", namespace))
+		}
+		jt := &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: *cjt.ObjectMeta.DeepCopy(),
+			Spec:       *cjt.Spec.JobTemplateSpec.DeepCopy(),
+		}
+		jt.Namespace = namespace
+		return jt, nil
+	}
+
+	jt := new(dispatcherv1beta1.JobTemplate)
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, jt); err != nil {
+		return nil, err
+	}
+	return jt, nil
+}
+
+// Returns the effective JobTemplate, after inheriting from its bases.
+func resolveJobTemplate(ctx context.Context, c client.Reader, kind string, jobTemplate *dispatcherv1beta1.JobTemplate) (*dispatcherv1beta1.JobTemplate, error) {
+	chain := []*dispatcherv1beta1.JobTemplate{jobTemplate}
+	names := []string{jobTemplate.Name}
+	for current := jobTemplate; current.Spec.Base != nil; current = chain[len(chain)-1] {
+		name := current.Spec.Base.Name
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("JobTemplate %s has a cyclic base: %s -> %s", jobTemplate.Name, strings.Join(names, " -> "), name)
+		}
+		if len(chain) > maxJobTemplateBases {
+			return nil, fmt.Errorf("JobTemplate %s has more than %d bases", jobTemplate.Name, maxJobTemplateBases)
+		}
+
+		base, err := fetchJobTemplate(ctx, c, kind, name, jobTemplate.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed fetching base JobTemplate %s: %w", name, err)
+		}
+		chain = append(chain, base)
+		names = append(names, name)
+	}
+
+	// Apply the inheriting JobTemplates from the root base.
+	resolved := chain[len(chain)-1]
+	for i := len(chain) - 2; i >= 0; i-- {
+		var err error
+		if resolved, err = inheritJobTemplate(resolved, chain[i]); err != nil {
+			return nil, fmt.Errorf("failed inheriting JobTemplate %s: %w", chain[i].Name, err)
+		}
+	}
+	return resolved, nil
+}
+
+// Returns the JobTemplate with the spec inherited from its base: the base's
+// Job with the patch applied, and the fields it doesn't set.
+func inheritJobTemplate(base, jobTemplate *dispatcherv1beta1.JobTemplate) (*dispatcherv1beta1.JobTemplate, error) {
+	result := jobTemplate.DeepCopy()
+	spec, err := patchJobTemplateSpec(&base.Spec.JobTemplateSpec, jobTemplate.Spec.Base)
+	if err != nil {
+		return nil, err
+	}
+	result.Spec.JobTemplateSpec = *spec
+	result.Spec.Base = nil
+
+	if len(result.Spec.Parameters) == 0 {
+		result.Spec.Parameters = base.DeepCopy().Spec.Parameters
+	}
+	if len(result.Spec.OverridableFields) == 0 {
+		result.Spec.OverridableFields = slices.Clone(base.Spec.OverridableFields)
+	}
+	if result.Spec.RetryPolicy == nil {
+		result.Spec.RetryPolicy = base.Spec.RetryPolicy.DeepCopy()
+	}
+	if result.Spec.Timeout == nil {
+		result.Spec.Timeout = base.Spec.Timeout.DeepCopy()
+	}
+	if result.Spec.Result == nil {
+		result.Spec.Result = base.Spec.Result.DeepCopy()
+	}
+	if len(result.Spec.Notifications) == 0 {
+		result.Spec.Notifications = base.DeepCopy().Spec.Notifications
+	}
+	if len(result.Spec.OnSuccess) == 0 {
+		result.Spec.OnSuccess = base.DeepCopy().Spec.OnSuccess
+	}
+	if len(result.Spec.OnFailure) == 0 {
+		result.Spec.OnFailure = base.DeepCopy().Spec.OnFailure
+	}
+	return result, nil
+}
+
+// Applies the base's patch to the Job spec.
+func patchJobTemplateSpec(spec *batchv1.JobTemplateSpec, base *dispatcherv1beta1.JobTemplateBase) (*batchv1.JobTemplateSpec, error) {
+	if len(strings.TrimSpace(base.Patch)) == 0 {
+		return spec.DeepCopy(), nil
+	}
+
+	original, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := yaml.YAMLToJSON([]byte(base.Patch))
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	var patched []byte
+	switch base.PatchType {
+	case dispatcherv1beta1.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case dispatcherv1beta1.JSONPatchType:
+		var operations jsonpatch.Patch
+		if operations, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = operations.Apply(original)
+		}
+	default:
+		patched, err = strategicpatch.StrategicMergePatch(original, patch, batchv1.JobTemplateSpec{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed applying %s patch: %w", base.PatchType, err)
+	}
+
+	result := new(batchv1.JobTemplateSpec)
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
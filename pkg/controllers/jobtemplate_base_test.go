@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobTemplate bases", func() {
+	const namespace = "default"
+	ctx := context.Background()
+
+	var k8sClient client.Client
+
+	baseTemplate := func() *dispatcherv1beta1.JobTemplate {
+		return &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: namespace},
+			Spec: dispatcherv1beta1.JobTemplateSpec{
+				JobTemplateSpec: batchv1.JobTemplateSpec{
+					Spec: batchv1.JobSpec{
+						Template: corev1.PodTemplateSpec{
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{
+									{Name: "main", Image: "alpine:latest", Command: []string{"echo", "base"}},
+									{Name: "sidecar", Image: "proxy:latest"},
+								},
+								RestartPolicy: corev1.RestartPolicyNever,
+							},
+						},
+					},
+				},
+				Parameters:        []dispatcherv1beta1.JobTemplateParameter{{Name: "region", Default: "us-east-1"}},
+				OverridableFields: []dispatcherv1beta1.OverridableField{dispatcherv1beta1.OverridableFieldImageTag},
+				Timeout:           &metav1.Duration{Duration: time.Hour},
+			},
+		}
+	}
+
+	inheriting := func(name, base string, patchType dispatcherv1beta1.JobTemplatePatchType, patch string) *dispatcherv1beta1.JobTemplate {
+		return &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: dispatcherv1beta1.JobTemplateSpec{
+				Base: &dispatcherv1beta1.JobTemplateBase{Name: base, PatchType: patchType, Patch: patch},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		k8sClient = newFakeClient(
+			baseTemplate(),
+			inheriting("middle", "base", dispatcherv1beta1.StrategicMergePatchType, `
+spec:
+  template:
+    spec:
+      containers:
+      - name: main
+        command: [echo, middle]
+`),
+			inheriting("cycle-a", "cycle-b", "", ""),
+			inheriting("cycle-b", "cycle-a", "", ""),
+		)
+	})
+
+	It("merges the containers by name with a strategic merge patch", func() {
+		jt := inheriting("child", "middle", dispatcherv1beta1.StrategicMergePatchType, `{"spec": {"backoffLimit": 2}}`)
+		jt.Spec.Timeout = &metav1.Duration{Duration: time.Minute}
+
+		resolved, err := resolveJobTemplate(ctx, k8sClient, "", jt)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(resolved.Name).To(Equal("child"))
+		Expect(resolved.Spec.Base).To(BeNil())
+		Expect(resolved.Spec.Timeout.Duration).To(Equal(time.Minute))
+		Expect(*resolved.Spec.JobTemplateSpec.Spec.BackoffLimit).To(Equal(int32(2)))
+
+		containers := resolved.Spec.JobTemplateSpec.Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(2))
+		Expect(containers[0].Image).To(Equal("alpine:latest"))
+		Expect(containers[0].Command).To(Equal([]string{"echo", "middle"}))
+		Expect(containers[1].Name).To(Equal("sidecar"))
+	})
+
+	It("inherits the fields that the JobTemplate doesn't set", func() {
+		resolved, err := resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "base", "", ""))
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(resolved.Spec.Timeout.Duration).To(Equal(time.Hour))
+		Expect(resolved.Spec.Parameters).To(Equal(baseTemplate().Spec.Parameters))
+		Expect(resolved.Spec.OverridableFields).To(Equal(baseTemplate().Spec.OverridableFields))
+		Expect(resolved.Spec.JobTemplateSpec).To(Equal(baseTemplate().Spec.JobTemplateSpec))
+	})
+
+	It("replaces lists with a merge patch", func() {
+		resolved, err := resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "base", dispatcherv1beta1.MergePatchType, `
+spec:
+  template:
+    spec:
+      containers:
+      - name: only
+        image: busybox
+`))
+		Expect(err).To(Not(HaveOccurred()))
+		containers := resolved.Spec.JobTemplateSpec.Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0].Name).To(Equal("only"))
+	})
+
+	It("applies the operations of a JSON patch", func() {
+		resolved, err := resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "base", dispatcherv1beta1.JSONPatchType,
+			`[{"op": "replace", "path": "/spec/template/spec/containers/0/image", "value": "alpine:edge"}]`))
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(resolved.Spec.JobTemplateSpec.Spec.Template.Spec.Containers[0].Image).To(Equal("alpine:edge"))
+
+		_, err = resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "base", dispatcherv1beta1.JSONPatchType, `{"spec": {}}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects cyclic and missing bases", func() {
+		jt := new(dispatcherv1beta1.JobTemplate)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "cycle-a", Namespace: namespace}, jt)).To(Succeed())
+		_, err := resolveJobTemplate(ctx, k8sClient, "", jt)
+		Expect(err).To(MatchError(ContainSubstring("cycle-a -> cycle-b -> cycle-a")))
+
+		_, err = resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "missing", "", ""))
+		Expect(err).To(MatchError(ContainSubstring("failed fetching base JobTemplate missing")))
+	})
+
+	It("limits the number of bases", func() {
+		base := "base"
+		for _, name := range []string{"level-1", "level-2", "level-3", "level-4", "level-5"} {
+			Expect(k8sClient.Create(ctx, inheriting(name, base, "", ""))).To(Succeed())
+			base = name
+		}
+
+		_, err := resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "level-4", "", ""))
+		Expect(err).To(Not(HaveOccurred()))
+		_, err = resolveJobTemplate(ctx, k8sClient, "", inheriting("child", "level-5", "", ""))
+		Expect(err).To(MatchError(ContainSubstring("more than 5 bases")))
+	})
+})
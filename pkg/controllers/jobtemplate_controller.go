@@ -0,0 +1,172 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// The number of finished executions used for a JobTemplate's success rate.
+const maxRecentJobTemplateExecutions = 20
+
+// JobTemplateReconciler reconciles a JobTemplate object
+type JobTemplateReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobtemplates/status,verbs=get;update;patch
+
+// Reconcile updates the JobTemplate's status with the statistics of its
+// executions. Finished executions are recorded once, as they're deleted after
+// their Job.
+func (r *JobTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	jt := new(dispatcherv1beta1.JobTemplate)
+	if err := r.Get(ctx, req.NamespacedName, jt); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("JobTemplate resource not found, ignoring as resouce must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get JobTemplate, requeueing")
+		return ctrl.Result{}, err
+	}
+
+	list := new(dispatcherv1.JobExecutionList)
+	if err := r.List(ctx, list, client.InNamespace(jt.Namespace)); err != nil {
+		log.Error(err, "Failed to list the JobTemplate's JobExecutions")
+		return ctrl.Result{}, err
+	}
+
+	status := jt.Status.DeepCopy()
+	status.Active, status.Queued = 0, 0
+	for i := range list.Items {
+		je := &list.Items[i]
+		if !isJobTemplateExecution(jt, je) {
+			continue
+		}
+		if !isJobExecutionFinished(je) {
+			if meta.IsStatusConditionTrue(je.Status.Conditions, runningCondition) {
+				status.Active++
+			} else {
+				status.Queued++
+			}
+		} else if je.Status.CompletionTime != nil {
+			recordJobTemplateExecution(status, dispatcherv1beta1.JobTemplateExecution{
+				Name:           je.Name,
+				Outcome:        getJobExecutionOutcome(je),
+				CompletionTime: *je.Status.CompletionTime,
+			})
+		}
+	}
+
+	if equality.Semantic.DeepEqual(status, &jt.Status) {
+		return ctrl.Result{}, nil
+	}
+	jt.Status = *status
+	if err := r.Status().Update(ctx, jt); err != nil {
+		log.Error(err, "Failed to update JobTemplate status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JobTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dispatcherv1beta1.JobTemplate{}).
+		Watches(&dispatcherv1.JobExecution{}, handler.EnqueueRequestsFromMapFunc(getJobExecutionTemplateRequests)).
+		Complete(r)
+}
+
+// Returns the request for the JobTemplate executed by a JobExecution.
+func getJobExecutionTemplateRequests(_ context.Context, obj client.Object) []reconcile.Request {
+	je, ok := obj.(*dispatcherv1.JobExecution)
+	if !ok || je.Spec.JobTemplateKind == dispatcherv1.ClusterJobTemplateKind {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: je.Spec.JobTemplateName, Namespace: je.Namespace},
+	}}
+}
+
+// Returns true if the JobExecution is an execution of the JobTemplate. The
+// items of a fanned out execution are counted as part of it.
+func isJobTemplateExecution(jobTemplate *dispatcherv1beta1.JobTemplate, jobExecution *dispatcherv1.JobExecution) bool {
+	if _, ok := jobExecution.Labels[dispatcherv1.JobExecutionFanOutLabel]; ok {
+		return false
+	}
+	return jobExecution.Spec.JobTemplateName == jobTemplate.Name &&
+		jobExecution.Spec.JobTemplateKind != dispatcherv1.ClusterJobTemplateKind
+}
+
+// Records a finished execution in the JobTemplate's status, unless it's
+// already recorded, or older than the recent executions.
+func recordJobTemplateExecution(status *dispatcherv1beta1.JobTemplateStatus, execution dispatcherv1beta1.JobTemplateExecution) {
+	recent := status.RecentExecutions
+	if slices.ContainsFunc(recent, func(e dispatcherv1beta1.JobTemplateExecution) bool { return e.Name == execution.Name }) {
+		return
+	}
+	if len(recent) >= maxRecentJobTemplateExecutions && execution.CompletionTime.Before(&recent[len(recent)-1].CompletionTime) {
+		return
+	}
+
+	recent = append(recent, execution)
+	slices.SortStableFunc(recent, func(a, b dispatcherv1beta1.JobTemplateExecution) int {
+		if c := b.CompletionTime.Compare(a.CompletionTime.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	status.RecentExecutions = recent[:min(len(recent), maxRecentJobTemplateExecutions)]
+
+	if execution.Outcome == dispatcherv1beta1.ExecutionSucceeded {
+		if status.LastSuccessTime == nil || status.LastSuccessTime.Before(&execution.CompletionTime) {
+			status.LastSuccessTime = execution.CompletionTime.DeepCopy()
+		}
+	} else if status.LastFailureTime == nil || status.LastFailureTime.Before(&execution.CompletionTime) {
+		status.LastFailureTime = execution.CompletionTime.DeepCopy()
+	}
+
+	var succeeded int
+	for _, e := range status.RecentExecutions {
+		if e.Outcome == dispatcherv1beta1.ExecutionSucceeded {
+			succeeded++
+		}
+	}
+	status.SuccessRate = ptr.To(int32(succeeded * 100 / len(status.RecentExecutions)))
+	status.LastExecution = status.RecentExecutions[0].Name
+	status.LastOutcome = status.RecentExecutions[0].Outcome
+}
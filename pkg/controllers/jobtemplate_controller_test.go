@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("JobTemplate controller", func() {
+	const namespace = "default"
+	ctx := context.Background()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "report", Namespace: namespace}}
+	finished := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var (
+		k8sClient  client.Client
+		reconciler *JobTemplateReconciler
+	)
+
+	BeforeEach(func() {
+		jobTemplate := &dispatcherv1beta1.JobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: namespace},
+		}
+		k8sClient = newFakeClient(jobTemplate)
+		reconciler = &JobTemplateReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+		}
+	})
+
+	createJobExecution := func(name, templateName string, conditions ...metav1.Condition) *dispatcherv1.JobExecution {
+		je := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       dispatcherv1.JobExecutionSpec{JobTemplateName: templateName},
+		}
+		Expect(k8sClient.Create(ctx, je)).To(Succeed())
+		if len(conditions) > 0 {
+			je.Status.Conditions = conditions
+			Expect(k8sClient.Status().Update(ctx, je)).To(Succeed())
+		}
+		return je
+	}
+
+	finishJobExecution := func(name string, offset time.Duration, conditions ...metav1.Condition) {
+		je := createJobExecution(name, "report", conditions...)
+		je.Status.CompletionTime = ptr.To(metav1.NewTime(finished.Add(offset)))
+		Expect(k8sClient.Status().Update(ctx, je)).To(Succeed())
+	}
+
+	getStatus := func() dispatcherv1beta1.JobTemplateStatus {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		jt := new(dispatcherv1beta1.JobTemplate)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, jt)).To(Succeed())
+		return jt.Status
+	}
+
+	succeeded := metav1.Condition{Type: succeededCondition, Status: metav1.ConditionTrue, Reason: "JobSucceeded"}
+	failed := metav1.Condition{Type: succeededCondition, Status: metav1.ConditionFalse, Reason: "JobFailed"}
+	cancelled := metav1.Condition{Type: cancelledCondition, Status: metav1.ConditionTrue, Reason: "Cancelled"}
+	running := metav1.Condition{Type: runningCondition, Status: metav1.ConditionTrue, Reason: "JobRunning"}
+
+	It("counts the active and queued executions", func() {
+		createJobExecution("report-running", "report", running)
+		createJobExecution("report-waiting", "report")
+		createJobExecution("other-running", "other", running)
+
+		status := getStatus()
+		Expect(status.Active).To(Equal(int32(1)))
+		Expect(status.Queued).To(Equal(int32(1)))
+		Expect(status.LastExecution).To(BeEmpty())
+		Expect(status.SuccessRate).To(BeNil())
+	})
+
+	It("records the outcome of the finished executions", func() {
+		finishJobExecution("report-a", time.Minute, succeeded)
+		finishJobExecution("report-b", 2*time.Minute, failed)
+		finishJobExecution("report-c", 3*time.Minute, failed, cancelled)
+		finishJobExecution("report-d", 4*time.Minute, succeeded)
+
+		status := getStatus()
+		Expect(status.LastExecution).To(Equal("report-d"))
+		Expect(status.LastOutcome).To(Equal(dispatcherv1beta1.ExecutionSucceeded))
+		Expect(status.LastSuccessTime.Time).To(BeTemporally("==", finished.Add(4*time.Minute)))
+		Expect(status.LastFailureTime.Time).To(BeTemporally("==", finished.Add(3*time.Minute)))
+		Expect(status.SuccessRate).To(Equal(ptr.To[int32](50)))
+		Expect(status.RecentExecutions).To(HaveLen(4))
+		Expect(status.RecentExecutions[1].Outcome).To(Equal(dispatcherv1beta1.ExecutionCancelled))
+
+		By("Keeping them once the executions are deleted")
+		list := new(dispatcherv1.JobExecutionList)
+		Expect(k8sClient.List(ctx, list)).To(Succeed())
+		for i := range list.Items {
+			Expect(k8sClient.Delete(ctx, &list.Items[i])).To(Succeed())
+		}
+		Expect(getStatus().RecentExecutions).To(Equal(status.RecentExecutions))
+	})
+
+	It("limits the recent executions", func() {
+		for i := range maxRecentJobTemplateExecutions {
+			finishJobExecution(fmt.Sprintf("report-%d", i), time.Duration(i+1)*time.Minute, succeeded)
+		}
+		Expect(getStatus().SuccessRate).To(Equal(ptr.To[int32](100)))
+
+		By("Ignoring executions older than the recent ones")
+		finishJobExecution("report-old", 0, failed)
+		status := getStatus()
+		Expect(status.SuccessRate).To(Equal(ptr.To[int32](100)))
+		Expect(status.LastFailureTime).To(BeNil())
+
+		By("Dropping the oldest execution")
+		finishJobExecution("report-new", time.Hour, failed)
+		status = getStatus()
+		Expect(status.RecentExecutions).To(HaveLen(maxRecentJobTemplateExecutions))
+		Expect(status.LastExecution).To(Equal("report-new"))
+		Expect(status.SuccessRate).To(Equal(ptr.To[int32](95)))
+	})
+
+	It("maps JobExecutions to their JobTemplate", func() {
+		je := &dispatcherv1.JobExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-abcde", Namespace: namespace},
+			Spec:       dispatcherv1.JobExecutionSpec{JobTemplateName: "report"},
+		}
+		Expect(getJobExecutionTemplateRequests(ctx, je)).To(Equal([]reconcile.Request{request}))
+
+		je.Spec.JobTemplateKind = dispatcherv1.ClusterJobTemplateKind
+		Expect(getJobExecutionTemplateRequests(ctx, je)).To(BeEmpty())
+	})
+})
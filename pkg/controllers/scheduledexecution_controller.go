@@ -0,0 +1,298 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The maximum number of missed schedules to look for, before giving up.
+const maxMissedSchedules = 100
+
+var (
+	scheduledExecutionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "scheduled_executions_total",
+		Help: "The total number of JobExecutions created on schedule.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		scheduledExecutionsTotal,
+	)
+}
+
+// ScheduledExecutionReconciler reconciles a ScheduledExecution object
+type ScheduledExecutionReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Returns the current time, replaced in tests.
+	now func() time.Time
+}
+
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=scheduledexecutions,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=scheduledexecutions/status,verbs=get;update;patch
+
+// Reconcile creates the JobExecutions of a ScheduledExecution once their time
+// comes, and requeues until the next one.
+func (r *ScheduledExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	se := new(dispatcherv1beta1.ScheduledExecution)
+	if err := r.Get(ctx, req.NamespacedName, se); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("ScheduledExecution resource not found, ignoring as resouce must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ScheduledExecution, requeueing")
+		return ctrl.Result{}, err
+	}
+
+	children := new(dispatcherv1.JobExecutionList)
+	if err := r.List(
+		ctx,
+		children,
+		client.InNamespace(se.Namespace),
+		client.MatchingLabels{dispatcherv1beta1.ScheduledExecutionNameLabel: getNameLabelValue(se.Name)},
+	); err != nil {
+		log.Error(err, "Failed to list the ScheduledExecution's JobExecutions")
+		return ctrl.Result{}, err
+	}
+	children.Items = slices.DeleteFunc(children.Items, func(child dispatcherv1.JobExecution) bool {
+		return !metav1.IsControlledBy(&child, se)
+	})
+
+	var active []*dispatcherv1.JobExecution
+	se.Status.Active = nil
+	for i := range children.Items {
+		child := &children.Items[i]
+		if !isJobExecutionFinished(child) {
+			active = append(active, child)
+			se.Status.Active = append(se.Status.Active, child.Name)
+		} else if meta.IsStatusConditionTrue(child.Status.Conditions, succeededCondition) && child.Status.CompletionTime != nil {
+			if se.Status.LastSuccessfulTime == nil || se.Status.LastSuccessfulTime.Before(child.Status.CompletionTime) {
+				se.Status.LastSuccessfulTime = child.Status.CompletionTime.DeepCopy()
+			}
+		}
+	}
+	slices.Sort(se.Status.Active)
+
+	result, err := r.scheduleJobExecution(ctx, se, active)
+	if err != nil {
+		return result, err
+	}
+
+	if err := r.Status().Update(ctx, se); err != nil {
+		log.Error(err, "Failed to update ScheduledExecution status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// Starts the last missed execution, unless the ScheduledExecution is suspended
+// or it is forbidden by the concurrency policy. Returns when to requeue for the
+// next execution.
+func (r *ScheduledExecutionReconciler) scheduleJobExecution(ctx context.Context, se *dispatcherv1beta1.ScheduledExecution, active []*dispatcherv1.JobExecution) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if se.Spec.Suspend {
+		return ctrl.Result{}, nil
+	}
+
+	schedule, err := parseSchedule(se.Spec.Schedule, se.Spec.TimeZone)
+	if err != nil {
+		// A new schedule triggers a new reconciliation, don't requeue.
+		log.Error(err, "Invalid schedule", "schedule", se.Spec.Schedule)
+		r.Recorder.Eventf(se, corev1.EventTypeWarning, "InvalidSchedule", "Invalid schedule %q: %s", se.Spec.Schedule, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	now := r.getNow()
+	missed, count, next := getScheduledTimes(se, schedule, now)
+	if count > maxMissedSchedules {
+		// Still start the last missed time, like a CronJob.
+		log.Info("Too many missed schedules", "count", count)
+		r.Recorder.Eventf(se, corev1.EventTypeWarning, "TooManyMissedTimes", "Too many missed start times (%d), set or decrease the startingDeadlineSeconds, or check the clock skew", count)
+	}
+	result := ctrl.Result{RequeueAfter: next.Sub(now)}
+	if missed.IsZero() {
+		return result, nil
+	}
+
+	switch se.Spec.ConcurrencyPolicy {
+	case dispatcherv1beta1.ForbidConcurrent:
+		if len(active) > 0 {
+			// Retried until it starts, or it exceeds the starting deadline.
+			log.Info("Skipping execution, the previous one is still running", "scheduledTime", missed)
+			return result, nil
+		}
+	case dispatcherv1beta1.ReplaceConcurrent:
+		for _, child := range active {
+			if child.Spec.Cancel {
+				continue
+			}
+			patch := client.MergeFrom(child.DeepCopy())
+			child.Spec.Cancel = true
+			if err := r.Patch(ctx, child, patch); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "Failed to cancel the replaced JobExecution")
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(se, corev1.EventTypeNormal, "Replaced", "Cancelled JobExecution %s", child.Name)
+		}
+	}
+
+	je, err := r.newScheduledJobExecution(se, missed)
+	if err != nil {
+		log.Error(err, "Failed to generate the scheduled JobExecution")
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, je); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create the scheduled JobExecution")
+		r.Recorder.Eventf(se, corev1.EventTypeWarning, "FailedCreate", "Failed creating JobExecution: %s", err.Error())
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Eventf(se, corev1.EventTypeNormal, "Created", "Created JobExecution %s", je.Name)
+	scheduledExecutionsTotal.Inc()
+
+	se.Status.LastScheduleTime = &metav1.Time{Time: missed}
+	if !slices.Contains(se.Status.Active, je.Name) {
+		se.Status.Active = append(se.Status.Active, je.Name)
+	}
+	return result, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ScheduledExecutionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dispatcherv1beta1.ScheduledExecution{}).
+		Owns(&dispatcherv1.JobExecution{}).
+		Complete(r)
+}
+
+// Returns the JobExecution scheduled at the time. Its name is deterministic,
+// so it's created only once.
+func (r *ScheduledExecutionReconciler) newScheduledJobExecution(se *dispatcherv1beta1.ScheduledExecution, scheduledTime time.Time) (*dispatcherv1.JobExecution, error) {
+	je := &dispatcherv1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getChildJobExecutionName(se.Name, strconv.FormatInt(scheduledTime.Unix()/60, 10)),
+			Namespace: se.Namespace,
+			Labels: map[string]string{
+				dispatcherv1beta1.ScheduledExecutionNameLabel: getNameLabelValue(se.Name),
+			},
+		},
+		Spec: dispatcherv1.JobExecutionSpec{
+			JobTemplateName: se.Spec.JobTemplateName,
+			Payload:         se.Spec.Payload,
+		},
+	}
+	if err := ctrl.SetControllerReference(se, je, r.Scheme); err != nil {
+		return nil, err
+	}
+	return je, nil
+}
+
+// Returns the current time.
+func (r *ScheduledExecutionReconciler) getNow() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// Parses the Cron schedule, in the time zone if it's set.
+func parseSchedule(spec string, timeZone *string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, err
+	}
+	if timeZone == nil {
+		return schedule, nil
+	}
+
+	location, err := time.LoadLocation(*timeZone)
+	if err != nil {
+		return nil, err
+	}
+	if specSchedule, ok := schedule.(*cron.SpecSchedule); ok {
+		specSchedule.Location = location
+	}
+	return schedule, nil
+}
+
+// Returns the last scheduled time that was missed, or a zero time if none
+// was, an estimate of the number of missed times, and the next scheduled time.
+// The missed times are counted from the last scheduled execution, or from the
+// ScheduledExecution's creation, and not before its starting deadline.
+func getScheduledTimes(se *dispatcherv1beta1.ScheduledExecution, schedule cron.Schedule, now time.Time) (missed time.Time, count int, next time.Time) {
+	earliest := se.CreationTimestamp.Time
+	if se.Status.LastScheduleTime != nil {
+		earliest = se.Status.LastScheduleTime.Time
+	}
+	if deadline := se.Spec.StartingDeadlineSeconds; deadline != nil {
+		if start := now.Add(-time.Duration(*deadline) * time.Second); start.After(earliest) {
+			earliest = start
+		}
+	}
+
+	next = schedule.Next(now)
+	first := schedule.Next(earliest)
+	if first.After(now) {
+		return time.Time{}, 0, next
+	}
+	interval := schedule.Next(first).Sub(first)
+	count = int(now.Sub(first)/interval) + 1
+
+	// Don't loop over a schedule that was missed for too long, look for the
+	// last missed time back from now, doubling the lookback until it has one.
+	start := earliest
+	if count > maxMissedSchedules {
+		for lookback := interval; ; lookback *= 2 {
+			if start = now.Add(-lookback); !start.After(earliest) {
+				start = earliest
+				break
+			}
+			if !schedule.Next(start).After(now) {
+				break
+			}
+		}
+	}
+	for t := schedule.Next(start); !t.After(now); t = schedule.Next(t) {
+		missed = t
+	}
+	return missed, count, next
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+var _ = Describe("ScheduledExecution controller", func() {
+	const namespace = "default"
+	ctx := context.Background()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "hourly", Namespace: namespace}}
+	created := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+	var (
+		k8sClient  client.Client
+		reconciler *ScheduledExecutionReconciler
+		now        time.Time
+	)
+
+	newReconciler := func(spec dispatcherv1beta1.ScheduledExecutionSpec) {
+		scheduledExecution := &dispatcherv1beta1.ScheduledExecution{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "hourly",
+				Namespace:         namespace,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: spec,
+		}
+		k8sClient = newFakeClient(scheduledExecution)
+		reconciler = &ScheduledExecutionReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(1024),
+			now:      func() time.Time { return now },
+		}
+	}
+
+	listJobExecutions := func() []dispatcherv1.JobExecution {
+		list := new(dispatcherv1.JobExecutionList)
+		Expect(k8sClient.List(ctx, list, client.MatchingLabels{dispatcherv1beta1.ScheduledExecutionNameLabel: "hourly"})).To(Succeed())
+		return list.Items
+	}
+
+	finishJobExecution := func(je dispatcherv1.JobExecution) {
+		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
+			Type:   succeededCondition,
+			Status: metav1.ConditionTrue,
+			Reason: "JobSucceeded",
+		})
+		je.Status.CompletionTime = ptr.To(metav1.NewTime(now))
+		Expect(k8sClient.Status().Update(ctx, &je)).To(Succeed())
+	}
+
+	It("creates the JobExecutions on schedule", func() {
+		newReconciler(dispatcherv1beta1.ScheduledExecutionSpec{
+			JobTemplateName: "report",
+			Schedule:        "0 * * * *",
+			Payload:         "daily",
+		})
+
+		By("Waiting for the first scheduled time")
+		now = created.Add(10 * time.Minute)
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(20 * time.Minute))
+		Expect(listJobExecutions()).To(BeEmpty())
+
+		By("Creating the execution once it's due")
+		now = created.Add(31 * time.Minute)
+		result, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(59 * time.Minute))
+
+		jobExecutions := listJobExecutions()
+		Expect(jobExecutions).To(HaveLen(1))
+		Expect(jobExecutions[0].Spec.JobTemplateName).To(Equal("report"))
+		Expect(jobExecutions[0].Spec.Payload).To(Equal("daily"))
+		Expect(metav1.GetControllerOf(&jobExecutions[0]).Name).To(Equal("hourly"))
+
+		se := new(dispatcherv1beta1.ScheduledExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, se)).To(Succeed())
+		Expect(se.Status.LastScheduleTime.Time).To(BeTemporally("==", created.Add(30*time.Minute)))
+		Expect(se.Status.Active).To(Equal([]string{jobExecutions[0].Name}))
+
+		By("Recording the successful execution")
+		finishJobExecution(jobExecutions[0])
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(k8sClient.Get(ctx, request.NamespacedName, se)).To(Succeed())
+		Expect(se.Status.Active).To(BeEmpty())
+		Expect(se.Status.LastSuccessfulTime).To(Not(BeNil()))
+		Expect(listJobExecutions()).To(HaveLen(1))
+	})
+
+	It("starts the last missed execution after too many missed schedules", func() {
+		newReconciler(dispatcherv1beta1.ScheduledExecutionSpec{
+			JobTemplateName: "report",
+			Schedule:        "0 * * * *",
+		})
+
+		now = created.AddDate(0, 1, 0)
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(30 * time.Minute))
+		Expect(listJobExecutions()).To(HaveLen(1))
+		Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("TooManyMissedTimes")))
+
+		se := new(dispatcherv1beta1.ScheduledExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, se)).To(Succeed())
+		Expect(se.Status.LastScheduleTime.Time).To(BeTemporally("==", now.Add(-30*time.Minute)))
+	})
+
+	It("starts only the last missed execution within the deadline", func() {
+		newReconciler(dispatcherv1beta1.ScheduledExecutionSpec{
+			JobTemplateName:         "report",
+			Schedule:                "0 * * * *",
+			StartingDeadlineSeconds: ptr.To[int64](600),
+		})
+
+		now = created.Add(5*time.Hour + 15*time.Minute)
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(listJobExecutions()).To(BeEmpty())
+
+		now = created.Add(5*time.Hour + 35*time.Minute)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(listJobExecutions()).To(HaveLen(1))
+	})
+
+	It("follows the concurrency policy", func() {
+		By("Forbidding concurrent executions")
+		newReconciler(dispatcherv1beta1.ScheduledExecutionSpec{
+			JobTemplateName:   "report",
+			Schedule:          "*/10 * * * *",
+			ConcurrencyPolicy: dispatcherv1beta1.ForbidConcurrent,
+		})
+		now = created.Add(11 * time.Minute)
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		now = created.Add(21 * time.Minute)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(listJobExecutions()).To(HaveLen(1))
+
+		By("Replacing the running execution")
+		newReconciler(dispatcherv1beta1.ScheduledExecutionSpec{
+			JobTemplateName:   "report",
+			Schedule:          "*/10 * * * *",
+			ConcurrencyPolicy: dispatcherv1beta1.ReplaceConcurrent,
+		})
+		now = created.Add(11 * time.Minute)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		now = created.Add(21 * time.Minute)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		jobExecutions := listJobExecutions()
+		Expect(jobExecutions).To(HaveLen(2))
+		Expect([]bool{jobExecutions[0].Spec.Cancel, jobExecutions[1].Spec.Cancel}).To(ConsistOf(true, false))
+	})
+
+	It("doesn't schedule suspended executions", func() {
+		newReconciler(dispatcherv1beta1.ScheduledExecutionSpec{
+			JobTemplateName: "report",
+			Schedule:        "* * * * *",
+			Suspend:         true,
+		})
+		now = created.Add(time.Hour)
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.IsZero()).To(BeTrue())
+		Expect(listJobExecutions()).To(BeEmpty())
+	})
+
+	It("parses the schedule in its time zone", func() {
+		schedule, err := parseSchedule("0 9 * * *", ptr.To("America/Mexico_City"))
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(schedule.Next(created)).To(BeTemporally("==", time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)))
+
+		_, err = parseSchedule("0 9 * * *", ptr.To("Nowhere/Invalid"))
+		Expect(err).To(HaveOccurred())
+		_, err = parseSchedule("every hour", nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("finds the last missed time after too many missed schedules", func() {
+		se := &dispatcherv1beta1.ScheduledExecution{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+		schedule, err := parseSchedule("* * * * *", nil)
+		Expect(err).To(Not(HaveOccurred()))
+		missed, count, next := getScheduledTimes(se, schedule, created.Add(24*time.Hour+30*time.Second))
+		Expect(missed).To(BeTemporally("==", created.Add(24*time.Hour)))
+		Expect(count).To(Equal(24 * 60))
+		Expect(next).To(BeTemporally("==", created.Add(24*time.Hour+time.Minute)))
+
+		schedule, err = parseSchedule("30 6-16/4 * * 1-5", nil)
+		Expect(err).To(Not(HaveOccurred()))
+		missed, count, _ = getScheduledTimes(se, schedule, created.AddDate(1, 0, 0))
+		Expect(count).To(BeNumerically(">", maxMissedSchedules))
+		Expect(missed).To(BeTemporally("==", time.Date(2024, 12, 31, 14, 30, 0, 0, time.UTC)))
+	})
+})
@@ -30,6 +30,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
 	dispatcherv1alpha1 "github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
 	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
 	//+kubebuilder:scaffold:imports
@@ -69,6 +70,9 @@ var _ = BeforeSuite(func() {
 	err = dispatcherv1beta1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = dispatcherv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	//+kubebuilder:scaffold:scheme
 
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
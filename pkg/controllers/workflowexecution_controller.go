@@ -0,0 +1,393 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	workflowExecutionsFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "workflow_executions_failures_total",
+		Help: "The total number of failed WorkflowExecutions.",
+	})
+	workflowExecutionsSuccessTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "workflow_executions_success_total",
+		Help: "The total number of successful WorkflowExecutions.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		workflowExecutionsFailuresTotal,
+		workflowExecutionsSuccessTotal,
+	)
+}
+
+// WorkflowExecutionReconciler reconciles a WorkflowExecution object
+type WorkflowExecutionReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=workflows,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=workflowexecutions,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=workflowexecutions/status,verbs=get;update;patch
+
+// Reconcile creates the JobExecutions of the Workflow's steps once the steps
+// they depend on finish, and tracks their outcome.
+func (r *WorkflowExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	we := new(dispatcherv1beta1.WorkflowExecution)
+	if err := r.Get(ctx, req.NamespacedName, we); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("WorkflowExecution resource not found, ignoring as resource must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get WorkflowExecution, requeueing")
+		return ctrl.Result{}, err
+	}
+	if isWorkflowExecutionFinished(we) {
+		return ctrl.Result{}, nil
+	}
+
+	wf := new(dispatcherv1beta1.Workflow)
+	if err := r.Get(ctx, types.NamespacedName{Name: we.Spec.WorkflowName, Namespace: we.Namespace}, wf); err != nil {
+		meta.SetStatusCondition(&we.Status.Conditions, metav1.Condition{
+			Type:    runningCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "FetchWorkflowError",
+			Message: "Failed fetching Workflow",
+		})
+		if err := r.Status().Update(ctx, we); err != nil {
+			log.Error(err, "Failed to set WorkflowExecution status to failed fetching workflow")
+			return ctrl.Result{}, err
+		}
+
+		log.Error(err, "Failed to get Workflow, requeueing")
+		r.Recorder.Eventf(we, corev1.EventTypeWarning, "WorkflowNotFound", "Failed fetching Workflow %s: %s", we.Spec.WorkflowName, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if err := template.ValidateWorkflowSteps(wf.Spec.Steps); err != nil {
+		log.Error(err, "Invalid Workflow")
+		r.Recorder.Eventf(we, corev1.EventTypeWarning, "InvalidWorkflow", "Workflow %s is invalid: %s", wf.Name, err.Error())
+		finishWorkflowExecution(we, false, "InvalidWorkflow", err.Error())
+		workflowExecutionsFailuresTotal.Inc()
+		if err := r.Status().Update(ctx, we); err != nil {
+			log.Error(err, "Failed to update WorkflowExecution status for invalid workflow")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if we.Status.StartTime == nil {
+		now := metav1.Now()
+		we.Status.StartTime = &now
+		meta.SetStatusCondition(&we.Status.Conditions, metav1.Condition{
+			Type:    runningCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "WorkflowStarted",
+			Message: "Workflow is running",
+		})
+		meta.SetStatusCondition(&we.Status.Conditions, metav1.Condition{
+			Type:    succeededCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "WorkflowStarted",
+			Message: "Workflow is running",
+		})
+	}
+	for _, step := range wf.Spec.Steps {
+		if getWorkflowStepStatus(we, step.Name) == nil {
+			we.Status.Steps = append(we.Status.Steps, dispatcherv1beta1.WorkflowStepStatus{
+				Name:  step.Name,
+				Phase: dispatcherv1beta1.WorkflowStepPending,
+			})
+		}
+	}
+
+	// Track the outcome of the running steps
+	for i := range we.Status.Steps {
+		if we.Status.Steps[i].Phase != dispatcherv1beta1.WorkflowStepRunning {
+			continue
+		}
+		if err := r.updateWorkflowStep(ctx, we, &we.Status.Steps[i]); err != nil {
+			log.Error(err, "Failed to get the step's JobExecution", "step", we.Status.Steps[i].Name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Start, or skip, the steps whose dependencies finished, until no step is
+	// ready
+	for progressed := true; progressed; {
+		progressed = false
+		for _, step := range wf.Spec.Steps {
+			status := getWorkflowStepStatus(we, step.Name)
+			if status.Phase != dispatcherv1beta1.WorkflowStepPending || !areWorkflowStepDependenciesFinished(we, step) {
+				continue
+			}
+			if err := r.startWorkflowStep(ctx, we, step, status); err != nil {
+				log.Error(err, "Failed to start step", "step", step.Name)
+				return ctrl.Result{}, err
+			}
+			progressed = true
+		}
+	}
+
+	if finished, succeeded := getWorkflowExecutionOutcome(we); finished {
+		if succeeded {
+			finishWorkflowExecution(we, true, "WorkflowSucceeded", "All the steps finished")
+			r.Recorder.Eventf(we, corev1.EventTypeNormal, "Completed", "Workflow %s completed", wf.Name)
+			workflowExecutionsSuccessTotal.Inc()
+		} else {
+			finishWorkflowExecution(we, false, "StepFailed", "At least one of the steps failed")
+			r.Recorder.Eventf(we, corev1.EventTypeWarning, "Failed", "Workflow %s failed", wf.Name)
+			workflowExecutionsFailuresTotal.Inc()
+		}
+	}
+
+	if err := r.Status().Update(ctx, we); err != nil {
+		log.Error(err, "Failed to update WorkflowExecution status")
+		return ctrl.Result{}, err
+	}
+
+	if isWorkflowExecutionFinished(we) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: time.Second * 15}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WorkflowExecutionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dispatcherv1beta1.WorkflowExecution{}).
+		Owns(&dispatcherv1.JobExecution{}).
+		Complete(r)
+}
+
+// Updates the running step with the outcome of its JobExecution.
+func (r *WorkflowExecutionReconciler) updateWorkflowStep(ctx context.Context, workflowExecution *dispatcherv1beta1.WorkflowExecution, status *dispatcherv1beta1.WorkflowStepStatus) error {
+	je := new(dispatcherv1.JobExecution)
+	if err := r.Get(ctx, types.NamespacedName{Name: status.JobExecutionName, Namespace: workflowExecution.Namespace}, je); err != nil {
+		if errors.IsNotFound(err) {
+			status.Phase = dispatcherv1beta1.WorkflowStepFailed
+			status.Message = "JobExecution was deleted before finishing"
+			return nil
+		}
+		return err
+	}
+
+	condition := meta.FindStatusCondition(je.Status.Conditions, succeededCondition)
+	switch {
+	case condition == nil || condition.Status == metav1.ConditionUnknown:
+		return nil
+	case condition.Status == metav1.ConditionTrue:
+		result, err := getJobExecutionResult(ctx, r, je)
+		if err != nil {
+			return err
+		}
+		status.Phase = dispatcherv1beta1.WorkflowStepSucceeded
+		status.Result = result
+	default:
+		status.Phase = dispatcherv1beta1.WorkflowStepFailed
+	}
+	status.Message = condition.Message
+	return nil
+}
+
+// Creates the JobExecution of the step, or skips it when its condition is not
+// met.
+func (r *WorkflowExecutionReconciler) startWorkflowStep(ctx context.Context, workflowExecution *dispatcherv1beta1.WorkflowExecution, step dispatcherv1beta1.WorkflowStep, status *dispatcherv1beta1.WorkflowStepStatus) error {
+	env := newWorkflowEnvironment(workflowExecution, step)
+	run, err := shouldRunWorkflowStep(step, env)
+	if err != nil {
+		status.Phase = dispatcherv1beta1.WorkflowStepFailed
+		status.Message = fmt.Sprintf("Failed rendering the condition: %s", err.Error())
+		return nil
+	}
+	if !run {
+		status.Phase = dispatcherv1beta1.WorkflowStepSkipped
+		status.Message = "The step's condition was not met"
+		return nil
+	}
+
+	payload := workflowExecution.Spec.Payload
+	if len(step.Payload) > 0 {
+		if payload, err = template.RenderWorkflowStep(step.Payload, env); err != nil {
+			status.Phase = dispatcherv1beta1.WorkflowStepFailed
+			status.Message = fmt.Sprintf("Failed rendering the payload: %s", err.Error())
+			return nil
+		}
+	}
+
+	je := &dispatcherv1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getChildJobExecutionName(workflowExecution.Name, step.Name),
+			Namespace: workflowExecution.Namespace,
+			Labels: map[string]string{
+				dispatcherv1beta1.WorkflowExecutionNameLabel: getNameLabelValue(workflowExecution.Name),
+				dispatcherv1beta1.WorkflowStepLabel:          step.Name,
+			},
+		},
+		Spec: dispatcherv1.JobExecutionSpec{
+			JobTemplateName: step.JobTemplateName,
+			Payload:         payload,
+		},
+	}
+	if err := ctrl.SetControllerReference(workflowExecution, je, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, je); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	status.Phase = dispatcherv1beta1.WorkflowStepRunning
+	status.JobExecutionName = je.Name
+	status.Message = ""
+	r.Recorder.Eventf(workflowExecution, corev1.EventTypeNormal, "StepStarted", "Step %s started with JobExecution %s", step.Name, je.Name)
+	return nil
+}
+
+// Returns true if the step's condition is met. Without a condition, all the
+// steps it depends on must have succeeded.
+func shouldRunWorkflowStep(step dispatcherv1beta1.WorkflowStep, env *template.WorkflowEnvironment) (bool, error) {
+	if len(step.When) == 0 {
+		for _, output := range env.Steps {
+			if output.Outcome != string(dispatcherv1beta1.WorkflowStepSucceeded) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	rendered, err := template.RenderWorkflowStep(step.When, env)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(rendered) == "true", nil
+}
+
+// Returns the data available to the step's templates, with the outcome of the
+// steps it depends on.
+func newWorkflowEnvironment(workflowExecution *dispatcherv1beta1.WorkflowExecution, step dispatcherv1beta1.WorkflowStep) *template.WorkflowEnvironment {
+	env := &template.WorkflowEnvironment{
+		Name:    workflowExecution.Name,
+		Payload: workflowExecution.Spec.Payload,
+		Steps:   make(map[string]template.WorkflowStepOutput, len(step.DependsOn)),
+	}
+	for _, dependency := range step.DependsOn {
+		if status := getWorkflowStepStatus(workflowExecution, dependency); status != nil {
+			env.Steps[dependency] = template.WorkflowStepOutput{
+				Outcome: string(status.Phase),
+				Result:  status.Result,
+			}
+		}
+	}
+	return env
+}
+
+// Returns true if all the steps the step depends on finished.
+func areWorkflowStepDependenciesFinished(workflowExecution *dispatcherv1beta1.WorkflowExecution, step dispatcherv1beta1.WorkflowStep) bool {
+	for _, dependency := range step.DependsOn {
+		if status := getWorkflowStepStatus(workflowExecution, dependency); status == nil || !isWorkflowStepFinished(status) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether all the steps finished, and if none of them failed.
+func getWorkflowExecutionOutcome(workflowExecution *dispatcherv1beta1.WorkflowExecution) (finished, succeeded bool) {
+	succeeded = true
+	for i := range workflowExecution.Status.Steps {
+		status := &workflowExecution.Status.Steps[i]
+		if !isWorkflowStepFinished(status) {
+			return false, false
+		}
+		if status.Phase == dispatcherv1beta1.WorkflowStepFailed {
+			succeeded = false
+		}
+	}
+	return true, succeeded
+}
+
+// Sets the conditions and completion time of a finished WorkflowExecution.
+func finishWorkflowExecution(workflowExecution *dispatcherv1beta1.WorkflowExecution, succeeded bool, reason, message string) {
+	status := metav1.ConditionFalse
+	if succeeded {
+		status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&workflowExecution.Status.Conditions, metav1.Condition{
+		Type:    succeededCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	meta.SetStatusCondition(&workflowExecution.Status.Conditions, metav1.Condition{
+		Type:    runningCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	now := metav1.Now()
+	workflowExecution.Status.CompletionTime = &now
+}
+
+// Returns true if the WorkflowExecution succeeded or failed.
+func isWorkflowExecutionFinished(workflowExecution *dispatcherv1beta1.WorkflowExecution) bool {
+	condition := meta.FindStatusCondition(workflowExecution.Status.Conditions, succeededCondition)
+	return condition != nil && condition.Status != metav1.ConditionUnknown
+}
+
+func isWorkflowStepFinished(status *dispatcherv1beta1.WorkflowStepStatus) bool {
+	return status.Phase == dispatcherv1beta1.WorkflowStepSucceeded ||
+		status.Phase == dispatcherv1beta1.WorkflowStepFailed ||
+		status.Phase == dispatcherv1beta1.WorkflowStepSkipped
+}
+
+// Returns the status of the step with the given name, or nil if it doesn't
+// have it.
+func getWorkflowStepStatus(workflowExecution *dispatcherv1beta1.WorkflowExecution, name string) *dispatcherv1beta1.WorkflowStepStatus {
+	for i := range workflowExecution.Status.Steps {
+		if workflowExecution.Status.Steps[i].Name == name {
+			return &workflowExecution.Status.Steps[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	dispatcherv1 "github.com/ivanvc/dispatcher/pkg/api/v1"
+	dispatcherv1beta1 "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+)
+
+var _ = Describe("WorkflowExecution controller", func() {
+	const namespace = "default"
+	ctx := context.Background()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pipeline-abcde", Namespace: namespace}}
+
+	var (
+		k8sClient  client.Client
+		reconciler *WorkflowExecutionReconciler
+	)
+
+	BeforeEach(func() {
+		workflow := &dispatcherv1beta1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: namespace},
+			Spec: dispatcherv1beta1.WorkflowSpec{
+				Steps: []dispatcherv1beta1.WorkflowStep{{
+					Name:            "extract",
+					JobTemplateName: "extract",
+				}, {
+					Name:            "load",
+					JobTemplateName: "load",
+					DependsOn:       []string{"extract"},
+					Payload:         `{{(index .Steps "extract").Result}}`,
+				}, {
+					Name:            "cleanup",
+					JobTemplateName: "cleanup",
+					DependsOn:       []string{"extract"},
+					When:            `{{eq .Steps.extract.Outcome "Failed"}}`,
+				}},
+			},
+		}
+		workflowExecution := &dispatcherv1beta1.WorkflowExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline-abcde", Namespace: namespace},
+			Spec: dispatcherv1beta1.WorkflowExecutionSpec{
+				WorkflowName: "pipeline",
+				Payload:      "input",
+			},
+		}
+		k8sClient = newFakeClient(workflow, workflowExecution)
+		reconciler = &WorkflowExecutionReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(1024),
+		}
+	})
+
+	completeJobExecution := func(name, result string) {
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, je)).To(Succeed())
+		meta.SetStatusCondition(&je.Status.Conditions, metav1.Condition{
+			Type:   succeededCondition,
+			Status: metav1.ConditionTrue,
+			Reason: "JobSucceeded",
+		})
+		je.Status.Result = result
+		Expect(k8sClient.Status().Update(ctx, je)).To(Succeed())
+	}
+
+	It("runs the steps once their dependencies finish", func() {
+		By("Starting the steps without dependencies")
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		we := new(dispatcherv1beta1.WorkflowExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, we)).To(Succeed())
+		Expect(getWorkflowStepStatus(we, "extract").Phase).To(Equal(dispatcherv1beta1.WorkflowStepRunning))
+		Expect(getWorkflowStepStatus(we, "load").Phase).To(Equal(dispatcherv1beta1.WorkflowStepPending))
+		Expect(meta.IsStatusConditionTrue(we.Status.Conditions, runningCondition)).To(BeTrue())
+
+		je := new(dispatcherv1.JobExecution)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pipeline-abcde-extract", Namespace: namespace}, je)).To(Succeed())
+		Expect(je.Spec.Payload).To(Equal("input"))
+		Expect(je.Labels).To(HaveKeyWithValue(dispatcherv1beta1.WorkflowStepLabel, "extract"))
+
+		By("Passing the result to the dependent steps")
+		completeJobExecution("pipeline-abcde-extract", "rows.csv")
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		Expect(k8sClient.Get(ctx, request.NamespacedName, we)).To(Succeed())
+		Expect(getWorkflowStepStatus(we, "extract").Phase).To(Equal(dispatcherv1beta1.WorkflowStepSucceeded))
+		Expect(getWorkflowStepStatus(we, "load").Phase).To(Equal(dispatcherv1beta1.WorkflowStepRunning))
+		Expect(getWorkflowStepStatus(we, "cleanup").Phase).To(Equal(dispatcherv1beta1.WorkflowStepSkipped))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pipeline-abcde-load", Namespace: namespace}, je)).To(Succeed())
+		Expect(je.Spec.Payload).To(Equal("rows.csv"))
+
+		By("Succeeding once all the steps finish")
+		completeJobExecution("pipeline-abcde-load", "")
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		Expect(k8sClient.Get(ctx, request.NamespacedName, we)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(we.Status.Conditions, succeededCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(we.Status.Conditions, runningCondition)).To(BeTrue())
+		Expect(we.Status.CompletionTime).To(Not(BeNil()))
+	})
+
+	It("fails an invalid Workflow", func() {
+		workflow := new(dispatcherv1beta1.Workflow)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pipeline", Namespace: namespace}, workflow)).To(Succeed())
+		workflow.Spec.Steps[0].DependsOn = []string{"load"}
+		Expect(k8sClient.Update(ctx, workflow)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		we := new(dispatcherv1beta1.WorkflowExecution)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, we)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(we.Status.Conditions, succeededCondition)).To(BeTrue())
+		Expect(meta.FindStatusCondition(we.Status.Conditions, succeededCondition).Reason).To(Equal("InvalidWorkflow"))
+	})
+
+	It("only runs a step without a condition when its dependencies succeeded", func() {
+		step := dispatcherv1beta1.WorkflowStep{Name: "load", DependsOn: []string{"extract"}}
+		env := &template.WorkflowEnvironment{Steps: map[string]template.WorkflowStepOutput{
+			"extract": {Outcome: "Skipped"},
+		}}
+		Expect(shouldRunWorkflowStep(step, env)).To(BeFalse())
+
+		env.Steps["extract"] = template.WorkflowStepOutput{Outcome: "Succeeded"}
+		Expect(shouldRunWorkflowStep(step, env)).To(BeTrue())
+	})
+})
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+)
+
+// The length of the hash in the JobExecutionDeduplicationKeyLabel.
+const deduplicationKeyLength = 32
+
+// Returns the hash of the JobExecution's deduplication key, or an empty string
+// if it isn't deduplicated.
+func getDeduplicationKey(deduplication *v1beta1.Deduplication, jobExecution *v1.JobExecution) (string, error) {
+	if deduplication == nil {
+		return "", nil
+	}
+	key, err := template.RenderDeduplicationKey(deduplication.Key, jobExecution)
+	if err != nil || len(key) == 0 {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:deduplicationKeyLength], nil
+}
+
+// Returns the oldest JobExecution of the JobTemplate with the deduplication
+// key that is waiting or running, or nil if there isn't one, and the number of
+// the JobTemplate's executions with the key.
+func (e *executeJobHandler) getDuplicateJobExecution(ctx context.Context, namespace, jobTemplateName, key string) (*v1.JobExecution, int, error) {
+	list := new(v1.JobExecutionList)
+	if err := e.reader.List(
+		ctx,
+		list,
+		client.InNamespace(namespace),
+		client.MatchingLabels{v1.JobExecutionDeduplicationKeyLabel: key},
+	); err != nil {
+		return nil, 0, err
+	}
+
+	var oldest *v1.JobExecution
+	count := 0
+	for i := range list.Items {
+		je := &list.Items[i]
+		if je.Spec.JobTemplateName != jobTemplateName {
+			continue
+		}
+		count++
+		if !isActiveJobExecution(je) {
+			continue
+		}
+		if oldest == nil || je.CreationTimestamp.Before(&oldest.CreationTimestamp) {
+			oldest = je
+		}
+	}
+	return oldest, count, nil
+}
+
+// Returns the name of the JobTemplate's execution with the deduplication key
+// and sequence, which is the number of its previous executions with the key.
+// As it's deterministic, the API server creates a single JobExecution for
+// concurrent requests with the key, whichever replica serves them.
+func getDuplicateJobExecutionName(namespace, jobTemplateName, key string, sequence int) string {
+	return getJobExecutionName(jobTemplateName, namespace, key, strconv.Itoa(sequence))
+}
+
+// Returns true if the JobExecution is waiting or running. Cancelled
+// executions aren't active, even if they're still running.
+func isActiveJobExecution(je *v1.JobExecution) bool {
+	if je.Spec.Cancel || !je.DeletionTimestamp.IsZero() {
+		return false
+	}
+	phase := v1.GetJobExecutionPhase(je.Status.Conditions)
+	return phase == v1.JobExecutionPhasePending || phase == v1.JobExecutionPhaseRunning
+}
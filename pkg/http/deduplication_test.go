@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newDuplicateJobExecution(name, key string, age time.Duration, conditions ...metav1.Condition) *v1.JobExecution {
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			Labels:            map[string]string{v1.JobExecutionDeduplicationKeyLabel: key},
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Spec: v1.JobExecutionSpec{JobTemplateName: "rebuild"},
+	}
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&je.Status.Conditions, condition)
+	}
+	return je
+}
+
+func TestGetDeduplicationKey(t *testing.T) {
+	je := &v1.JobExecution{Spec: v1.JobExecutionSpec{Payload: `{"cache": "products", "id": 42}`}}
+
+	if key, err := getDeduplicationKey(nil, je); err != nil || len(key) > 0 {
+		t.Errorf("Expected no deduplication key, got %q, %v", key, err)
+	}
+
+	byPayload, err := getDeduplicationKey(&v1beta1.Deduplication{}, je)
+	if err != nil || len(byPayload) != deduplicationKeyLength {
+		t.Errorf("Expected the hash of the payload, got %q, %v", byPayload, err)
+	}
+	byCache, err := getDeduplicationKey(&v1beta1.Deduplication{Key: "{{ (fromJson .Payload).cache }}"}, je)
+	if err != nil || len(byCache) != deduplicationKeyLength || byCache == byPayload {
+		t.Errorf("Expected the hash of the rendered key, got %q, %v", byCache, err)
+	}
+
+	je.Spec.Payload = `{"id": 43}`
+	if key, err := getDeduplicationKey(&v1beta1.Deduplication{Key: `{{ get (fromJson .Payload) "cache" }}`}, je); err != nil || len(key) > 0 {
+		t.Errorf("Expected no deduplication key for an empty key, got %q, %v", key, err)
+	}
+	if _, err := getDeduplicationKey(&v1beta1.Deduplication{Key: `{{ fail "no cache" }}`}, je); err == nil {
+		t.Error("Expecting error, got nothing")
+	}
+}
+
+func TestGetDuplicateJobExecution(t *testing.T) {
+	running := metav1.Condition{Type: "Running", Status: metav1.ConditionTrue, Reason: "JobRunning"}
+	succeeded := metav1.Condition{Type: "Succeeded", Status: metav1.ConditionTrue, Reason: "JobSucceeded"}
+	cancelled := newDuplicateJobExecution("rebuild-cancelled", "key", time.Hour, running)
+	cancelled.Spec.Cancel = true
+	handler := &executeJobHandler{newTestServer(t,
+		newDuplicateJobExecution("rebuild-finished", "key", 2*time.Hour, succeeded),
+		cancelled,
+		newDuplicateJobExecution("rebuild-running", "key", 10*time.Minute, running),
+		newDuplicateJobExecution("rebuild-waiting", "key", time.Minute),
+		newDuplicateJobExecution("rebuild-other", "other", time.Hour, succeeded),
+	)}
+	ctx := context.Background()
+
+	je, count, err := handler.getDuplicateJobExecution(ctx, "default", "rebuild", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if je == nil || je.Name != "rebuild-running" {
+		t.Errorf("Expected the oldest active JobExecution, got %v", je)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 JobExecutions with the key, got %d", count)
+	}
+
+	for _, tc := range []struct{ name, key string }{{"rebuild", "other"}, {"rebuild", "missing"}, {"test", "key"}} {
+		if je, _, err := handler.getDuplicateJobExecution(ctx, "default", tc.name, tc.key); err != nil || je != nil {
+			t.Errorf("Expected no JobExecution for %s and %s, got %v, %v", tc.name, tc.key, je, err)
+		}
+	}
+}
+
+func TestExecuteJobWithADeduplicationKey(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t, &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "rebuild", Namespace: "default"},
+		Spec: v1beta1.JobTemplateSpec{
+			Deduplication: &v1beta1.Deduplication{Key: "{{ (fromJson .Payload).cache }}"},
+		},
+	})}
+
+	execute := func(payload string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(http.MethodPut, "/execute/rebuild", strings.NewReader(payload)))
+		return w
+	}
+
+	w := execute(`{"cache": "products", "id": 1}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code to be %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created jobExecutionResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	w = execute(`{"cache": "products", "id": 2}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusOK, w.Code)
+	}
+	var coalesced jobExecutionResponse
+	if err := json.NewDecoder(w.Body).Decode(&coalesced); err != nil {
+		t.Fatal(err)
+	}
+	if coalesced != created {
+		t.Errorf("Expected to coalesce into %v, got %v", created, coalesced)
+	}
+
+	if w := execute(`{"cache": "users", "id": 3}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusCreated, w.Code)
+	}
+	if w := execute(`not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	list := new(v1.JobExecutionList)
+	if err := handler.List(context.Background(), list, client.HasLabels{v1.JobExecutionDeduplicationKeyLabel}); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("Expected 2 JobExecutions, got %d", len(list.Items))
+	}
+}
+
+func TestExecuteJobWithAConcurrentDeduplicationKey(t *testing.T) {
+	jt := &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "rebuild", Namespace: "default"},
+		Spec:       v1beta1.JobTemplateSpec{Deduplication: &v1beta1.Deduplication{}},
+	}
+	key, err := getDeduplicationKey(jt.Spec.Deduplication, &v1.JobExecution{Spec: v1.JobExecutionSpec{Payload: "products"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	succeeded := metav1.Condition{Type: "Succeeded", Status: metav1.ConditionTrue, Reason: "JobSucceeded"}
+
+	tt := []struct {
+		existing *v1.JobExecution
+		status   int
+		expected string
+	}{
+		// Created by a concurrent request, after this one looked for it.
+		{newDuplicateJobExecution(getDuplicateJobExecutionName("default", "rebuild", key, 0), "", time.Minute), http.StatusOK, getDuplicateJobExecutionName("default", "rebuild", key, 0)},
+		// Finished after taking the name.
+		{newDuplicateJobExecution(getDuplicateJobExecutionName("default", "rebuild", key, 0), "", time.Hour, succeeded), http.StatusCreated, getDuplicateJobExecutionName("default", "rebuild", key, 1)},
+	}
+	for _, tc := range tt {
+		handler := &executeJobHandler{newTestServer(t, jt.DeepCopy(), tc.existing)}
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(http.MethodPut, "/execute/rebuild", strings.NewReader("products")))
+		if w.Code != tc.status {
+			t.Errorf("Expected status code to be %d, got %d", tc.status, w.Code)
+		}
+		var res jobExecutionResponse
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Name != tc.expected {
+			t.Errorf("Expected JobExecution %s, got %v", tc.expected, res)
+		}
+	}
+}
+
+func TestExecuteClusterJobTemplateWithADeduplicationKey(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t, &v1beta1.ClusterJobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "rebuild"},
+		Spec: v1beta1.ClusterJobTemplateSpec{
+			JobTemplateSpec: v1beta1.JobTemplateSpec{
+				Deduplication: &v1beta1.Deduplication{Key: "{{ (fromJson .Payload).cache }}"},
+			},
+			AllowedNamespaces: []string{"team"},
+		},
+	})}
+
+	var responses []jobExecutionResponse
+	for i, status := range []int{http.StatusCreated, http.StatusOK} {
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(http.MethodPut, "/execute/team/rebuild", strings.NewReader(fmt.Sprintf(`{"cache": "products", "id": %d}`, i))))
+		if w.Code != status {
+			t.Fatalf("Expected status code to be %d, got %d", status, w.Code)
+		}
+		var res jobExecutionResponse
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, res)
+	}
+	if responses[1] != responses[0] {
+		t.Errorf("Expected to coalesce into %v, got %v", responses[0], responses[1])
+	}
+
+	list := new(v1.JobExecutionList)
+	if err := handler.List(context.Background(), list, client.InNamespace("team")); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Spec.JobTemplateKind != v1.ClusterJobTemplateKind {
+		t.Errorf("Expected a single JobExecution of the ClusterJobTemplate, got %v", list.Items)
+	}
+}
@@ -3,17 +3,30 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
-	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -34,6 +47,18 @@ var (
 		Name: "job_requests_success_total",
 		Help: "The total number of success dispatch job requests",
 	})
+	jobRequestsIdempotentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_requests_idempotent_total",
+		Help: "The total number of dispatch job requests that returned an existing execution for their idempotency key",
+	})
+	jobRequestsCoalescedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_requests_coalesced_total",
+		Help: "The total number of dispatch job requests coalesced into an active execution with their deduplication key",
+	})
+	jobRequestsRateLimitedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_requests_rate_limited_total",
+		Help: "The total number of dispatch job requests rejected for exceeding a rate limit",
+	})
 )
 
 func init() {
@@ -42,9 +67,15 @@ func init() {
 		jobRequestsFailuresTotal,
 		jobRequestsNotFoundFailuresTotal,
 		jobRequestsSuccessTotal,
+		jobRequestsIdempotentTotal,
+		jobRequestsCoalescedTotal,
+		jobRequestsRateLimitedTotal,
 	)
 }
 
+// The length of the hash in the names of the JobExecutions created with a key.
+const jobExecutionNameHashLength = 16
+
 type executeJobHandler struct {
 	*Server
 }
@@ -72,7 +103,9 @@ func (e *executeJobHandler) handle(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	jt, err := e.getJobTemplate(ns, name, ctx)
+	idempotencyKey := getIdempotencyKey(req.Header.Get(idempotencyKeyHeader))
+
+	jt, kind, err := e.getJobTemplate(ns, name, ctx)
 	if err != nil {
 		jobRequestsFailuresTotal.Inc()
 		jobRequestsNotFoundFailuresTotal.Inc()
@@ -81,25 +114,134 @@ func (e *executeJobHandler) handle(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if ok, delay := e.rateLimiter.allow(req, ns, name); !ok {
+		jobRequestsFailuresTotal.Inc()
+		jobRequestsRateLimitedTotal.Inc()
+		log.Info("Rate limit exceeded", "name", name, "namespace", ns, "retryAfter", delay)
+		writeTooManyRequests(w, delay)
+		return
+	}
+
+	payload, err := readPayload(w, req, e.maxPayloadSize)
+	if err != nil {
+		jobRequestsFailuresTotal.Inc()
+		log.Error(err, "Invalid payload")
+		var payloadErr *payloadError
+		if errors.As(err, &payloadErr) {
+			w.WriteHeader(payloadErr.status)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	jobExecution := createJobExecution(jt, payload)
+	jobExecution.Spec.JobTemplateKind = kind
+	deduplicationKey, err := getDeduplicationKey(jt.Spec.Deduplication, jobExecution)
+	if err != nil {
+		jobRequestsFailuresTotal.Inc()
+		log.Error(err, "Error rendering deduplication key")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := setJobExecutionOptions(jobExecution, req.URL.Query()); err != nil {
+		jobRequestsFailuresTotal.Inc()
+		log.Error(err, "Invalid JobExecution options")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := template.ValidateOverrides(jt.Spec.OverridableFields, jobExecution.Spec.Overrides); err != nil {
+		jobRequestsFailuresTotal.Inc()
+		log.Error(err, "Forbidden JobExecution overrides")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if callback := jobExecution.Spec.Callback; callback != nil {
+		if !isCallbackHostAllowed(e.callbackHosts, callback.URL) {
+			jobRequestsFailuresTotal.Inc()
+			log.Info("Forbidden callback URL host", "callbackUrl", callback.URL)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		callback.SecretRef = jt.Spec.CallbackSecretRef.DeepCopy()
+	}
+
+	if len(idempotencyKey) > 0 {
+		existing, err := e.getIdempotentJobExecution(ctx, jt.Namespace, jt.Name, idempotencyKey)
+		if err != nil {
+			jobRequestsFailuresTotal.Inc()
+			log.Error(err, "Error getting JobExecution for idempotency key", "idempotencyKey", idempotencyKey)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			jobRequestsIdempotentTotal.Inc()
+			log.Info("Returning existing JobExecution for idempotency key", "name", existing.Name, "namespace", existing.Namespace, "idempotencyKey", idempotencyKey)
+			writeJobExecution(w, http.StatusOK, existing)
+			return
+		}
+		setIdempotencyKey(jobExecution, idempotencyKey)
+		if e.idempotencyKeyWindow > 0 {
+			jobExecution.GenerateName = ""
+			jobExecution.Name = e.getIdempotentJobExecutionName(jt.Namespace, jt.Name, idempotencyKey, time.Now())
+		}
+	}
+	var sequence int
+	if len(deduplicationKey) > 0 {
+		existing, count, err := e.getDuplicateJobExecution(ctx, jt.Namespace, jt.Name, deduplicationKey)
+		if err != nil {
+			jobRequestsFailuresTotal.Inc()
+			log.Error(err, "Error getting JobExecution for deduplication key", "deduplicationKey", deduplicationKey)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			jobRequestsCoalescedTotal.Inc()
+			log.Info("Coalescing into the active JobExecution with the deduplication key", "name", existing.Name, "namespace", existing.Namespace, "deduplicationKey", deduplicationKey)
+			writeJobExecution(w, http.StatusOK, existing)
+			return
+		}
+		setJobExecutionLabel(jobExecution, v1.JobExecutionDeduplicationKeyLabel, deduplicationKey)
+		// It replaces the idempotency key's name, as concurrent requests with
+		// the idempotency key have the same deduplication key.
+		sequence = count
+		jobExecution.GenerateName = ""
+		jobExecution.Name = getDuplicateJobExecutionName(jt.Namespace, jt.Name, deduplicationKey, sequence)
+	}
+
 	log.Info("Creating JobExecution", "name", name, "namespace", ns)
-	jobExecution := createJobExecution(jt, req.Body)
 	if e.logJobExecutionPayloads {
 		log.Info("JobExecution payload", "jobExecution", jobExecution)
 	}
 
-	if err := e.Create(ctx, jobExecution); err != nil {
+	existing, err := e.createOrGetJobExecution(ctx, jobExecution, jt.Name, deduplicationKey, sequence)
+	if err != nil {
 		jobRequestsFailuresTotal.Inc()
 		log.Error(err, "Error creating JobExecution")
 		w.WriteHeader(http.StatusNotAcceptable)
 		return
 	}
+	if existing != nil && len(deduplicationKey) > 0 {
+		jobRequestsCoalescedTotal.Inc()
+		log.Info("Coalescing into the concurrent JobExecution with the deduplication key", "name", existing.Name, "namespace", existing.Namespace, "deduplicationKey", deduplicationKey)
+		writeJobExecution(w, http.StatusOK, existing)
+		return
+	} else if existing != nil {
+		jobRequestsIdempotentTotal.Inc()
+		log.Info("Returning the concurrent JobExecution for idempotency key", "name", existing.Name, "namespace", existing.Namespace, "idempotencyKey", idempotencyKey)
+		writeJobExecution(w, http.StatusOK, existing)
+		return
+	}
 
 	jobRequestsSuccessTotal.Inc()
-	w.WriteHeader(http.StatusCreated)
+	writeJobExecution(w, http.StatusCreated, jobExecution)
 }
 
+// Gets the name and namespace from a path in the form of
+// /handler/[namespace/]name.
 func getNameAndNamespace(path, defaultNamespace string) (name, namespace string, err error) {
-	if n := strings.Split(strings.TrimPrefix(path, "/execute/"), "/"); len(n[0]) == 0 || len(n) > 2 {
+	_, path, _ = strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if n := strings.Split(path, "/"); len(n[0]) == 0 || len(n) > 2 {
 		return "", "", errors.New("Empty job name")
 	} else if len(n) > 1 {
 		namespace = n[0]
@@ -111,27 +253,241 @@ func getNameAndNamespace(path, defaultNamespace string) (name, namespace string,
 	return
 }
 
-func createJobExecution(jobTemplate *v1alpha1.JobTemplate, body io.ReadCloser) *v1alpha1.JobExecution {
-	var b bytes.Buffer
-	if body != nil {
-		io.Copy(&b, body)
-	}
-
-	return &v1alpha1.JobExecution{
+func createJobExecution(jobTemplate *v1beta1.JobTemplate, payload string) *v1.JobExecution {
+	return &v1.JobExecution{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: jobTemplate.ObjectMeta.Name + "-",
 			Namespace:    jobTemplate.ObjectMeta.Namespace,
 			Labels:       jobTemplate.ObjectMeta.Labels,
 		},
-		Spec: v1alpha1.JobExecutionSpec{
+		Spec: v1.JobExecutionSpec{
 			JobTemplateName: jobTemplate.ObjectMeta.Name,
-			Payload:         b.String(),
+			JobTemplateVersion: &v1.JobTemplateVersion{
+				ResourceVersion: jobTemplate.ObjectMeta.ResourceVersion,
+				Generation:      jobTemplate.ObjectMeta.Generation,
+			},
+			Payload: payload,
 		},
 	}
 }
 
-func (e *executeJobHandler) getJobTemplate(namespace, name string, ctx context.Context) (*v1alpha1.JobTemplate, error) {
-	jt := new(v1alpha1.JobTemplate)
+// Creates the JobExecution, or returns the one with its name, which a
+// concurrent request with the same key created. A finished JobExecution with
+// the deduplication key only takes the name, so it's retried with the next
+// sequence.
+func (e *executeJobHandler) createOrGetJobExecution(ctx context.Context, jobExecution *v1.JobExecution, jobTemplateName, deduplicationKey string, sequence int) (*v1.JobExecution, error) {
+	for {
+		err := e.Create(ctx, jobExecution)
+		if !apierrors.IsAlreadyExists(err) || len(jobExecution.Name) == 0 {
+			return nil, err
+		}
+		existing := new(v1.JobExecution)
+		if err := e.reader.Get(ctx, client.ObjectKeyFromObject(jobExecution), existing); err != nil {
+			return nil, err
+		}
+		if len(deduplicationKey) == 0 || isActiveJobExecution(existing) {
+			return existing, nil
+		}
+		sequence++
+		jobExecution.Name = getDuplicateJobExecutionName(jobExecution.Namespace, jobTemplateName, deduplicationKey, sequence)
+	}
+}
+
+// Returns the name of a JobExecution of the JobTemplate identified by the
+// parts, which is the JobTemplate's name suffixed with their hash. It's
+// shortened to be a valid label value, like the names of the JobExecutions
+// created by the controller.
+func getJobExecutionName(jobTemplateName string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{jobTemplateName}, parts...), "/")))
+	suffix := "-" + hex.EncodeToString(sum[:])[:jobExecutionNameHashLength]
+	if length := validation.LabelValueMaxLength - len(suffix); len(jobTemplateName) > length {
+		jobTemplateName = strings.TrimRight(jobTemplateName[:length], "-.")
+	}
+	return jobTemplateName + suffix
+}
+
+// Sets the JobExecution's options from the request's query parameters.
+func setJobExecutionOptions(jobExecution *v1.JobExecution, query url.Values) error {
+	if query.Has("timeout") {
+		timeout, err := time.ParseDuration(query.Get("timeout"))
+		if err != nil {
+			return err
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("non-positive timeout %s", timeout)
+		}
+		jobExecution.Spec.Timeout = &metav1.Duration{Duration: timeout}
+	}
+	if query.Has("notBefore") && query.Has("delay") {
+		return errors.New("notBefore and delay are mutually exclusive")
+	}
+	if query.Has("notBefore") {
+		notBefore, err := time.Parse(time.RFC3339, query.Get("notBefore"))
+		if err != nil {
+			return err
+		}
+		jobExecution.Spec.NotBefore = &metav1.Time{Time: notBefore}
+	}
+	if query.Has("delay") {
+		delay, err := time.ParseDuration(query.Get("delay"))
+		if err != nil {
+			return err
+		}
+		if delay <= 0 {
+			return fmt.Errorf("non-positive delay %s", delay)
+		}
+		jobExecution.Spec.NotBefore = &metav1.Time{Time: time.Now().Add(delay)}
+	}
+	if query.Has("callbackSecret") {
+		return errors.New("callbackSecret isn't supported, the JobTemplate's callbackSecretRef signs the callbacks")
+	}
+	if query.Has("callbackUrl") {
+		callback, err := getCallback(query.Get("callbackUrl"))
+		if err != nil {
+			return err
+		}
+		jobExecution.Spec.Callback = callback
+	}
+	if query.Has("fanOut") {
+		fanOut, err := strconv.ParseBool(query.Get("fanOut"))
+		if err != nil {
+			return err
+		}
+		if fanOut {
+			items, err := getItems(jobExecution.Spec.Payload)
+			if err != nil {
+				return err
+			}
+			jobExecution.Spec.Items = items
+			jobExecution.Spec.Payload = ""
+		}
+	}
+	if query.Has("parallelism") {
+		if len(jobExecution.Spec.Items) == 0 {
+			return errors.New("parallelism requires fanOut")
+		}
+		parallelism, err := strconv.ParseInt(query.Get("parallelism"), 10, 32)
+		if err != nil {
+			return err
+		}
+		if parallelism <= 0 {
+			return fmt.Errorf("non-positive parallelism %d", parallelism)
+		}
+		jobExecution.Spec.Parallelism = ptr.To(int32(parallelism))
+	}
+	overrides, err := getOverrides(query)
+	if err != nil {
+		return err
+	}
+	jobExecution.Spec.Overrides = overrides
+	return nil
+}
+
+// Returns the overrides from the query parameters: the image or its tag, the
+// environment variables in the form of NAME=value, and the arguments. The
+// JobTemplate has to declare them as overridable.
+func getOverrides(query url.Values) (*v1.ExecutionOverrides, error) {
+	if !query.Has("image") && !query.Has("imageTag") && !query.Has("env") && !query.Has("arg") {
+		return nil, nil
+	}
+	if query.Has("image") && query.Has("imageTag") {
+		return nil, errors.New("image and imageTag are mutually exclusive")
+	}
+
+	overrides := &v1.ExecutionOverrides{
+		Image:    query.Get("image"),
+		ImageTag: query.Get("imageTag"),
+		Args:     query["arg"],
+	}
+	for _, env := range query["env"] {
+		name, value, found := strings.Cut(env, "=")
+		if !found || len(name) == 0 {
+			return nil, fmt.Errorf("invalid environment variable %q", env)
+		}
+		overrides.Env = append(overrides.Env, corev1.EnvVar{Name: name, Value: value})
+	}
+	return overrides, nil
+}
+
+// Returns the items of a fanned out JobExecution from a JSON array. String
+// items are used as they are, and any other value as its JSON encoding.
+func getItems(payload string) ([]string, error) {
+	var values []json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &values); err != nil {
+		return nil, fmt.Errorf("fanOut requires a JSON array: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("fanOut requires at least one item")
+	}
+
+	items := make([]string, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal(value, &items[i]); err != nil {
+			var b bytes.Buffer
+			if err := json.Compact(&b, value); err != nil {
+				return nil, err
+			}
+			items[i] = b.String()
+		}
+	}
+	return items, nil
+}
+
+// Returns the callback to the URL. It's signed with the JobTemplate's
+// CallbackSecretRef, rather than a Secret chosen by the caller.
+func getCallback(callbackURL string) (*v1.Callback, error) {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid callback URL %q", callbackURL)
+	}
+	return &v1.Callback{URL: callbackURL}, nil
+}
+
+// Returns true if the callback URL's host is one of the hosts, or a subdomain
+// of a host in the form of *.example.com. No host is allowed when there are no
+// hosts.
+func isCallbackHostAllowed(hosts []string, callbackURL string) bool {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(allowed)
+		if domain, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the JobTemplate from the namespace, or the ClusterJobTemplate with the
+// name if the namespace doesn't have it and is allowed by it, and returns its
+// kind. A ClusterJobTemplate is returned as a JobTemplate in the namespace,
+// with its metadata and spec.
+func (e *executeJobHandler) getJobTemplate(namespace, name string, ctx context.Context) (*v1beta1.JobTemplate, string, error) {
+	jt := new(v1beta1.JobTemplate)
 	err := e.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, jt)
-	return jt, err
+	if !apierrors.IsNotFound(err) {
+		return jt, v1.JobTemplateKind, err
+	}
+
+	cjt := new(v1beta1.ClusterJobTemplate)
+	if err := e.Get(ctx, types.NamespacedName{Name: name}, cjt); err != nil {
+		return nil, "", err
+	}
+	if !cjt.IsNamespaceAllowed(namespace) {
+		return nil, "", apierrors.NewForbidden(v1beta1.GroupVersion.WithResource("clusterjobtemplates").GroupResource(), name, fmt.Errorf("namespace %s isn't allowed", namespace))
+	}
+	jt.ObjectMeta = cjt.ObjectMeta
+	jt.Spec = cjt.Spec.JobTemplateSpec
+	jt.Namespace = namespace
+	return jt, v1.ClusterJobTemplateKind, nil
 }
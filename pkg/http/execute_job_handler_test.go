@@ -1,13 +1,20 @@
 package http
 
 import (
-	"bytes"
-	"io/ioutil"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
 )
 
 func TestGetNameAndNamespaceWithAnError(t *testing.T) {
@@ -30,6 +37,7 @@ func TestGetNameAndNamespaceWithoutAnError(t *testing.T) {
 	tt := [][]string{
 		[]string{"/execute/a", "default", "a"},
 		[]string{"/execute/a/b", "a", "b"},
+		[]string{"/executions/a/b", "a", "b"},
 	}
 	for _, tc := range tt {
 		name, ns, err := getNameAndNamespace(tc[0], "default")
@@ -47,15 +55,17 @@ func TestGetNameAndNamespaceWithoutAnError(t *testing.T) {
 }
 
 func TestCreateJobExecutionWithoutARequestBody(t *testing.T) {
-	jt := &v1alpha1.JobTemplate{
+	jt := &v1beta1.JobTemplate{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test",
-			Namespace: "default",
-			Labels:    map[string]string{"test": "true"},
+			Name:            "test",
+			Namespace:       "default",
+			Labels:          map[string]string{"test": "true"},
+			ResourceVersion: "42",
+			Generation:      3,
 		},
-		Spec: v1alpha1.JobTemplateSpec{},
+		Spec: v1beta1.JobTemplateSpec{},
 	}
-	je := createJobExecution(jt, nil)
+	je := createJobExecution(jt, "")
 
 	if je.ObjectMeta.GenerateName != "test-" {
 		t.Errorf("Expected JobExecution GenerateName to be %q, got %q", "test-", je.ObjectMeta.GenerateName)
@@ -69,21 +79,292 @@ func TestCreateJobExecutionWithoutARequestBody(t *testing.T) {
 	if je.Spec.JobTemplateName != "test" {
 		t.Errorf("Expected JobExecutionSpec JobTemplateName to be %q, got %q", "test", je.Spec.JobTemplateName)
 	}
+	if expected := (&v1.JobTemplateVersion{ResourceVersion: "42", Generation: 3}); !reflect.DeepEqual(je.Spec.JobTemplateVersion, expected) {
+		t.Errorf("Expected JobExecutionSpec JobTemplateVersion to be %v, got %v", expected, je.Spec.JobTemplateVersion)
+	}
 }
 
 func TestCreateJobExecutionWithARequestBody(t *testing.T) {
-	jt := &v1alpha1.JobTemplate{
+	jt := &v1beta1.JobTemplate{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test",
 			Namespace: "default",
 			Labels:    map[string]string{"test": "true"},
 		},
-		Spec: v1alpha1.JobTemplateSpec{},
+		Spec: v1beta1.JobTemplateSpec{},
 	}
-	body := ioutil.NopCloser(bytes.NewReader([]byte("testing")))
-	je := createJobExecution(jt, body)
+	je := createJobExecution(jt, "testing")
 
 	if je.Spec.Payload != "testing" {
 		t.Errorf("Expected JobExecutionSpec Payload to be %q, got %q", "testing", je.Spec.Payload)
 	}
 }
+
+func TestGetJobTemplate(t *testing.T) {
+	e := &executeJobHandler{newTestServer(t,
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "team"}},
+		&v1beta1.ClusterJobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Labels: map[string]string{"app": "shared"}},
+			Spec:       v1beta1.ClusterJobTemplateSpec{AllowedNamespaces: []string{"*"}},
+		},
+		&v1beta1.ClusterJobTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "restricted"},
+			Spec:       v1beta1.ClusterJobTemplateSpec{AllowedNamespaces: []string{"ops"}},
+		},
+	)}
+
+	tt := []struct {
+		name, kind string
+	}{
+		{"local", v1.JobTemplateKind},
+		{"shared", v1.ClusterJobTemplateKind},
+	}
+	for _, tc := range tt {
+		jt, kind, err := e.getJobTemplate("team", tc.name, context.Background())
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if kind != tc.kind {
+			t.Errorf("Expected kind to be %q, got %q", tc.kind, kind)
+		}
+		if jt.Name != tc.name || jt.Namespace != "team" {
+			t.Errorf("Expected JobTemplate to be team/%s, got %s/%s", tc.name, jt.Namespace, jt.Name)
+		}
+	}
+
+	if _, _, err := e.getJobTemplate("team", "missing", context.Background()); err == nil {
+		t.Error("Expecting error with a missing JobTemplate, got nothing")
+	}
+	if _, _, err := e.getJobTemplate("team", "restricted", context.Background()); !apierrors.IsForbidden(err) {
+		t.Errorf("Expecting a Forbidden error with a ClusterJobTemplate that doesn't allow the namespace, got %v", err)
+	}
+}
+
+func TestSetJobExecutionOptions(t *testing.T) {
+	je := new(v1.JobExecution)
+	if err := setJobExecutionOptions(je, url.Values{}); err != nil {
+		t.Error(err)
+		return
+	}
+	if je.Spec.Timeout != nil {
+		t.Errorf("Expected JobExecutionSpec Timeout to be nil, got %v", je.Spec.Timeout)
+	}
+
+	if err := setJobExecutionOptions(je, url.Values{"timeout": {"1h30m"}}); err != nil {
+		t.Error(err)
+		return
+	}
+	if je.Spec.Timeout == nil || je.Spec.Timeout.Duration != 90*time.Minute {
+		t.Errorf("Expected JobExecutionSpec Timeout to be %v, got %v", 90*time.Minute, je.Spec.Timeout)
+	}
+	if je.Spec.Callback != nil {
+		t.Errorf("Expected JobExecutionSpec Callback to be nil, got %v", je.Spec.Callback)
+	}
+}
+
+func TestSetJobExecutionOptionsWithACallback(t *testing.T) {
+	je := new(v1.JobExecution)
+	if err := setJobExecutionOptions(je, url.Values{"callbackUrl": {"https://example.com/done"}}); err != nil {
+		t.Error(err)
+		return
+	}
+	if je.Spec.Callback == nil || je.Spec.Callback.URL != "https://example.com/done" {
+		t.Errorf("Expected JobExecutionSpec Callback URL to be set, got %v", je.Spec.Callback)
+		return
+	}
+	if je.Spec.Callback.SecretRef != nil {
+		t.Errorf("Expected no SecretRef, got %v", je.Spec.Callback.SecretRef)
+	}
+}
+
+func TestIsCallbackHostAllowed(t *testing.T) {
+	hosts := []string{"example.com", "*.hooks.example.org"}
+	tt := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/done", true},
+		{"https://EXAMPLE.com:8443/done", true},
+		{"https://ci.hooks.example.org/done", true},
+		{"https://hooks.example.org/done", false},
+		{"https://api.example.com/done", false},
+		{"http://10.0.0.1/done", false},
+		{"https://example.com.attacker.net/done", false},
+	}
+	for _, tc := range tt {
+		if allowed := isCallbackHostAllowed(hosts, tc.url); allowed != tc.allowed {
+			t.Errorf("Expected %s to be allowed: %t, got %t", tc.url, tc.allowed, allowed)
+		}
+	}
+	if isCallbackHostAllowed(nil, "https://example.com/done") {
+		t.Error("Expected no host to be allowed without hosts")
+	}
+}
+
+func TestExecuteJobWithACallback(t *testing.T) {
+	secretRef := &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "signing"},
+		Key:                  "secret",
+	}
+	handler := &executeJobHandler{newTestServer(t, &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       v1beta1.JobTemplateSpec{CallbackSecretRef: secretRef},
+	})}
+	handler.callbackHosts = []string{"example.com"}
+
+	tt := []struct {
+		query  string
+		status int
+	}{
+		{"callbackUrl=http://10.0.0.1/done", http.StatusForbidden},
+		{"callbackUrl=https://example.com/done&callbackSecret=other", http.StatusBadRequest},
+		{"callbackUrl=https://example.com/done", http.StatusCreated},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(http.MethodPost, "/execute/test?"+tc.query, nil))
+		if w.Code != tc.status {
+			t.Errorf("Expected status code to be %d with %q, got %d", tc.status, tc.query, w.Code)
+		}
+	}
+
+	list := new(v1.JobExecutionList)
+	if err := handler.List(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("Expected 1 JobExecution, got %d", len(list.Items))
+	}
+	if callback := list.Items[0].Spec.Callback; callback == nil || !reflect.DeepEqual(callback.SecretRef, secretRef) {
+		t.Errorf("Expected the Callback to be signed with the JobTemplate's secret, got %v", callback)
+	}
+}
+
+func TestSetJobExecutionOptionsWithADelay(t *testing.T) {
+	je := new(v1.JobExecution)
+	if err := setJobExecutionOptions(je, url.Values{"notBefore": {"2024-01-01T02:00:00+02:00"}}); err != nil {
+		t.Error(err)
+		return
+	}
+	if expected := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); je.Spec.NotBefore == nil || !je.Spec.NotBefore.Equal(&metav1.Time{Time: expected}) {
+		t.Errorf("Expected JobExecutionSpec NotBefore to be %v, got %v", expected, je.Spec.NotBefore)
+	}
+
+	je = new(v1.JobExecution)
+	if err := setJobExecutionOptions(je, url.Values{"delay": {"1h"}}); err != nil {
+		t.Error(err)
+		return
+	}
+	if je.Spec.NotBefore == nil || time.Until(je.Spec.NotBefore.Time).Round(time.Minute) != time.Hour {
+		t.Errorf("Expected JobExecutionSpec NotBefore to be in an hour, got %v", je.Spec.NotBefore)
+	}
+}
+
+func TestSetJobExecutionOptionsWithFanOut(t *testing.T) {
+	je := new(v1.JobExecution)
+	je.Spec.Payload = `["a", {"id": 1}, 2]`
+	if err := setJobExecutionOptions(je, url.Values{"fanOut": {"true"}, "parallelism": {"2"}}); err != nil {
+		t.Error(err)
+		return
+	}
+	if expected := []string{"a", `{"id":1}`, "2"}; !reflect.DeepEqual(je.Spec.Items, expected) {
+		t.Errorf("Expected JobExecutionSpec Items to be %q, got %q", expected, je.Spec.Items)
+	}
+	if len(je.Spec.Payload) > 0 {
+		t.Errorf("Expected JobExecutionSpec Payload to be empty, got %q", je.Spec.Payload)
+	}
+	if je.Spec.Parallelism == nil || *je.Spec.Parallelism != 2 {
+		t.Errorf("Expected JobExecutionSpec Parallelism to be 2, got %v", je.Spec.Parallelism)
+	}
+
+	for _, payload := range []string{"", "[]", `{"items": ["a"]}`} {
+		je := new(v1.JobExecution)
+		je.Spec.Payload = payload
+		if err := setJobExecutionOptions(je, url.Values{"fanOut": {"true"}}); err == nil {
+			t.Errorf("Expecting error with payload %q, got nothing", payload)
+		}
+	}
+}
+
+func TestSetJobExecutionOptionsWithOverrides(t *testing.T) {
+	je := new(v1.JobExecution)
+	query := url.Values{
+		"imageTag": {"sha-1234567"},
+		"env":      {"LOG_LEVEL=debug", "EMPTY="},
+		"arg":      {"--dry-run", "--verbose"},
+	}
+	if err := setJobExecutionOptions(je, query); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := &v1.ExecutionOverrides{
+		ImageTag: "sha-1234567",
+		Env: []corev1.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "EMPTY"},
+		},
+		Args: []string{"--dry-run", "--verbose"},
+	}
+	if !reflect.DeepEqual(je.Spec.Overrides, expected) {
+		t.Errorf("Expected JobExecutionSpec Overrides to be %v, got %v", expected, je.Spec.Overrides)
+	}
+}
+
+func TestSetJobExecutionOptionsWithAnError(t *testing.T) {
+	tt := []url.Values{
+		{"timeout": {"1"}},
+		{"timeout": {"-1m"}},
+		{"callbackUrl": {"ftp://example.com"}},
+		{"callbackUrl": {"/relative"}},
+		{"callbackUrl": {"https://example.com"}, "callbackSecret": {"signing"}},
+		{"callbackSecret": {"signing"}},
+		{"notBefore": {"tomorrow"}},
+		{"delay": {"0s"}},
+		{"notBefore": {"2024-01-01T00:00:00Z"}, "delay": {"1h"}},
+		{"fanOut": {"maybe"}},
+		{"fanOut": {"true"}},
+		{"parallelism": {"2"}},
+		{"image": {"busybox:1.37"}, "imageTag": {"1.37"}},
+		{"env": {"LOG_LEVEL"}},
+		{"env": {"=debug"}},
+	}
+	for _, tc := range tt {
+		if err := setJobExecutionOptions(new(v1.JobExecution), tc); err == nil {
+			t.Errorf("Expecting error with input %v, got nothing", tc)
+		}
+	}
+}
+
+func TestExecuteJobWithOverrides(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t, &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1beta1.JobTemplateSpec{
+			OverridableFields: []v1beta1.OverridableField{v1beta1.OverridableFieldEnv},
+		},
+	})}
+
+	tt := []struct {
+		query  string
+		status int
+	}{
+		{"env=LOG_LEVEL=debug", http.StatusCreated},
+		{"imageTag=sha-1234567", http.StatusForbidden},
+		{"env=LOG_LEVEL=debug&arg=--dry-run", http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(http.MethodPost, "/execute/test?"+tc.query, nil))
+		if w.Code != tc.status {
+			t.Errorf("Expected status code to be %d with %q, got %d", tc.status, tc.query, w.Code)
+		}
+	}
+
+	list := new(v1.JobExecutionList)
+	if err := handler.List(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("Expected 1 JobExecution, got %d", len(list.Items))
+	}
+}
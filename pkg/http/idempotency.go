@@ -0,0 +1,87 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+const (
+	// The header with the key that identifies the retries of a request.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// The length of the hash in the JobExecutionIdempotencyKeyLabel.
+	idempotencyKeyLength = 32
+)
+
+// Returns the hash of the request's idempotency key, which is stored as the
+// value of a label, or an empty string if it doesn't have one.
+func getIdempotencyKey(header string) string {
+	if len(header) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(header))
+	return hex.EncodeToString(sum[:])[:idempotencyKeyLength]
+}
+
+// Returns the newest JobExecution of the JobTemplate created with the
+// idempotency key within the window, or nil if there isn't one.
+func (e *executeJobHandler) getIdempotentJobExecution(ctx context.Context, namespace, jobTemplateName, key string) (*v1.JobExecution, error) {
+	if e.idempotencyKeyWindow <= 0 {
+		return nil, nil
+	}
+
+	list := new(v1.JobExecutionList)
+	if err := e.reader.List(
+		ctx,
+		list,
+		client.InNamespace(namespace),
+		client.MatchingLabels{v1.JobExecutionIdempotencyKeyLabel: key},
+	); err != nil {
+		return nil, err
+	}
+
+	var newest *v1.JobExecution
+	since := time.Now().Add(-e.idempotencyKeyWindow)
+	for i := range list.Items {
+		je := &list.Items[i]
+		if je.Spec.JobTemplateName != jobTemplateName || je.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&je.CreationTimestamp) {
+			newest = je
+		}
+	}
+	return newest, nil
+}
+
+// Returns the name of the JobExecution created with the idempotency key in the
+// current period of the window. As it's deterministic, the API server creates
+// a single JobExecution for concurrent requests with the key, whichever
+// replica serves them.
+func (e *executeJobHandler) getIdempotentJobExecutionName(namespace, jobTemplateName, key string, now time.Time) string {
+	period := now.UnixNano() / int64(e.idempotencyKeyWindow)
+	return getJobExecutionName(jobTemplateName, namespace, key, strconv.FormatInt(period, 10))
+}
+
+// Records the idempotency key in the JobExecution's label.
+func setIdempotencyKey(jobExecution *v1.JobExecution, key string) {
+	setJobExecutionLabel(jobExecution, v1.JobExecutionIdempotencyKeyLabel, key)
+}
+
+// Sets the JobExecution's label. Its labels are copied, as they're shared with
+// its JobTemplate.
+func setJobExecutionLabel(jobExecution *v1.JobExecution, key, value string) {
+	labels := maps.Clone(jobExecution.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[key] = value
+	jobExecution.Labels = labels
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newIdempotentJobExecution(name, jobTemplateName, key string, age time.Duration) *v1.JobExecution {
+	return &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			Labels:            map[string]string{v1.JobExecutionIdempotencyKeyLabel: getIdempotencyKey(key)},
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Spec: v1.JobExecutionSpec{JobTemplateName: jobTemplateName},
+	}
+}
+
+func TestGetIdempotencyKey(t *testing.T) {
+	if key := getIdempotencyKey(""); len(key) > 0 {
+		t.Errorf("Expected no idempotency key, got %q", key)
+	}
+	keys := make(map[string]bool)
+	for _, tc := range []string{"8e03978e-40d5-43e8-bc93-6894a57f9324", "with spaces", strings.Repeat("a", 64), "urn:event/1+2"} {
+		key := getIdempotencyKey(tc)
+		if len(key) != idempotencyKeyLength || len(validation.IsValidLabelValue(key)) > 0 {
+			t.Errorf("Expected the hash of %q as a label value, got %q", tc, key)
+		}
+		keys[key] = true
+	}
+	if len(keys) != 4 {
+		t.Errorf("Expected a different hash for every key, got %v", keys)
+	}
+}
+
+func TestGetIdempotentJobExecutionName(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t)}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := getIdempotencyKey("event-1")
+
+	name := handler.getIdempotentJobExecutionName("default", "test", key, now)
+	if name != handler.getIdempotentJobExecutionName("default", "test", key, now.Add(30*time.Minute)) {
+		t.Errorf("Expected the same name within the window's period, got %q", name)
+	}
+	for _, other := range []string{
+		handler.getIdempotentJobExecutionName("other", "test", key, now),
+		handler.getIdempotentJobExecutionName("default", "other", key, now),
+		handler.getIdempotentJobExecutionName("default", "test", getIdempotencyKey("event-2"), now),
+		handler.getIdempotentJobExecutionName("default", "test", key, now.Add(time.Hour)),
+	} {
+		if other == name {
+			t.Errorf("Expected a different name than %q", name)
+		}
+	}
+
+	long := handler.getIdempotentJobExecutionName("default", strings.Repeat("a", 100), key, now)
+	if len(long) > validation.LabelValueMaxLength || !strings.HasPrefix(long, "aaaa") {
+		t.Errorf("Expected the name to be shortened, got %q", long)
+	}
+}
+
+func TestGetIdempotentJobExecution(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t,
+		newIdempotentJobExecution("test-old", "test", "event-1", 2*time.Hour),
+		newIdempotentJobExecution("test-abcde", "test", "event-1", 10*time.Minute),
+		newIdempotentJobExecution("test-fghij", "test", "event-1", time.Minute),
+		newIdempotentJobExecution("other-abcde", "other", "event-2", time.Minute),
+	)}
+	ctx := context.Background()
+
+	je, err := handler.getIdempotentJobExecution(ctx, "default", "test", getIdempotencyKey("event-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if je == nil || je.Name != "test-fghij" {
+		t.Errorf("Expected the newest JobExecution within the window, got %v", je)
+	}
+
+	for _, tc := range []struct{ name, key string }{{"test", "event-2"}, {"test", "event-3"}, {"other", "event-1"}} {
+		if je, err := handler.getIdempotentJobExecution(ctx, "default", tc.name, getIdempotencyKey(tc.key)); err != nil || je != nil {
+			t.Errorf("Expected no JobExecution for %s and %s, got %v, %v", tc.name, tc.key, je, err)
+		}
+	}
+
+	handler.idempotencyKeyWindow = 0
+	if je, err := handler.getIdempotentJobExecution(ctx, "default", "test", getIdempotencyKey("event-1")); err != nil || je != nil {
+		t.Errorf("Expected no JobExecution without a window, got %v, %v", je, err)
+	}
+}
+
+func TestExecuteJobWithAnIdempotencyKey(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t,
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		newIdempotentJobExecution("test-abcde", "test", "event-1", time.Minute),
+	)}
+
+	execute := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/execute/test", strings.NewReader("payload"))
+		req.Header.Set(idempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		handler.handle(w, req)
+		return w
+	}
+
+	w := execute("event-1")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusOK, w.Code)
+	}
+	var res jobExecutionResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Name != "test-abcde" {
+		t.Errorf("Expected the existing JobExecution, got %v", res)
+	}
+
+	if w := execute("event-2"); w.Code != http.StatusCreated {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusCreated, w.Code)
+	}
+	list := new(v1.JobExecutionList)
+	if err := handler.List(context.Background(), list, client.MatchingLabels{v1.JobExecutionIdempotencyKeyLabel: getIdempotencyKey("event-2")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("Expected a JobExecution with the idempotency key, got %v", list.Items)
+	}
+
+	if w := execute("not a label value: 1/2"); w.Code != http.StatusCreated {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusCreated, w.Code)
+	}
+}
+
+func TestExecuteJobWithAConcurrentIdempotencyKey(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t,
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+	)}
+	// Created by a concurrent request, after this one looked for it.
+	concurrent := &v1.JobExecution{ObjectMeta: metav1.ObjectMeta{
+		Name:      handler.getIdempotentJobExecutionName("default", "test", getIdempotencyKey("event-1"), time.Now()),
+		Namespace: "default",
+	}}
+	if err := handler.Create(context.Background(), concurrent); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/execute/test", strings.NewReader("payload"))
+	req.Header.Set(idempotencyKeyHeader, "event-1")
+	w := httptest.NewRecorder()
+	handler.handle(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusOK, w.Code)
+	}
+	var res jobExecutionResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Name != concurrent.Name {
+		t.Errorf("Expected the concurrent JobExecution, got %v", res)
+	}
+}
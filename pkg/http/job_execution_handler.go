@@ -0,0 +1,219 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cancelRequestsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cancel_requests_total",
+		Help: "The total number of requests to cancel job executions",
+	})
+	cancelRequestsFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cancel_requests_failures_total",
+		Help: "The total number of failed cancel job execution requests",
+	})
+	statusRequestsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "status_requests_total",
+		Help: "The total number of requests to get the status of job executions",
+	})
+	statusRequestsFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "status_requests_failures_total",
+		Help: "The total number of failed job execution status requests",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		cancelRequestsTotal,
+		cancelRequestsFailuresTotal,
+		statusRequestsTotal,
+		statusRequestsFailuresTotal,
+	)
+}
+
+// The response body for requests that create or modify a JobExecution.
+type jobExecutionResponse struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// The response body for requests that get the status of a JobExecution.
+type jobExecutionStatusResponse struct {
+	jobExecutionResponse
+	Conditions      []metav1.Condition          `json:"conditions,omitempty"`
+	StartTime       *metav1.Time                `json:"startTime,omitempty"`
+	CompletionTime  *metav1.Time                `json:"completionTime,omitempty"`
+	Result          string                      `json:"result,omitempty"`
+	ResultTruncated bool                        `json:"resultTruncated,omitempty"`
+	FollowUps       []string                    `json:"followUps,omitempty"`
+	Items           *v1.JobExecutionItemsStatus `json:"items,omitempty"`
+	JobTemplate     *v1.JobTemplateSnapshot     `json:"jobTemplate,omitempty"`
+}
+
+type jobExecutionHandler struct {
+	*Server
+}
+
+func (j *jobExecutionHandler) registerHandler() {
+	http.HandleFunc("/executions/", j.handle)
+}
+
+func (j *jobExecutionHandler) handle(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		j.status(w, req)
+	case http.MethodDelete:
+		j.cancel(w, req)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Cancels a JobExecution, the controller takes care of stopping its Job.
+func (j *jobExecutionHandler) cancel(w http.ResponseWriter, req *http.Request) {
+	cancelRequestsTotal.Inc()
+	ctx := req.Context()
+	log := ctrllog.FromContext(ctx)
+
+	name, ns, err := getNameAndNamespace(req.URL.Path, j.defaultNamespace)
+	if err != nil {
+		cancelRequestsFailuresTotal.Inc()
+		log.Error(err, "Error getting name and namespace")
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	gracePeriodSeconds, err := getGracePeriodSeconds(req.URL.Query())
+	if err != nil {
+		cancelRequestsFailuresTotal.Inc()
+		log.Error(err, "Invalid grace period")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	je := new(v1.JobExecution)
+	if err := j.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, je); err != nil {
+		cancelRequestsFailuresTotal.Inc()
+		if errors.IsNotFound(err) {
+			log.Error(err, "JobExecution doesn't exist", "name", name, "namespace", ns)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Error(err, "Error getting JobExecution", "name", name, "namespace", ns)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	patch := client.MergeFrom(je.DeepCopy())
+	je.Spec.Cancel = true
+	if gracePeriodSeconds != nil {
+		je.Spec.CancelGracePeriodSeconds = gracePeriodSeconds
+	}
+	if err := j.Patch(ctx, je, patch); err != nil {
+		cancelRequestsFailuresTotal.Inc()
+		log.Error(err, "Error cancelling JobExecution", "name", name, "namespace", ns)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Cancelled JobExecution", "name", name, "namespace", ns)
+	writeJobExecution(w, http.StatusAccepted, je)
+}
+
+// Responds with the status of a JobExecution, including its result.
+func (j *jobExecutionHandler) status(w http.ResponseWriter, req *http.Request) {
+	statusRequestsTotal.Inc()
+	ctx := req.Context()
+	log := ctrllog.FromContext(ctx)
+
+	name, ns, err := getNameAndNamespace(req.URL.Path, j.defaultNamespace)
+	if err != nil {
+		statusRequestsFailuresTotal.Inc()
+		log.Error(err, "Error getting name and namespace")
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	je := new(v1.JobExecution)
+	if err := j.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, je); err != nil {
+		statusRequestsFailuresTotal.Inc()
+		if errors.IsNotFound(err) {
+			log.Error(err, "JobExecution doesn't exist", "name", name, "namespace", ns)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Error(err, "Error getting JobExecution", "name", name, "namespace", ns)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := jobExecutionStatusResponse{
+		jobExecutionResponse: jobExecutionResponse{
+			Name:      je.Name,
+			Namespace: je.Namespace,
+		},
+		Conditions:      je.Status.Conditions,
+		StartTime:       je.Status.StartTime,
+		CompletionTime:  je.Status.CompletionTime,
+		Result:          je.Status.Result,
+		ResultTruncated: je.Status.ResultTruncated,
+		FollowUps:       je.Status.FollowUps,
+		Items:           je.Status.Items,
+		JobTemplate:     je.Status.JobTemplate,
+	}
+	if je.Status.ResultConfigMap != nil {
+		configMap := new(corev1.ConfigMap)
+		if err := j.Get(ctx, types.NamespacedName{Name: je.Status.ResultConfigMap.Name, Namespace: ns}, configMap); err != nil {
+			statusRequestsFailuresTotal.Inc()
+			log.Error(err, "Error getting JobExecution result", "name", name, "namespace", ns)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		res.Result = configMap.Data[v1.JobResultConfigMapKey]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// Gets the optional gracePeriodSeconds query parameter.
+func getGracePeriodSeconds(query url.Values) (*int64, error) {
+	if !query.Has("gracePeriodSeconds") {
+		return nil, nil
+	}
+	gracePeriodSeconds, err := strconv.ParseInt(query.Get("gracePeriodSeconds"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if gracePeriodSeconds < 0 {
+		return nil, fmt.Errorf("negative grace period %d", gracePeriodSeconds)
+	}
+	return &gracePeriodSeconds, nil
+}
+
+// Writes the reference to the JobExecution, and its location to the response.
+func writeJobExecution(w http.ResponseWriter, status int, jobExecution *v1.JobExecution) {
+	w.Header().Set("Location", fmt.Sprintf("/executions/%s/%s", jobExecution.Namespace, jobExecution.Name))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(jobExecutionResponse{
+		Name:      jobExecution.Name,
+		Namespace: jobExecution.Namespace,
+	})
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newTestServer(t *testing.T, objects ...runtime.Object) *Server {
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+	return NewServer(":0", "default", false, 1024, time.Hour, RateLimitConfig{}, nil, client, client)
+}
+
+func TestGetGracePeriodSeconds(t *testing.T) {
+	if gracePeriod, err := getGracePeriodSeconds(url.Values{}); err != nil || gracePeriod != nil {
+		t.Errorf("Expected no grace period, got %v, %v", gracePeriod, err)
+	}
+	if gracePeriod, err := getGracePeriodSeconds(url.Values{"gracePeriodSeconds": {"10"}}); err != nil || *gracePeriod != 10 {
+		t.Errorf("Expected grace period to be 10, got %v, %v", gracePeriod, err)
+	}
+	for _, tc := range []string{"-1", "a", ""} {
+		if _, err := getGracePeriodSeconds(url.Values{"gracePeriodSeconds": {tc}}); err == nil {
+			t.Errorf("Expecting error with input %q, got nothing", tc)
+		}
+	}
+}
+
+func TestWriteJobExecution(t *testing.T) {
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-abcde",
+			Namespace: "default",
+		},
+	}
+	w := httptest.NewRecorder()
+	writeJobExecution(w, http.StatusCreated, je)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusCreated, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/executions/default/test-abcde" {
+		t.Errorf("Expected Location to be %q, got %q", "/executions/default/test-abcde", location)
+	}
+	var res jobExecutionResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Error(err)
+		return
+	}
+	if res.Name != "test-abcde" || res.Namespace != "default" {
+		t.Errorf("Unexpected response body %v", res)
+	}
+}
+
+func TestCancelJobExecution(t *testing.T) {
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-abcde",
+			Namespace: "default",
+		},
+		Spec: v1.JobExecutionSpec{
+			JobTemplateName: "test",
+		},
+	}
+	handler := &jobExecutionHandler{newTestServer(t, je)}
+
+	w := httptest.NewRecorder()
+	handler.handle(w, httptest.NewRequest(http.MethodDelete, "/executions/test-abcde?gracePeriodSeconds=5", nil))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	found := new(v1.JobExecution)
+	if err := handler.Get(context.Background(), types.NamespacedName{Name: "test-abcde", Namespace: "default"}, found); err != nil {
+		t.Error(err)
+		return
+	}
+	if !found.Spec.Cancel {
+		t.Error("Expected JobExecution to be cancelled")
+	}
+	if found.Spec.CancelGracePeriodSeconds == nil || *found.Spec.CancelGracePeriodSeconds != 5 {
+		t.Errorf("Expected CancelGracePeriodSeconds to be 5, got %v", found.Spec.CancelGracePeriodSeconds)
+	}
+}
+
+func TestCancelJobExecutionWithAnError(t *testing.T) {
+	handler := &jobExecutionHandler{newTestServer(t)}
+	tt := []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodPost, "/executions/test", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/executions/test", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/executions/", http.StatusNotAcceptable},
+		{http.MethodDelete, "/executions/test?gracePeriodSeconds=a", http.StatusBadRequest},
+		{http.MethodDelete, "/executions/test", http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code {
+			t.Errorf("Expected %s %s status code to be %d, got %d", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+}
+
+func TestGetJobExecutionStatus(t *testing.T) {
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-abcde",
+			Namespace: "default",
+		},
+		Status: v1.JobExecutionStatus{
+			Result: "https://example.com/report",
+		},
+	}
+	spilled := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-fghij",
+			Namespace: "default",
+		},
+		Status: v1.JobExecutionStatus{
+			ResultConfigMap: &corev1.LocalObjectReference{Name: "test-fghij-result"},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-fghij-result",
+			Namespace: "default",
+		},
+		Data: map[string]string{v1.JobResultConfigMapKey: "a large result"},
+	}
+	handler := &jobExecutionHandler{newTestServer(t, je, spilled, configMap)}
+
+	tt := []struct {
+		path, result string
+	}{
+		{"/executions/test-abcde", "https://example.com/report"},
+		{"/executions/default/test-fghij", "a large result"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		handler.handle(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code to be %d, got %d", http.StatusOK, w.Code)
+		}
+		var res jobExecutionStatusResponse
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Error(err)
+			continue
+		}
+		if res.Result != tc.result {
+			t.Errorf("Expected result of %s to be %q, got %q", tc.path, tc.result, res.Result)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.handle(w, httptest.NewRequest(http.MethodGet, "/executions/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
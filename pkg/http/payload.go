@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// The form field with the payload of form-encoded requests.
+const payloadFormField = "payload"
+
+// A payloadError is an error reading the payload of a request, with the
+// status code of the response.
+type payloadError struct {
+	status int
+	err    error
+}
+
+func (e *payloadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *payloadError) Unwrap() error {
+	return e.err
+}
+
+// Reads the payload from the request's body, of up to maxSize bytes, as
+// described by its Content-Type:
+//   - JSON bodies are used as they are, once validated.
+//   - Form-encoded bodies use the value of their payload field, or the whole
+//     body if they don't have one.
+//   - Text bodies, or bodies without a Content-Type, are used as they are.
+//   - Octet-stream bodies are encoded in base64.
+//   - Bodies of other types are used as they are if they're valid UTF-8, or
+//     encoded in base64 otherwise.
+//
+// Returns a payloadError with the status code of the response.
+func readPayload(w http.ResponseWriter, req *http.Request, maxSize int64) (string, error) {
+	mediaType := "text/plain"
+	if contentType := req.Header.Get("Content-Type"); len(contentType) > 0 {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return "", &payloadError{http.StatusUnsupportedMediaType, err}
+		}
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(http.MaxBytesReader(w, req.Body, maxSize)); err != nil {
+			if maxBytesErr := new(http.MaxBytesError); errors.As(err, &maxBytesErr) {
+				return "", &payloadError{http.StatusRequestEntityTooLarge, fmt.Errorf("payload exceeds %d bytes", maxSize)}
+			}
+			return "", &payloadError{http.StatusBadRequest, fmt.Errorf("reading payload: %w", err)}
+		}
+	}
+
+	var payload string
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if len(body) > 0 && !json.Valid(body) {
+			return "", &payloadError{http.StatusBadRequest, errors.New("invalid JSON payload")}
+		}
+		payload = string(body)
+	case mediaType == "application/x-www-form-urlencoded":
+		payload = string(body)
+		if values, err := url.ParseQuery(payload); err == nil && values.Has(payloadFormField) {
+			payload = values.Get(payloadFormField)
+		}
+	case mediaType == "application/octet-stream" || !strings.HasPrefix(mediaType, "text/") && !utf8.Valid(body):
+		payload = base64.StdEncoding.EncodeToString(body)
+		if int64(len(payload)) > maxSize {
+			return "", &payloadError{http.StatusRequestEntityTooLarge, fmt.Errorf("encoded payload exceeds %d bytes", maxSize)}
+		}
+	default:
+		payload = string(body)
+	}
+
+	if !utf8.ValidString(payload) {
+		return "", &payloadError{http.StatusBadRequest, errors.New("payload isn't valid UTF-8, send it as application/octet-stream")}
+	}
+	return payload, nil
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newPayloadRequest(contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/execute/test", strings.NewReader(body))
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func TestReadPayload(t *testing.T) {
+	tt := []struct {
+		contentType, body, expected string
+	}{
+		{"", "my test payload", "my test payload"},
+		{"text/plain; charset=utf-8", "my test payload", "my test payload"},
+		{"application/json", `{"cache": "products"}`, `{"cache": "products"}`},
+		{"application/cloudevents+json", `{"type": "created"}`, `{"type": "created"}`},
+		{"application/json", "", ""},
+		{"application/x-www-form-urlencoded", "payload=%7B%22id%22%3A1%7D&token=abc", `{"id":1}`},
+		{"application/x-www-form-urlencoded", "my test payload", "my test payload"},
+		{"application/x-www-form-urlencoded", `["a", "b"]`, `["a", "b"]`},
+		{"application/octet-stream", "\x00\xff\x10", "AP8Q"},
+		{"application/xml", "<id>1</id>", "<id>1</id>"},
+		{"image/png", "\x00\xff\x10", "AP8Q"},
+	}
+	for _, tc := range tt {
+		payload, err := readPayload(httptest.NewRecorder(), newPayloadRequest(tc.contentType, tc.body), 64)
+		if err != nil {
+			t.Errorf("Unexpected error with %q, %q: %v", tc.contentType, tc.body, err)
+			continue
+		}
+		if payload != tc.expected {
+			t.Errorf("Expected payload %q with %q, got %q", tc.expected, tc.contentType, payload)
+		}
+	}
+}
+
+func TestReadPayloadWithAnError(t *testing.T) {
+	truncated := newPayloadRequest("text/plain", "short")
+	truncated.Body = io.NopCloser(io.MultiReader(strings.NewReader("short"), errorReader{io.ErrUnexpectedEOF}))
+
+	tt := []struct {
+		req    *http.Request
+		status int
+	}{
+		{newPayloadRequest("text/plain", strings.Repeat("a", 33)), http.StatusRequestEntityTooLarge},
+		{newPayloadRequest("application/octet-stream", strings.Repeat("a", 30)), http.StatusRequestEntityTooLarge},
+		{newPayloadRequest("application/json", `{"cache": `), http.StatusBadRequest},
+		{newPayloadRequest("text/plain", "\xff\xfe"), http.StatusBadRequest},
+		{newPayloadRequest("image/png", strings.Repeat("\xff", 30)), http.StatusRequestEntityTooLarge},
+		{newPayloadRequest("text/", "invalid"), http.StatusUnsupportedMediaType},
+		{truncated, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		_, err := readPayload(httptest.NewRecorder(), tc.req, 32)
+		var payloadErr *payloadError
+		if !errors.As(err, &payloadErr) || payloadErr.status != tc.status {
+			t.Errorf("Expected an error with status %d for %q, got %v", tc.status, tc.req.Header.Get("Content-Type"), err)
+		}
+	}
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestExecuteJobWithATooLargePayload(t *testing.T) {
+	handler := &executeJobHandler{newTestServer(t, &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+	})}
+
+	w := httptest.NewRecorder()
+	handler.handle(w, newPayloadRequest("text/plain", strings.Repeat("a", 2048)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// The maximum number of per-key limiters kept. When reached, the idle ones are
+// dropped, and if none is idle, the new keys share an overflow limiter.
+const maxRateLimiters = 1024
+
+// RateLimit is a token bucket that refills at Rate requests per second, up to
+// Burst requests. A non-positive Rate disables it.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig is the rate limits of the requests to dispatch jobs. The
+// limits are kept in memory, so they apply to each replica of the manager.
+type RateLimitConfig struct {
+	// The limit of all the requests.
+	Global RateLimit
+	// The limit of the requests of each JobTemplate.
+	JobTemplate RateLimit
+	// The limit of the requests of each caller.
+	Caller RateLimit
+	// The header with the authenticated caller, e.g. set by an authenticating
+	// proxy. The caller is the client's IP address when it is empty, or the
+	// request doesn't have it.
+	CallerHeader string
+}
+
+// Limits the requests to dispatch jobs globally, per JobTemplate and per
+// caller.
+type rateLimiter struct {
+	callerHeader string
+	global       *rate.Limiter
+	jobTemplates *keyedRateLimiter
+	callers      *keyedRateLimiter
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	l := &rateLimiter{
+		callerHeader: config.CallerHeader,
+		jobTemplates: newKeyedRateLimiter(config.JobTemplate),
+		callers:      newKeyedRateLimiter(config.Caller),
+	}
+	if config.Global.Rate > 0 {
+		l.global = rate.NewLimiter(rate.Limit(config.Global.Rate), max(config.Global.Burst, 1))
+	}
+	return l
+}
+
+// Returns whether the request to dispatch the JobTemplate is allowed and, if
+// it isn't, the time until it would be. A denied request doesn't take any
+// tokens.
+func (l *rateLimiter) allow(req *http.Request, namespace, name string) (bool, time.Duration) {
+	now := time.Now()
+	reservations := []*rate.Reservation{
+		reserve(l.global, now),
+		l.jobTemplates.reserve(namespace+"/"+name, now),
+		l.callers.reserve(l.getCaller(req), now),
+	}
+
+	var delay time.Duration
+	for _, r := range reservations {
+		if r != nil {
+			delay = max(delay, r.DelayFrom(now))
+		}
+	}
+	if delay == 0 {
+		return true, 0
+	}
+	for _, r := range reservations {
+		if r != nil {
+			r.CancelAt(now)
+		}
+	}
+	return false, delay
+}
+
+// Returns the caller of the request, from the caller header or the client's
+// IP address.
+func (l *rateLimiter) getCaller(req *http.Request) string {
+	if len(l.callerHeader) > 0 {
+		if caller := req.Header.Get(l.callerHeader); len(caller) > 0 {
+			return caller
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// Keeps a limiter for each key, with the same limit.
+type keyedRateLimiter struct {
+	limit    RateLimit
+	mutex    sync.Mutex
+	limiters map[string]*rate.Limiter
+	// Shared by the new keys when there are too many limiters.
+	overflow *rate.Limiter
+}
+
+func newKeyedRateLimiter(limit RateLimit) *keyedRateLimiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	limit.Burst = max(limit.Burst, 1)
+	return &keyedRateLimiter{
+		limit:    limit,
+		limiters: make(map[string]*rate.Limiter),
+		overflow: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
+	}
+}
+
+// Reserves a token from the key's limiter, or returns nil if it's disabled.
+func (l *keyedRateLimiter) reserve(key string, now time.Time) *rate.Reservation {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		if len(l.limiters) >= maxRateLimiters {
+			l.dropIdleLimiters(now)
+		}
+		if len(l.limiters) >= maxRateLimiters {
+			// Dropping a limiter that is still throttling would reset it, so
+			// rotating the keys would bypass the limit.
+			return l.overflow.ReserveN(now, 1)
+		}
+		limiter = rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)
+		l.limiters[key] = limiter
+	}
+	return limiter.ReserveN(now, 1)
+}
+
+// Drops the limiters with all their tokens, which behave as new ones.
+func (l *keyedRateLimiter) dropIdleLimiters(now time.Time) {
+	for key, limiter := range l.limiters {
+		if limiter.TokensAt(now) >= float64(l.limit.Burst) {
+			delete(l.limiters, key)
+		}
+	}
+}
+
+// Reserves a token from the limiter, or returns nil if it's disabled.
+func reserve(limiter *rate.Limiter, now time.Time) *rate.Reservation {
+	if limiter == nil {
+		return nil
+	}
+	return limiter.ReserveN(now, 1)
+}
+
+// Responds that the request exceeded the rate limit, and when to retry it.
+func writeTooManyRequests(w http.ResponseWriter, delay time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newRateLimitedRequest(remoteAddr, caller string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/execute/test", nil)
+	req.RemoteAddr = remoteAddr
+	if len(caller) > 0 {
+		req.Header.Set("X-Remote-User", caller)
+	}
+	return req
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{})
+	for range 100 {
+		if ok, _ := limiter.allow(newRateLimitedRequest("10.0.0.1:1234", ""), "default", "test"); !ok {
+			t.Fatal("Expected the request to be allowed")
+		}
+	}
+}
+
+func TestRateLimiterGlobal(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{Global: RateLimit{Rate: 1, Burst: 2}})
+	for i := range 2 {
+		if ok, _ := limiter.allow(newRateLimitedRequest("10.0.0.1:1234", ""), "default", "test"); !ok {
+			t.Errorf("Expected request %d to be allowed", i)
+		}
+	}
+	ok, delay := limiter.allow(newRateLimitedRequest("10.0.0.2:1234", ""), "default", "other")
+	if ok {
+		t.Error("Expected the request to exceed the global limit")
+	}
+	if delay <= 0 || delay > time.Second {
+		t.Errorf("Expected to retry within a second, got %v", delay)
+	}
+}
+
+func TestRateLimiterPerJobTemplateAndCaller(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		JobTemplate:  RateLimit{Rate: 0.1, Burst: 2},
+		Caller:       RateLimit{Rate: 0.1, Burst: 1},
+		CallerHeader: "X-Remote-User",
+	})
+	tt := []struct {
+		remoteAddr, caller, name string
+		allowed                  bool
+	}{
+		{"10.0.0.1:1234", "", "test", true},
+		{"10.0.0.1:5678", "", "other", false},
+		{"10.0.0.1:1234", "alice", "test", true},
+		{"10.0.0.2:1234", "", "test", false},
+		{"10.0.0.2:1234", "", "other", true},
+		{"10.0.0.3:1234", "bob", "other", true},
+	}
+	for _, tc := range tt {
+		ok, delay := limiter.allow(newRateLimitedRequest(tc.remoteAddr, tc.caller), "default", tc.name)
+		if ok != tc.allowed {
+			t.Errorf("Expected request from %s %q to %s to be allowed %t, got %t", tc.remoteAddr, tc.caller, tc.name, tc.allowed, ok)
+		}
+		if !ok && (delay <= 0 || delay > 10*time.Second) {
+			t.Errorf("Expected to retry within 10 seconds, got %v", delay)
+		}
+	}
+}
+
+func TestKeyedRateLimiterDropsIdleLimiters(t *testing.T) {
+	limiter := newKeyedRateLimiter(RateLimit{Rate: 1000, Burst: 1})
+	now := time.Now()
+	for i := range maxRateLimiters {
+		limiter.reserve(strings.Repeat("a", i), now)
+	}
+	limiter.reserve("new", now.Add(time.Second))
+	if len(limiter.limiters) != 1 {
+		t.Errorf("Expected the idle limiters to be dropped, got %d limiters", len(limiter.limiters))
+	}
+}
+
+func TestKeyedRateLimiterIsBounded(t *testing.T) {
+	limiter := newKeyedRateLimiter(RateLimit{Rate: 0.001, Burst: 1})
+	now := time.Now()
+	for i := range maxRateLimiters {
+		limiter.reserve(strings.Repeat("a", i+1), now)
+	}
+	if r := limiter.reserve("a", now.Add(time.Second)); r.DelayFrom(now.Add(time.Second)) == 0 {
+		t.Error("Expected the limiter of \"a\" to keep throttling")
+	}
+	if r := limiter.reserve("new", now.Add(time.Second)); r.DelayFrom(now.Add(time.Second)) > 0 {
+		t.Error("Expected the first new key to take the overflow limiter's token")
+	}
+	if r := limiter.reserve("newer", now.Add(time.Second)); r.DelayFrom(now.Add(time.Second)) == 0 {
+		t.Error("Expected the new keys to share the overflow limiter")
+	}
+	if len(limiter.limiters) != maxRateLimiters {
+		t.Errorf("Expected %d limiters, got %d", maxRateLimiters, len(limiter.limiters))
+	}
+}
+
+func TestExecuteJobExceedingTheRateLimit(t *testing.T) {
+	server := newTestServer(t, &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+	})
+	server.rateLimiter = newRateLimiter(RateLimitConfig{Caller: RateLimit{Rate: 0.5, Burst: 1}})
+	handler := &executeJobHandler{server}
+
+	w := httptest.NewRecorder()
+	handler.handle(w, httptest.NewRequest(http.MethodPut, "/execute/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.handle(w, httptest.NewRequest(http.MethodPut, "/execute/test", nil))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.handle(w, httptest.NewRequest(http.MethodPut, "/execute/test", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code to be %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected Retry-After to be 2, got %q", retryAfter)
+	}
+}
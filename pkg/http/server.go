@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -13,6 +14,14 @@ type Server struct {
 	client.Client
 	defaultNamespace        string
 	logJobExecutionPayloads bool
+	idempotencyKeyWindow    time.Duration
+	maxPayloadSize          int64
+	rateLimiter             *rateLimiter
+	// The hosts that the callbacks requested through the API can POST to.
+	callbackHosts []string
+	// Reads the JobExecutions with an idempotency key from the API server,
+	// as the cache may not have the ones just created.
+	reader client.Reader
 }
 
 // NeedLeaderElection implements the LeaderElectionRunnable interface, which
@@ -21,8 +30,18 @@ func (*Server) NeedLeaderElection() bool {
 	return false
 }
 
-func NewServer(address, defaultNamespace string, logJobExecutionPayloads bool, client client.Client) *Server {
-	return &Server{&http.Server{Addr: address}, client, defaultNamespace, logJobExecutionPayloads}
+func NewServer(address, defaultNamespace string, logJobExecutionPayloads bool, maxPayloadSize int64, idempotencyKeyWindow time.Duration, rateLimits RateLimitConfig, callbackHosts []string, client client.Client, reader client.Reader) *Server {
+	return &Server{
+		Server:                  &http.Server{Addr: address},
+		Client:                  client,
+		defaultNamespace:        defaultNamespace,
+		logJobExecutionPayloads: logJobExecutionPayloads,
+		maxPayloadSize:          maxPayloadSize,
+		idempotencyKeyWindow:    idempotencyKeyWindow,
+		rateLimiter:             newRateLimiter(rateLimits),
+		callbackHosts:           callbackHosts,
+		reader:                  reader,
+	}
 }
 
 // Starts the Web server.
@@ -40,4 +59,5 @@ func (s *Server) Start(ctx context.Context) error {
 
 func (s *Server) registerHandlers() {
 	(&executeJobHandler{s}).registerHandler()
+	(&jobExecutionHandler{s}).registerHandler()
 }
@@ -0,0 +1,187 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migration migrates the dispatcher's stored resources to their
+// storage version.
+package migration
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+const (
+	// The number of objects listed per request.
+	migrationPageSize = 500
+	// The interval between attempts to migrate a resource.
+	migrationRetryInterval = 30 * time.Second
+)
+
+var log = ctrl.Log.WithName("migration")
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=get;update
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobtemplates,verbs=get;list;update
+//+kubebuilder:rbac:groups=dispatcher.ivan.vc,resources=jobexecutions,verbs=get;list;update
+
+// StorageVersionMigrator rewrites the objects of the resources that were
+// stored in a previous version in their storage version, and then drops the
+// previous versions from the CustomResourceDefinition's stored versions, so
+// they can be eventually removed from the API. The objects are converted by
+// the conversion webhook, which has to be served.
+type StorageVersionMigrator struct {
+	client.Client
+	// Reader reads directly from the API server, instead of the cache.
+	Reader client.Reader
+}
+
+// A resource to migrate.
+type storageVersionResource struct {
+	name    string
+	newList func() client.ObjectList
+}
+
+// The resources with more than one version.
+var storageVersionResources = []storageVersionResource{
+	{
+		name:    "jobtemplates." + v1beta1.GroupVersion.Group,
+		newList: func() client.ObjectList { return new(v1beta1.JobTemplateList) },
+	},
+	{
+		name:    "jobexecutions." + v1.GroupVersion.Group,
+		newList: func() client.ObjectList { return new(v1.JobExecutionList) },
+	},
+}
+
+// Start migrates the resources, retrying each one until it's migrated or the
+// context is done. It implements manager.Runnable, and runs only in the
+// leader.
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	for _, resource := range storageVersionResources {
+		err := wait.PollUntilContextCancel(ctx, migrationRetryInterval, true, func(ctx context.Context) (bool, error) {
+			if err := m.migrate(ctx, resource); err != nil {
+				log.Error(err, "Error migrating resource to its storage version", "resource", resource.name)
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			// The context is done, the manager is stopping.
+			return nil
+		}
+	}
+	return nil
+}
+
+// Migrates the resource's objects if the CustomResourceDefinition has
+// versions stored other than its storage version.
+func (m *StorageVersionMigrator) migrate(ctx context.Context, resource storageVersionResource) error {
+	crd := new(apiextensionsv1.CustomResourceDefinition)
+	if err := m.Reader.Get(ctx, types.NamespacedName{Name: resource.name}, crd); err != nil {
+		return err
+	}
+	storageVersion, err := getStorageVersion(crd)
+	if err != nil {
+		return err
+	}
+	if slices.Equal(crd.Status.StoredVersions, []string{storageVersion}) {
+		return nil
+	}
+
+	log.Info("Migrating resource to its storage version", "resource", resource.name,
+		"storedVersions", crd.Status.StoredVersions, "storageVersion", storageVersion)
+	count, skipped, err := m.rewriteObjects(ctx, resource)
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		// The skipped objects are still stored in the previous versions, which
+		// have to be kept until they're fixed and the manager restarts.
+		log.Info("Skipped the objects whose admission was rejected, keeping the stored versions", "resource", resource.name,
+			"objects", count, "skipped", skipped)
+		return nil
+	}
+
+	crd.Status.StoredVersions = []string{storageVersion}
+	if err := m.Status().Update(ctx, crd); err != nil {
+		return err
+	}
+	log.Info("Migrated resource to its storage version", "resource", resource.name, "objects", count)
+	return nil
+}
+
+// Updates every object of the resource without changes, so the API server
+// stores it again in the storage version. Returns the number of objects, and
+// the ones skipped because an admission webhook rejected them.
+func (m *StorageVersionMigrator) rewriteObjects(ctx context.Context, resource storageVersionResource) (int, []string, error) {
+	count := 0
+	var skipped []string
+	opts := []client.ListOption{client.Limit(migrationPageSize)}
+	for {
+		list := resource.newList()
+		if err := m.Reader.List(ctx, list, opts...); err != nil {
+			return count, skipped, err
+		}
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			return count, skipped, err
+		}
+		for _, o := range objects {
+			obj := o.(client.Object)
+			// A conflict means the object was written after it was listed,
+			// hence it's already in the storage version.
+			err := m.Update(ctx, obj)
+			if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+				log.Error(err, "Skipping object rejected by the admission", "resource", resource.name,
+					"namespace", obj.GetNamespace(), "name", obj.GetName())
+				skipped = append(skipped, client.ObjectKeyFromObject(obj).String())
+				continue
+			} else if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+				return count, skipped, fmt.Errorf("rewriting %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+			}
+			count++
+		}
+
+		cont := list.GetContinue()
+		if len(cont) == 0 {
+			return count, skipped, nil
+		}
+		opts = []client.ListOption{client.Limit(migrationPageSize), client.Continue(cont)}
+	}
+}
+
+// Returns the CustomResourceDefinition's storage version.
+func getStorageVersion(crd *apiextensionsv1.CustomResourceDefinition) (string, error) {
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			return version.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no storage version", crd.Name)
+}
@@ -0,0 +1,150 @@
+package migration
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newTestMigrator(t *testing.T, objects ...client.Object) *StorageVersionMigrator {
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&apiextensionsv1.CustomResourceDefinition{}).
+		Build()
+	return &StorageVersionMigrator{Client: c, Reader: c}
+}
+
+func newTestCRD(name string, storedVersions ...string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1beta1", Served: true, Storage: true},
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+}
+
+func TestStorageVersionMigratorRewritesObjects(t *testing.T) {
+	resource := storageVersionResources[0]
+	jts := []client.Object{
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"}},
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "other"}},
+	}
+	m := newTestMigrator(t, append(jts, newTestCRD(resource.name, "v1alpha1", "v1beta1"))...)
+	ctx := context.Background()
+
+	if err := m.migrate(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, obj := range jts {
+		jt := new(v1beta1.JobTemplate)
+		if err := m.Get(ctx, client.ObjectKeyFromObject(obj), jt); err != nil {
+			t.Fatal(err)
+		}
+		if jt.ResourceVersion == obj.GetResourceVersion() {
+			t.Errorf("Expected JobTemplate %s to be rewritten", jt.Name)
+		}
+	}
+	crd := new(apiextensionsv1.CustomResourceDefinition)
+	if err := m.Get(ctx, types.NamespacedName{Name: resource.name}, crd); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(crd.Status.StoredVersions, []string{"v1beta1"}) {
+		t.Errorf("Expected stored versions to be %v, got %v", []string{"v1beta1"}, crd.Status.StoredVersions)
+	}
+}
+
+func TestStorageVersionMigratorSkipsRejectedObjects(t *testing.T) {
+	resource := storageVersionResources[0]
+	jts := []client.Object{
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"}},
+		&v1beta1.JobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "default"}},
+	}
+	m := newTestMigrator(t, append(jts, newTestCRD(resource.name, "v1alpha1", "v1beta1"))...)
+	m.Client = interceptor.NewClient(m.Client.(client.WithWatch), interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if obj.GetName() == "invalid" {
+				return apierrors.NewInvalid(v1beta1.GroupVersion.WithKind("JobTemplate").GroupKind(), obj.GetName(), field.ErrorList{})
+			}
+			return c.Update(ctx, obj, opts...)
+		},
+	})
+	ctx := context.Background()
+
+	if err := m.migrate(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	jt := new(v1beta1.JobTemplate)
+	if err := m.Get(ctx, client.ObjectKeyFromObject(jts[0]), jt); err != nil {
+		t.Fatal(err)
+	}
+	if jt.ResourceVersion == jts[0].GetResourceVersion() {
+		t.Errorf("Expected JobTemplate %s to be rewritten", jt.Name)
+	}
+	crd := new(apiextensionsv1.CustomResourceDefinition)
+	if err := m.Get(ctx, types.NamespacedName{Name: resource.name}, crd); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"v1alpha1", "v1beta1"}; !slices.Equal(crd.Status.StoredVersions, expected) {
+		t.Errorf("Expected stored versions to be %v, got %v", expected, crd.Status.StoredVersions)
+	}
+}
+
+func TestStorageVersionMigratorSkipsMigratedResources(t *testing.T) {
+	resource := storageVersionResources[1]
+	je := &v1.JobExecution{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"}}
+	m := newTestMigrator(t, je, newTestCRD(resource.name, "v1beta1"))
+	ctx := context.Background()
+
+	if err := m.migrate(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(v1.JobExecution)
+	if err := m.Get(ctx, client.ObjectKeyFromObject(je), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ResourceVersion != je.ResourceVersion {
+		t.Errorf("Expected JobExecution not to be rewritten, got resource version %s", got.ResourceVersion)
+	}
+}
+
+func TestStorageVersionMigratorWithoutAStorageVersion(t *testing.T) {
+	resource := storageVersionResources[0]
+	crd := newTestCRD(resource.name, "v1alpha1")
+	crd.Spec.Versions[1].Storage = false
+	m := newTestMigrator(t, crd)
+
+	if err := m.migrate(context.Background(), resource); err == nil {
+		t.Error("Expected an error for a CustomResourceDefinition without a storage version")
+	}
+}
@@ -0,0 +1,148 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig is the SMTP server used to send emails.
+type SMTPConfig struct {
+	// The address of the server, in the form of host:port.
+	Address  string
+	From     string
+	Username string
+	Password string
+}
+
+// Email sends notifications as an email to its recipients.
+type Email struct {
+	Config *SMTPConfig
+	To     []string
+	// The time limit to send the email, including dialing the server. Zero
+	// means no limit other than the context's.
+	Timeout time.Duration
+}
+
+// Send emails the payload's summary.
+func (e *Email) Send(ctx context.Context, payload *Payload) error {
+	if e.Config == nil || len(e.Config.Address) == 0 {
+		return errors.New("SMTP server is not configured")
+	}
+
+	// The envelope has the bare addresses, and the headers keep their display
+	// names.
+	from := e.Config.From
+	if len(from) > 0 {
+		address, err := mail.ParseAddress(from)
+		if err != nil {
+			return fmt.Errorf("invalid sender %q: %w", from, err)
+		}
+		from = address.Address
+	}
+	recipients := make([]string, len(e.To))
+	for i, to := range e.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		recipients[i] = address.Address
+	}
+
+	host, _, err := net.SplitHostPort(e.Config.Address)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if len(e.Config.Username) > 0 {
+		auth = smtp.PlainAuth("", e.Config.Username, e.Config.Password, host)
+	}
+
+	subject := fmt.Sprintf("[dispatcher] JobExecution %s/%s", payload.Namespace, payload.Name)
+	if len(payload.Outcome) > 0 {
+		subject += " " + payload.Outcome
+	} else if len(payload.Event) > 0 {
+		subject += " " + payload.Event
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.Config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(payload.Summary(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return e.sendMail(ctx, host, auth, from, recipients, msg.Bytes())
+}
+
+// Sends the message like smtp.SendMail, but bounding the whole conversation
+// with the server by the context and the Email's timeout.
+func (e *Email) sendMail(ctx context.Context, host string, auth smtp.Auth, from string, recipients []string, msg []byte) error {
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.Config.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	// Unblocks the conversation if the context is canceled before its deadline.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("SMTP server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range recipients {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,152 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serves a single SMTP conversation, sending the received commands and
+// message to the channel.
+func serveSMTP(l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var b strings.Builder
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		b.WriteString(line)
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250 localhost")
+		case cmd == "DATA":
+			reply("354 Go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				b.WriteString(line)
+			}
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			received <- b.String()
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+	received <- b.String()
+}
+
+func TestSendEmail(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go serveSMTP(l, received)
+
+	email := &Email{
+		Config:  &SMTPConfig{Address: l.Addr().String(), From: "dispatcher@example.com"},
+		To:      []string{"team@example.com"},
+		Timeout: 5 * time.Second,
+	}
+	payload := &Payload{Name: "test-abcde", Namespace: "default", Event: "Failed", Outcome: "Failed"}
+	if err := email.Send(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-received
+	if !strings.Contains(msg, "MAIL FROM:<dispatcher@example.com>") || !strings.Contains(msg, "RCPT TO:<team@example.com>") {
+		t.Errorf("Unexpected email %q", msg)
+	}
+	if !strings.Contains(msg, "Subject: [dispatcher] JobExecution default/test-abcde Failed\r\n") {
+		t.Errorf("Unexpected message %q", msg)
+	}
+}
+
+func TestSendEmailWithDisplayNames(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go serveSMTP(l, received)
+
+	email := &Email{
+		Config:  &SMTPConfig{Address: l.Addr().String(), From: "Dispatcher <dispatcher@example.com>"},
+		To:      []string{"Ops <ops@example.com>", "team@example.com"},
+		Timeout: 5 * time.Second,
+	}
+	if err := email.Send(context.Background(), &Payload{Name: "test-abcde", Namespace: "default"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-received
+	for _, s := range []string{
+		"MAIL FROM:<dispatcher@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<team@example.com>",
+		"From: Dispatcher <dispatcher@example.com>\r\n",
+		"To: Ops <ops@example.com>, team@example.com\r\n",
+	} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("Expected email to contain %q, got %q", s, msg)
+		}
+	}
+}
+
+func TestSendEmailWithATimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// Accepts the connection, but never greets the client.
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	email := &Email{
+		Config:  &SMTPConfig{Address: l.Addr().String(), From: "dispatcher@example.com"},
+		To:      []string{"team@example.com"},
+		Timeout: 100 * time.Millisecond,
+	}
+	start := time.Now()
+	if err := email.Send(context.Background(), &Payload{}); err == nil {
+		t.Error("Expected a timeout error, got nothing")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to time out after 100ms, got %s", elapsed)
+	}
+}
+
+func TestSendEmailWithAnError(t *testing.T) {
+	tt := []*Email{
+		{To: []string{"team@example.com"}},
+		{Config: &SMTPConfig{Address: "smtp.example.com:25"}, To: []string{"team@example.com\r\nBcc: x@example.com"}},
+	}
+	for _, tc := range tt {
+		if err := tc.Send(context.Background(), &Payload{}); err == nil {
+			t.Errorf("Expected an error sending %v, got nothing", tc)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Payload is the body of the notification sent for a JobExecution's lifecycle
+// event. The outcome is only set once it finishes.
+type Payload struct {
+	Event           string       `json:"event,omitempty"`
+	Name            string       `json:"name"`
+	Namespace       string       `json:"namespace"`
+	JobTemplateName string       `json:"jobTemplateName"`
+	Outcome         string       `json:"outcome,omitempty"`
+	Reason          string       `json:"reason,omitempty"`
+	Message         string       `json:"message,omitempty"`
+	StartTime       *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime  *metav1.Time `json:"completionTime,omitempty"`
+	Attempts        int32        `json:"attempts,omitempty"`
+	Result          string       `json:"result,omitempty"`
+	ResultTruncated bool         `json:"resultTruncated,omitempty"`
+}
+
+// Summary returns a human readable description of the payload.
+func (p *Payload) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "JobExecution %s/%s from JobTemplate %s", p.Namespace, p.Name, p.JobTemplateName)
+	if len(p.Outcome) > 0 {
+		fmt.Fprintf(&b, " finished: %s", p.Outcome)
+	} else if len(p.Event) > 0 {
+		fmt.Fprintf(&b, ": %s", p.Event)
+	}
+	if len(p.Message) > 0 {
+		fmt.Fprintf(&b, "\n%s", p.Message)
+	}
+	if len(p.Result) > 0 {
+		fmt.Fprintf(&b, "\nResult: %s", p.Result)
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package notification
+
+import "testing"
+
+func TestPayloadSummary(t *testing.T) {
+	tt := []struct {
+		payload  Payload
+		expected string
+	}{
+		{
+			Payload{Name: "test-abcde", Namespace: "default", JobTemplateName: "test", Event: "Started"},
+			"JobExecution default/test-abcde from JobTemplate test: Started",
+		},
+		{
+			Payload{Name: "test-abcde", Namespace: "default", JobTemplateName: "test", Event: "Succeeded", Outcome: "Succeeded", Message: "Job ran successfully", Result: "42"},
+			"JobExecution default/test-abcde from JobTemplate test finished: Succeeded\nJob ran successfully\nResult: 42",
+		},
+	}
+	for _, tc := range tt {
+		if summary := tc.payload.Summary(); summary != tc.expected {
+			t.Errorf("Expected summary to be %q, got %q", tc.expected, summary)
+		}
+	}
+}
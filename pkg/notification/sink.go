@@ -0,0 +1,8 @@
+package notification
+
+import "context"
+
+// Sink sends the notifications of a JobExecution's lifecycle events.
+type Sink interface {
+	Send(ctx context.Context, payload *Payload) error
+}
@@ -0,0 +1,40 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendSlack(t *testing.T) {
+	var received slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	slack := &Slack{URL: server.URL}
+	payload := &Payload{Name: "test-abcde", Namespace: "default", JobTemplateName: "test", Event: "Started"}
+	if err := slack.Send(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if received.Text != payload.Summary() {
+		t.Errorf("Expected text to be %q, got %q", payload.Summary(), received.Text)
+	}
+}
+
+func TestSendSlackWithAnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	slack := &Slack{URL: server.URL}
+	if err := slack.Send(context.Background(), &Payload{}); err == nil {
+		t.Error("Expected an error, got nothing")
+	}
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendWebhook(t *testing.T) {
+	var signature string
+	var received Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		signature = req.Header.Get(SignatureHeader)
+		if sign := Sign([]byte("secret"), body); signature != sign {
+			t.Errorf("Expected signature to be %q, got %q", sign, signature)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{URL: server.URL, Secret: []byte("secret")}
+	if err := webhook.Send(context.Background(), &Payload{Name: "test-abcde", Outcome: "Succeeded"}); err != nil {
+		t.Fatal(err)
+	}
+	if received.Name != "test-abcde" || received.Outcome != "Succeeded" {
+		t.Errorf("Unexpected payload %v", received)
+	}
+	if len(signature) == 0 {
+		t.Error("Expected request to be signed")
+	}
+}
+
+func TestSendWebhookWithoutASecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if signature := req.Header.Get(SignatureHeader); len(signature) > 0 {
+			t.Errorf("Expected no signature, got %q", signature)
+		}
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{URL: server.URL}
+	if err := webhook.Send(context.Background(), &Payload{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSendWebhookWithAnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{URL: server.URL}
+	if err := webhook.Send(context.Background(), &Payload{}); err == nil {
+		t.Error("Expected an error, got nothing")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n 'body' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=dc46983557fea127b43af721467eb9b3fde2338fe3e14f51952aa8478c13d355"
+	if signature := Sign([]byte("secret"), []byte("body")); signature != expected {
+		t.Errorf("Expected signature to be %q, got %q", expected, signature)
+	}
+}
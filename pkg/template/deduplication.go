@@ -0,0 +1,27 @@
+package template
+
+import (
+	"strings"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+// RenderDeduplicationKey renders the template of an execution's
+// deduplication key, or returns its payload if the template is empty. Unlike
+// the Job's templates, it fails if the template accesses a missing key, so
+// executions without it aren't coalesced.
+func RenderDeduplicationKey(text string, jobExecution *v1.JobExecution) (string, error) {
+	if len(text) == 0 {
+		return jobExecution.Spec.Payload, nil
+	}
+	tpl, err := newTemplate("deduplication").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tpl.Execute(&b, newEnvironment(jobExecution)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,34 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+)
+
+func TestRenderDeduplicationKey(t *testing.T) {
+	je := &v1.JobExecution{Spec: v1.JobExecutionSpec{Payload: `{"cache": "products", "id": 42}`}}
+	tt := []struct {
+		text, expected string
+	}{
+		{"", `{"cache": "products", "id": 42}`},
+		{"{{ (fromJson .Payload).cache }}", "products"},
+		{"{{ .Payload | sha256sum | trunc 8 }}", "1651bf7a"},
+	}
+	for _, tc := range tt {
+		key, err := RenderDeduplicationKey(tc.text, je)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if key != tc.expected {
+			t.Errorf("Expected key %q for %q, got %q", tc.expected, tc.text, key)
+		}
+	}
+
+	for _, text := range []string{"{{ .Missing }}", "{{ (fromJson .Payload).missing }}"} {
+		if _, err := RenderDeduplicationKey(text, je); err == nil {
+			t.Errorf("Expecting error with %q, got nothing", text)
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package template
 
-import "github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+import v1 "github.com/ivanvc/dispatcher/pkg/api/v1"
 
 type Environment struct {
 	Name    string
 	Payload string
+	// The values of the JobTemplate's parameters.
+	Parameters map[string]string
+	// The execution that triggered this one, nil unless it's a follow-up.
+	Trigger *v1.ExecutionTrigger
 }
 
-func newEnvironment(jobExecution *v1beta1.JobExecution) *Environment {
+func newEnvironment(jobExecution *v1.JobExecution) *Environment {
 	return &Environment{
-		Name:    jobExecution.ObjectMeta.Name,
-		Payload: jobExecution.Spec.Payload,
+		Name:       jobExecution.ObjectMeta.Name,
+		Payload:    jobExecution.Spec.Payload,
+		Parameters: getParameterValues(jobExecution.Spec.Parameters),
+		Trigger:    jobExecution.Spec.Trigger,
 	}
 }
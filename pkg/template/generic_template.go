@@ -1,6 +1,7 @@
 package template
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"text/template"
@@ -18,27 +19,28 @@ func newGenericTemplate(target any, env *Environment) *genericTemplate {
 }
 
 func (t *genericTemplate) execute() error {
-	return t.recursivelyExecuteTemplate(reflect.ValueOf(t.target))
+	return t.recursivelyExecuteTemplate(reflect.ValueOf(t.target), "")
 }
 
-func (t *genericTemplate) recursivelyExecuteTemplate(value reflect.Value) error {
+func (t *genericTemplate) recursivelyExecuteTemplate(value reflect.Value, path string) error {
 	if value.Kind() == reflect.Pointer {
 		value = value.Elem()
 	}
 
 	for i := 0; i < value.NumField(); i++ {
 		field := value.Field(i)
+		fieldPath := getFieldPath(path, value.Type().Field(i))
 
 		switch field.Kind() {
 		case reflect.String:
-			if err := t.replaceValueWithRenderedTemplate(field); err != nil {
+			if err := t.replaceValueWithRenderedTemplate(field, fieldPath); err != nil {
 				return err
 			}
 		case reflect.Slice, reflect.Array:
 			if field.IsNil() {
 				continue
 			}
-			if err := t.walkSlice(field); err != nil {
+			if err := t.walkSlice(field, fieldPath); err != nil {
 				return err
 			}
 		case reflect.Pointer:
@@ -47,16 +49,16 @@ func (t *genericTemplate) recursivelyExecuteTemplate(value reflect.Value) error
 			}
 			switch field.Elem().Kind() {
 			case reflect.Slice, reflect.Array:
-				if err := t.walkSlice(field.Elem()); err != nil {
+				if err := t.walkSlice(field.Elem(), fieldPath); err != nil {
 					return err
 				}
 			case reflect.Struct:
-				if err := t.recursivelyExecuteTemplate(field); err != nil {
+				if err := t.recursivelyExecuteTemplate(field, fieldPath); err != nil {
 					return err
 				}
 			}
 		case reflect.Struct:
-			if err := t.recursivelyExecuteTemplate(field); err != nil {
+			if err := t.recursivelyExecuteTemplate(field, fieldPath); err != nil {
 				return err
 			}
 		}
@@ -65,18 +67,19 @@ func (t *genericTemplate) recursivelyExecuteTemplate(value reflect.Value) error
 	return nil
 }
 
-func (t *genericTemplate) walkSlice(field reflect.Value) error {
+func (t *genericTemplate) walkSlice(field reflect.Value, path string) error {
 	for j := 0; j < field.Len(); j++ {
 		innerField := field.Index(j)
+		innerPath := fmt.Sprintf("%s[%d]", path, j)
 
 		if innerField.Kind() == reflect.String {
-			if err := t.replaceValueWithRenderedTemplate(innerField); err != nil {
+			if err := t.replaceValueWithRenderedTemplate(innerField, innerPath); err != nil {
 				return err
 			}
 		}
 
 		if innerField.Kind() == reflect.Struct || innerField.Kind() == reflect.Pointer && innerField.Elem().Kind() == reflect.Struct {
-			if err := t.recursivelyExecuteTemplate(innerField); err != nil {
+			if err := t.recursivelyExecuteTemplate(innerField, innerPath); err != nil {
 				return err
 			}
 		}
@@ -85,22 +88,57 @@ func (t *genericTemplate) walkSlice(field reflect.Value) error {
 	return nil
 }
 
-func (t *genericTemplate) replaceValueWithRenderedTemplate(value reflect.Value) error {
+func (t *genericTemplate) replaceValueWithRenderedTemplate(value reflect.Value, path string) error {
 	if !value.CanSet() {
 		return nil
 	}
 
-	tpl, err := template.New(value.Type().Name()).Funcs(sprig.TxtFuncMap()).Parse(value.String())
+	rendered, err := render(value.Type().Name(), value.String(), t.env)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", path, err)
 	}
 
-	var b strings.Builder
-	if err := tpl.Execute(&b, t.env); err != nil {
-		return err
+	value.SetString(rendered)
+
+	return nil
+}
+
+// Returns the path of a struct's field, using its JSON name. Inlined fields
+// don't add to the path.
+func getFieldPath(path string, field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if len(name) == 0 {
+		if field.Anonymous {
+			return path
+		}
+		name = field.Name
+	}
+	if len(path) == 0 {
+		return name
 	}
+	return path + "." + name
+}
 
-	value.SetString(b.String())
+// Parse returns an error if the text isn't a valid template, using the same
+// functions available when rendering it.
+func Parse(text string) error {
+	_, err := newTemplate("").Parse(text)
+	return err
+}
 
-	return nil
+func newTemplate(name string) *template.Template {
+	return template.New(name).Funcs(sprig.TxtFuncMap())
+}
+
+func render(name, text string, data any) (string, error) {
+	tpl, err := newTemplate(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
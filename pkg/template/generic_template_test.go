@@ -1,13 +1,18 @@
 package template
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+)
 
 func TestExecuteTemplateInStringField(t *testing.T) {
 	type input struct {
 		A, B string
 		C    int
 	}
-	tpl := newGenericTemplate(&input{"{{.Payload}}", "text", 6}, &Environment{"Name", "Replaced"})
+	tpl := newGenericTemplate(&input{"{{.Payload}}", "text", 6}, &Environment{Name: "Name", Payload: "Replaced"})
 	if err := tpl.execute(); err != nil {
 		t.Error(err)
 		return
@@ -28,7 +33,7 @@ func TestExecuteTemplateInEmbeddedField(t *testing.T) {
 		E3 *Embedded
 	}
 	in := &input{E1: &Embedded{"{{.Name | upper}}"}, E2: Embedded{"{{.Payload}}"}}
-	tpl := newGenericTemplate(in, &Environment{"Name", "Replaced"})
+	tpl := newGenericTemplate(in, &Environment{Name: "Name", Payload: "Replaced"})
 	if err := tpl.execute(); err != nil {
 		t.Error(err)
 		return
@@ -43,7 +48,7 @@ func TestExecuteTemplateInEmbeddedField(t *testing.T) {
 
 func TestExecuteTemplateInSlice(t *testing.T) {
 	type input struct{ A []string }
-	tpl := newGenericTemplate(&input{[]string{"{{.Name}}", "1", "1{{.Payload}}3"}}, &Environment{"Name", "2"})
+	tpl := newGenericTemplate(&input{[]string{"{{.Name}}", "1", "1{{.Payload}}3"}}, &Environment{Name: "Name", Payload: "2"})
 	if err := tpl.execute(); err != nil {
 		t.Error(err)
 		return
@@ -66,7 +71,7 @@ func TestExecuteTemplateInEmbbededSlice(t *testing.T) {
 		E1: &([]Embedded{Embedded{"{{.Payload}}"}}),
 		E2: []Embedded{Embedded{"{{.Name}}"}},
 	}
-	tpl := newGenericTemplate(in, &Environment{"2", "1"})
+	tpl := newGenericTemplate(in, &Environment{Name: "2", Payload: "1"})
 	if err := tpl.execute(); err != nil {
 		t.Error(err)
 		return
@@ -90,7 +95,7 @@ func TestExecuteTemplateWithAnError(t *testing.T) {
 func TestExecuteTemplateWithComplexSprigFunction(t *testing.T) {
 	type input struct{ A string }
 	in := &input{`{{.Payload | replace "\n" ""}}`}
-	tpl := newGenericTemplate(in, &Environment{"", `{
+	tpl := newGenericTemplate(in, &Environment{Payload: `{
 "hello": "world"
 }`})
 	if err := tpl.execute(); err != nil {
@@ -101,3 +106,45 @@ func TestExecuteTemplateWithComplexSprigFunction(t *testing.T) {
 		t.Error("Mismatch in generated output", in.A)
 	}
 }
+
+func TestExecuteTemplateWithATrigger(t *testing.T) {
+	type input struct{ A string }
+	in := &input{`{{with .Trigger}}{{.Outcome}}:{{.Result}}{{end}}`}
+	tpl := newGenericTemplate(in, &Environment{Trigger: &v1.ExecutionTrigger{Outcome: "Succeeded", Result: "42"}})
+	if err := tpl.execute(); err != nil {
+		t.Error(err)
+		return
+	}
+	if in.A != "Succeeded:42" {
+		t.Error("Mismatch in generated output", in.A)
+	}
+}
+
+func TestExecuteTemplateWithAnErrorReturnsThePath(t *testing.T) {
+	type inner struct {
+		B []string `json:"b,omitempty"`
+	}
+	type input struct {
+		A []inner `json:"a"`
+	}
+	tpl := newGenericTemplate(&input{[]inner{{}, {B: []string{"ok", "{{ .Payload"}}}}, &Environment{})
+	err := tpl.execute()
+	if err == nil {
+		t.Fatal("Expecting error, got nothing")
+	}
+	if !strings.HasPrefix(err.Error(), "a[1].b[1]: ") {
+		t.Errorf("Expecting the error to start with the path, got %q", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if err := Parse(`{{ .Payload | b64enc }}`); err != nil {
+		t.Error(err)
+	}
+	if err := Parse(`{{ .Payload | notAFunction }}`); err == nil {
+		t.Error("Expecting error for an unknown function, got nothing")
+	}
+	if err := Parse(`{{ .Payload `); err == nil {
+		t.Error("Expecting error for an unclosed action, got nothing")
+	}
+}
@@ -3,10 +3,10 @@ package template
 import (
 	batchv1 "k8s.io/api/batch/v1"
 
-	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
 )
 
-func BuildJob(jobTemplateSpec *batchv1.JobTemplateSpec, jobExecution *v1beta1.JobExecution) (*batchv1.JobTemplateSpec, error) {
+func BuildJob(jobTemplateSpec *batchv1.JobTemplateSpec, jobExecution *v1.JobExecution) (*batchv1.JobTemplateSpec, error) {
 	tpl := newGenericTemplate(jobTemplateSpec.DeepCopy(), newEnvironment(jobExecution))
 	err := tpl.execute()
 
@@ -11,6 +11,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
 	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
 )
 
@@ -40,8 +41,8 @@ var jobTemplateSpec = &batchv1.JobTemplateSpec{
 }
 
 func TestBuildJobWithAJSON(t *testing.T) {
-	je := &v1beta1.JobExecution{
-		Spec: v1beta1.JobExecutionSpec{
+	je := &v1.JobExecution{
+		Spec: v1.JobExecutionSpec{
 			JobTemplateName: "test",
 			Payload: `{
 "hello": "world"
@@ -66,8 +67,8 @@ func TestBuildJobWithAJSON(t *testing.T) {
 }
 
 func TestBuildJobWithoutAJSON(t *testing.T) {
-	je := &v1beta1.JobExecution{
-		Spec: v1beta1.JobExecutionSpec{
+	je := &v1.JobExecution{
+		Spec: v1.JobExecutionSpec{
 			JobTemplateName: "test",
 			Payload: `
 "hello": "world"
@@ -92,7 +93,7 @@ func TestBuildJobWithAnError(t *testing.T) {
 		},
 	}
 
-	if _, err := BuildJob(jt, &v1beta1.JobExecution{}); err == nil {
+	if _, err := BuildJob(jt, &v1.JobExecution{}); err == nil {
 		t.Error("Expecting error, got nothing")
 	}
 }
@@ -108,7 +109,7 @@ func TestExecuteTemplateWithAJobTemplate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	tpl := newGenericTemplate(&jt, &Environment{"Name", `{"date":"2022-12-12"}`})
+	tpl := newGenericTemplate(&jt, &Environment{Name: "Name", Payload: `{"date":"2022-12-12"}`})
 	if err := tpl.execute(); err != nil {
 		t.Error(err)
 	}
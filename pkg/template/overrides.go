@@ -0,0 +1,55 @@
+package template
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// ValidateOverrides returns an error if an execution's overrides set fields
+// that the JobTemplate doesn't declare as overridable. Overriding the image
+// also allows overriding its tag.
+func ValidateOverrides(fields []v1beta1.OverridableField, overrides *v1.ExecutionOverrides) error {
+	var denied []string
+	for _, field := range getOverriddenFields(overrides) {
+		if slices.Contains(fields, field) ||
+			field == v1beta1.OverridableFieldImageTag && slices.Contains(fields, v1beta1.OverridableFieldImage) {
+			continue
+		}
+		denied = append(denied, string(field))
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("fields not overridable by the JobTemplate: %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// Returns the fields that the overrides set.
+func getOverriddenFields(overrides *v1.ExecutionOverrides) []v1beta1.OverridableField {
+	if overrides == nil {
+		return nil
+	}
+	var fields []v1beta1.OverridableField
+	if len(overrides.Image) > 0 {
+		fields = append(fields, v1beta1.OverridableFieldImage)
+	}
+	if len(overrides.ImageTag) > 0 {
+		fields = append(fields, v1beta1.OverridableFieldImageTag)
+	}
+	if len(overrides.Env) > 0 {
+		fields = append(fields, v1beta1.OverridableFieldEnv)
+	}
+	if overrides.Resources != nil {
+		fields = append(fields, v1beta1.OverridableFieldResources)
+	}
+	if overrides.Args != nil {
+		fields = append(fields, v1beta1.OverridableFieldArgs)
+	}
+	if len(overrides.NodeSelector) > 0 {
+		fields = append(fields, v1beta1.OverridableFieldNodeSelector)
+	}
+	return fields
+}
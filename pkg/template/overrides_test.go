@@ -0,0 +1,46 @@
+package template
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func TestValidateOverrides(t *testing.T) {
+	overrides := &v1.ExecutionOverrides{
+		ImageTag: "sha-1234567",
+		Env:      []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+	}
+
+	fields := []v1beta1.OverridableField{v1beta1.OverridableFieldImageTag, v1beta1.OverridableFieldEnv}
+	if err := ValidateOverrides(fields, overrides); err != nil {
+		t.Errorf("Expecting no error, got %v", err)
+	}
+
+	fields = []v1beta1.OverridableField{v1beta1.OverridableFieldImage, v1beta1.OverridableFieldEnv}
+	if err := ValidateOverrides(fields, overrides); err != nil {
+		t.Errorf("Expecting the image to allow overriding its tag, got %v", err)
+	}
+
+	if err := ValidateOverrides(nil, nil); err != nil {
+		t.Errorf("Expecting no error without overrides, got %v", err)
+	}
+}
+
+func TestValidateOverridesWithFieldsNotOverridable(t *testing.T) {
+	overrides := &v1.ExecutionOverrides{
+		Image: "busybox:1.37",
+		Args:  []string{"--dry-run"},
+		Env:   []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+	}
+	fields := []v1beta1.OverridableField{v1beta1.OverridableFieldImageTag, v1beta1.OverridableFieldEnv}
+
+	err := ValidateOverrides(fields, overrides)
+	expected := "fields not overridable by the JobTemplate: image, args"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expecting error %q, got %v", expected, err)
+	}
+}
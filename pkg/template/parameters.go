@@ -0,0 +1,40 @@
+package template
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// GetParameters returns an execution's parameters, with the default of the
+// declared parameters it doesn't set. Returns an error if it doesn't set a
+// required parameter.
+func GetParameters(parameters []v1beta1.JobTemplateParameter, values []v1.ExecutionParameter) ([]v1.ExecutionParameter, error) {
+	result := slices.Clone(values)
+	for _, parameter := range parameters {
+		if slices.ContainsFunc(values, func(value v1.ExecutionParameter) bool {
+			return value.Name == parameter.Name
+		}) {
+			continue
+		}
+		if parameter.Required {
+			return nil, fmt.Errorf("missing required parameter %s", parameter.Name)
+		}
+		result = append(result, v1.ExecutionParameter{Name: parameter.Name, Value: parameter.Default})
+	}
+	return result, nil
+}
+
+// Returns the literal values of the parameters by their name.
+func getParameterValues(parameters []v1.ExecutionParameter) map[string]string {
+	if parameters == nil {
+		return nil
+	}
+	values := make(map[string]string, len(parameters))
+	for _, parameter := range parameters {
+		values[parameter.Name] = parameter.Value
+	}
+	return values
+}
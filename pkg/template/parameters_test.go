@@ -0,0 +1,48 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func TestGetParameters(t *testing.T) {
+	parameters := []v1beta1.JobTemplateParameter{
+		{Name: "region", Default: "us-east-1"},
+		{Name: "dryRun"},
+		{Name: "target", Required: true},
+	}
+	values := []v1.ExecutionParameter{
+		{Name: "target", Value: "db"},
+		{Name: "region", Value: "eu-west-1"},
+		{Name: "extra", Value: "1"},
+	}
+
+	result, err := GetParameters(parameters, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append(values, v1.ExecutionParameter{Name: "dryRun"})
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Mismatch in parameters, got %v expecting %v", result, expected)
+	}
+	if len(values) != 3 {
+		t.Error("Expected to not modify the values")
+	}
+}
+
+func TestGetParametersWithoutARequiredParameter(t *testing.T) {
+	parameters := []v1beta1.JobTemplateParameter{{Name: "target", Required: true}}
+	if _, err := GetParameters(parameters, nil); err == nil {
+		t.Error("Expecting error, got nothing")
+	}
+}
+
+func TestGetParametersWithoutParameters(t *testing.T) {
+	result, err := GetParameters(nil, nil)
+	if err != nil || result != nil {
+		t.Errorf("Expecting no parameters, got %v, %v", result, err)
+	}
+}
@@ -0,0 +1,85 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+// WorkflowEnvironment is the data available to the templates of a Workflow's
+// step.
+type WorkflowEnvironment struct {
+	Name    string
+	Payload string
+	// The steps the step depends on, by name.
+	Steps map[string]WorkflowStepOutput
+}
+
+type WorkflowStepOutput struct {
+	Outcome string
+	Result  string
+}
+
+// RenderWorkflowStep renders a template of a Workflow's step.
+func RenderWorkflowStep(text string, env *WorkflowEnvironment) (string, error) {
+	return render("step", text, env)
+}
+
+// ValidateWorkflowSteps returns an error if the steps of a Workflow don't form
+// a directed acyclic graph: their names must be unique, and they may only
+// depend on existing steps, without cycles.
+func ValidateWorkflowSteps(steps []v1beta1.WorkflowStep) error {
+	dependencies := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		if _, ok := dependencies[step.Name]; ok {
+			return fmt.Errorf("duplicated step %q", step.Name)
+		}
+		dependencies[step.Name] = step.DependsOn
+	}
+	for _, step := range steps {
+		for _, dependency := range step.DependsOn {
+			if _, ok := dependencies[dependency]; !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", step.Name, dependency)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(steps))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			i := 0
+			for path[i] != name {
+				i++
+			}
+			return fmt.Errorf("steps have a cycle: %s", strings.Join(append(path[i:], name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, step := range steps {
+		if err := visit(step.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
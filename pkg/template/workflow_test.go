@@ -0,0 +1,70 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func TestRenderWorkflowStep(t *testing.T) {
+	env := &WorkflowEnvironment{
+		Name:    "pipeline-abcde",
+		Payload: "input",
+		Steps: map[string]WorkflowStepOutput{
+			"extract": {Outcome: "Succeeded", Result: `{"rows":42}`},
+		},
+	}
+	tt := []struct {
+		text, expected string
+	}{
+		{`{{.Payload}}`, "input"},
+		{`{{(index .Steps "extract").Result | fromJson | dig "rows" 0}}`, "42"},
+		{`{{eq .Steps.extract.Outcome "Succeeded"}}`, "true"},
+	}
+	for _, tc := range tt {
+		rendered, err := RenderWorkflowStep(tc.text, env)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if rendered != tc.expected {
+			t.Errorf("Expected %q to render %q, got %q", tc.text, tc.expected, rendered)
+		}
+	}
+}
+
+func TestRenderWorkflowStepWithAnError(t *testing.T) {
+	if _, err := RenderWorkflowStep(`{{.Missing`, &WorkflowEnvironment{}); err == nil {
+		t.Error("Expected an error, got nothing")
+	}
+}
+
+func TestValidateWorkflowSteps(t *testing.T) {
+	step := func(name string, dependsOn ...string) v1beta1.WorkflowStep {
+		return v1beta1.WorkflowStep{Name: name, JobTemplateName: name, DependsOn: dependsOn}
+	}
+	if err := ValidateWorkflowSteps([]v1beta1.WorkflowStep{
+		step("load", "transform-a", "transform-b"),
+		step("extract"),
+		step("transform-a", "extract"),
+		step("transform-b", "extract"),
+	}); err != nil {
+		t.Error(err)
+	}
+
+	tt := []struct {
+		steps    []v1beta1.WorkflowStep
+		expected string
+	}{
+		{[]v1beta1.WorkflowStep{step("extract"), step("extract")}, `duplicated step "extract"`},
+		{[]v1beta1.WorkflowStep{step("load", "transform")}, `unknown step "transform"`},
+		{[]v1beta1.WorkflowStep{step("extract"), step("transform", "extract", "load"), step("load", "transform")}, "transform -> load -> transform"},
+		{[]v1beta1.WorkflowStep{step("extract", "extract")}, "extract -> extract"},
+	}
+	for _, tc := range tt {
+		if err := ValidateWorkflowSteps(tc.steps); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("Expected an error with %q, got %v", tc.expected, err)
+		}
+	}
+}
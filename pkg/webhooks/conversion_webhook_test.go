@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func TestConvertibleResources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{v1alpha1.AddToScheme, v1beta1.AddToScheme, v1.AddToScheme} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, obj := range []runtime.Object{&v1beta1.JobTemplate{}, &v1.JobExecution{}} {
+		ok, err := conversion.IsConvertible(scheme, obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Errorf("Expected %T to be convertible", obj)
+		}
+	}
+}
+
+func newConversionJobExecution() *v1.JobExecution {
+	return &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "report-abcde",
+			Namespace:   "default",
+			Annotations: map[string]string{"team": "data"},
+		},
+		Spec: v1.JobExecutionSpec{
+			JobTemplateName:    "report",
+			JobTemplateVersion: &v1.JobTemplateVersion{ResourceVersion: "42", Generation: 3},
+			Payload:            "{}",
+			Parameters: []v1.ExecutionParameter{
+				{Name: "format", Value: "csv"},
+				{Name: "token", ValueFrom: &v1.ExecutionParameterSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "report"},
+						Key:                  "token",
+					},
+				}},
+			},
+			Overrides: &v1.ExecutionOverrides{
+				Image:        "report:v2",
+				Env:          []corev1.EnvVar{{Name: "DEBUG", Value: "1"}},
+				NodeSelector: map[string]string{"pool": "batch"},
+			},
+			Requester:   &v1.ExecutionRequester{Username: "alice", Groups: []string{"data"}},
+			RetryPolicy: &v1.RetryPolicy{MaxAttempts: 3},
+		},
+		Status: v1.JobExecutionStatus{
+			Phase: v1.JobExecutionPhaseSucceeded,
+			Conditions: []metav1.Condition{
+				{Type: string(v1.JobExecutionSucceeded), Status: metav1.ConditionTrue, Reason: "Completed"},
+			},
+			Attempts:       1,
+			NotifiedEvents: []v1.NotificationEvent{v1.NotificationEventSucceeded},
+		},
+	}
+}
+
+func TestConvertJobExecutionThroughV1beta1(t *testing.T) {
+	je := newConversionJobExecution()
+
+	spoke := new(v1beta1.JobExecution)
+	if err := spoke.ConvertFrom(je.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"format": "csv"}; !reflect.DeepEqual(spoke.Spec.Parameters, expected) {
+		t.Errorf("Mismatch in v1beta1 parameters, got %v expecting %v", spoke.Spec.Parameters, expected)
+	}
+	if _, ok := spoke.Annotations[v1.JobExecutionConversionAnnotation]; !ok {
+		t.Errorf("Expected the conversion annotation, got %v", spoke.Annotations)
+	}
+
+	hub := new(v1.JobExecution)
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hub, je) {
+		t.Errorf("Mismatch after the round trip, got %+v expecting %+v", hub, je)
+	}
+}
+
+func TestConvertJobExecutionThroughV1alpha1(t *testing.T) {
+	je := newConversionJobExecution()
+
+	spoke := new(v1alpha1.JobExecution)
+	if err := spoke.ConvertFrom(je.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	hub := new(v1.JobExecution)
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hub, je) {
+		t.Errorf("Mismatch after the round trip, got %+v expecting %+v", hub, je)
+	}
+}
+
+func TestConvertV1beta1JobExecutionWithoutV1Fields(t *testing.T) {
+	je := &v1beta1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "report-abcde"},
+		Spec: v1beta1.JobExecutionSpec{
+			JobTemplateName: "report",
+			Parameters:      map[string]string{"target": "sales", "format": "csv"},
+		},
+		Status: v1beta1.JobExecutionStatus{
+			Conditions: []metav1.Condition{
+				{Type: string(v1beta1.JobExecutionRunning), Status: metav1.ConditionTrue},
+			},
+		},
+	}
+
+	hub := new(v1.JobExecution)
+	if err := je.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1.ExecutionParameter{{Name: "format", Value: "csv"}, {Name: "target", Value: "sales"}}
+	if !reflect.DeepEqual(hub.Spec.Parameters, expected) {
+		t.Errorf("Mismatch in parameters, got %v expecting %v", hub.Spec.Parameters, expected)
+	}
+	if hub.Status.Phase != v1.JobExecutionPhaseRunning {
+		t.Errorf("Expected the phase to be %q, got %q", v1.JobExecutionPhaseRunning, hub.Status.Phase)
+	}
+
+	spoke := new(v1beta1.JobExecution)
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(spoke, je) {
+		t.Errorf("Mismatch after the round trip, got %+v expecting %+v", spoke, je)
+	}
+}
+
+func TestGetJobExecutionPhase(t *testing.T) {
+	condition := func(conditionType v1.JobExecutionConditionType, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: string(conditionType), Status: status}
+	}
+	tt := []struct {
+		conditions []metav1.Condition
+		expected   v1.JobExecutionPhase
+	}{
+		{nil, v1.JobExecutionPhasePending},
+		{[]metav1.Condition{condition(v1.JobExecutionWaiting, metav1.ConditionTrue)}, v1.JobExecutionPhasePending},
+		{[]metav1.Condition{condition(v1.JobExecutionRunning, metav1.ConditionTrue)}, v1.JobExecutionPhaseRunning},
+		{[]metav1.Condition{condition(v1.JobExecutionSucceeded, metav1.ConditionTrue)}, v1.JobExecutionPhaseSucceeded},
+		{[]metav1.Condition{condition(v1.JobExecutionSucceeded, metav1.ConditionFalse)}, v1.JobExecutionPhaseFailed},
+		{[]metav1.Condition{
+			condition(v1.JobExecutionSucceeded, metav1.ConditionFalse),
+			condition(v1.JobExecutionCancelled, metav1.ConditionTrue),
+		}, v1.JobExecutionPhaseCancelled},
+		{[]metav1.Condition{
+			condition(v1.JobExecutionSucceeded, metav1.ConditionFalse),
+			condition(v1.JobExecutionTimedOut, metav1.ConditionTrue),
+		}, v1.JobExecutionPhaseTimedOut},
+	}
+	for _, tc := range tt {
+		if phase := v1.GetJobExecutionPhase(tc.conditions); phase != tc.expected {
+			t.Errorf("Expected phase %q for %v, got %q", tc.expected, tc.conditions, phase)
+		}
+	}
+}
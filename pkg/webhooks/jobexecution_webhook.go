@@ -0,0 +1,271 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+)
+
+// The length of the hash in the JobExecutionHashLabel.
+const jobExecutionHashLength = 32
+
+// Matches the characters that aren't allowed in a label's value.
+var invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+//+kubebuilder:webhook:path=/mutate-dispatcher-ivan-vc-v1-jobexecution,mutating=true,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=jobexecutions,verbs=create,versions=v1,name=mjobexecution-v1.dispatcher.ivan.vc,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-dispatcher-ivan-vc-v1beta1-jobexecution,mutating=true,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=jobexecutions,verbs=create,versions=v1beta1,name=mjobexecution-v1beta1.dispatcher.ivan.vc,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-dispatcher-ivan-vc-v1alpha1-jobexecution,mutating=true,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=jobexecutions,verbs=create,versions=v1alpha1,name=mjobexecution-v1alpha1.dispatcher.ivan.vc,admissionReviewVersions=v1
+
+// SetupJobExecutionWebhookWithManager registers the defaulting webhooks of the
+// JobExecutions.
+func SetupJobExecutionWebhookWithManager(mgr ctrl.Manager) error {
+	defaulter := &jobExecutionDefaulter{Reader: mgr.GetClient()}
+	if err := ctrl.NewWebhookManagedBy(mgr, &v1.JobExecution{}).
+		WithDefaulter(defaulter).
+		Complete(); err != nil {
+		return err
+	}
+	if err := ctrl.NewWebhookManagedBy(mgr, &v1beta1.JobExecution{}).
+		WithDefaulter(&v1beta1JobExecutionDefaulter{defaulter}).
+		Complete(); err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr, &v1alpha1.JobExecution{}).
+		WithDefaulter(&v1alpha1JobExecutionDefaulter{defaulter}).
+		Complete()
+}
+
+// Fills in the defaults of the JobExecutions when they're created, from their
+// JobTemplate and the user that requests them.
+type jobExecutionDefaulter struct {
+	client.Reader
+}
+
+func (d *jobExecutionDefaulter) Default(ctx context.Context, je *v1.JobExecution) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if len(je.Namespace) == 0 {
+		je.Namespace = req.Namespace
+	}
+
+	owner, spec, version, err := d.getJobTemplate(ctx, je)
+	if err != nil {
+		return err
+	}
+	if je.Spec.JobTemplateVersion == nil {
+		je.Spec.JobTemplateVersion = version
+	}
+
+	parameters, err := template.GetParameters(spec.Parameters, je.Spec.Parameters)
+	if err != nil {
+		return apierrors.NewInvalid(
+			v1.GroupVersion.WithKind("JobExecution").GroupKind(),
+			je.Name,
+			field.ErrorList{field.Invalid(field.NewPath("spec", "parameters"), je.Spec.Parameters, err.Error())},
+		)
+	}
+	je.Spec.Parameters = parameters
+
+	if err := template.ValidateOverrides(spec.OverridableFields, je.Spec.Overrides); err != nil {
+		return apierrors.NewInvalid(
+			v1.GroupVersion.WithKind("JobExecution").GroupKind(),
+			je.Name,
+			field.ErrorList{field.Forbidden(field.NewPath("spec", "overrides"), err.Error())},
+		)
+	}
+
+	setJobExecutionLabel(je, v1.JobTemplateNameLabel, je.Spec.JobTemplateName)
+	// The requester and hash come from the request, replace the ones set by the
+	// client.
+	delete(je.Annotations, v1.JobExecutionRequesterAnnotation)
+	delete(je.Labels, v1.JobExecutionRequesterLabel)
+	delete(je.Labels, v1.JobExecutionHashLabel)
+	if requester := req.UserInfo.Username; len(requester) > 0 {
+		if je.Annotations == nil {
+			je.Annotations = make(map[string]string)
+		}
+		je.Annotations[v1.JobExecutionRequesterAnnotation] = requester
+		setJobExecutionLabel(je, v1.JobExecutionRequesterLabel, getLabelValue(requester))
+	}
+	je.Spec.Requester = getJobExecutionRequester(req.UserInfo)
+	hash, err := getJobExecutionHash(je)
+	if err != nil {
+		return err
+	}
+	setJobExecutionLabel(je, v1.JobExecutionHashLabel, hash)
+
+	for _, ref := range je.OwnerReferences {
+		if ref.UID == owner.UID {
+			return nil
+		}
+	}
+	je.OwnerReferences = append(je.OwnerReferences, owner)
+	return nil
+}
+
+// Returns the reference to the JobExecution's JobTemplate or
+// ClusterJobTemplate, its spec, and its current version.
+func (d *jobExecutionDefaulter) getJobTemplate(ctx context.Context, je *v1.JobExecution) (metav1.OwnerReference, *v1beta1.JobTemplateSpec, *v1.JobTemplateVersion, error) {
+	var obj client.Object
+	var spec *v1beta1.JobTemplateSpec
+	key := types.NamespacedName{Name: je.Spec.JobTemplateName, Namespace: je.Namespace}
+	kind := v1.JobTemplateKind
+	if je.Spec.JobTemplateKind == v1.ClusterJobTemplateKind {
+		cjt := new(v1beta1.ClusterJobTemplate)
+		obj, spec, kind = cjt, &cjt.Spec.JobTemplateSpec, v1.ClusterJobTemplateKind
+		key.Namespace = ""
+	} else {
+		jt := new(v1beta1.JobTemplate)
+		obj, spec = jt, &jt.Spec
+	}
+	if err := d.Get(ctx, key, obj); err != nil {
+		return metav1.OwnerReference{}, nil, nil, err
+	}
+
+	owner := metav1.OwnerReference{
+		APIVersion: v1beta1.GroupVersion.String(),
+		Kind:       kind,
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}
+	version := &v1.JobTemplateVersion{
+		ResourceVersion: obj.GetResourceVersion(),
+		Generation:      obj.GetGeneration(),
+	}
+	return owner, spec, version, nil
+}
+
+// Defaults v1beta1 JobExecutions, once converted to v1.
+type v1beta1JobExecutionDefaulter struct {
+	*jobExecutionDefaulter
+}
+
+func (d *v1beta1JobExecutionDefaulter) Default(ctx context.Context, je *v1beta1.JobExecution) error {
+	dst := new(v1.JobExecution)
+	if err := je.ConvertTo(dst); err != nil {
+		return err
+	}
+	if err := d.jobExecutionDefaulter.Default(ctx, dst); err != nil {
+		return err
+	}
+	return je.ConvertFrom(dst)
+}
+
+// Defaults v1alpha1 JobExecutions, once converted to v1.
+type v1alpha1JobExecutionDefaulter struct {
+	*jobExecutionDefaulter
+}
+
+func (d *v1alpha1JobExecutionDefaulter) Default(ctx context.Context, je *v1alpha1.JobExecution) error {
+	dst := new(v1.JobExecution)
+	if err := je.ConvertTo(dst); err != nil {
+		return err
+	}
+	if err := d.jobExecutionDefaulter.Default(ctx, dst); err != nil {
+		return err
+	}
+	return je.ConvertFrom(dst)
+}
+
+// Sets the JobExecution's label, unless it's already set.
+func setJobExecutionLabel(je *v1.JobExecution, key, value string) {
+	if _, ok := je.Labels[key]; ok || len(validation.IsValidLabelValue(value)) > 0 {
+		return
+	}
+	if je.Labels == nil {
+		je.Labels = make(map[string]string)
+	}
+	je.Labels[key] = value
+}
+
+// Returns the value as a valid label value, replacing the characters that
+// aren't allowed, and truncating it.
+func getLabelValue(value string) string {
+	value = invalidLabelValueChars.ReplaceAllString(value, ".")
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(value, "_.-")
+}
+
+// Returns the user that requests the JobExecution, or nil if it's anonymous.
+func getJobExecutionRequester(userInfo authenticationv1.UserInfo) *v1.ExecutionRequester {
+	if len(userInfo.Username) == 0 {
+		return nil
+	}
+	return &v1.ExecutionRequester{
+		Username: userInfo.Username,
+		UID:      userInfo.UID,
+		Groups:   userInfo.Groups,
+	}
+}
+
+// Returns the hash of what the JobExecution executes: its JobTemplate, payload,
+// parameters, overrides and items.
+func getJobExecutionHash(je *v1.JobExecution) (string, error) {
+	kind := je.Spec.JobTemplateKind
+	if len(kind) == 0 {
+		kind = v1.JobTemplateKind
+	}
+	parameters := slices.SortedFunc(slices.Values(je.Spec.Parameters), func(a, b v1.ExecutionParameter) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	data, err := json.Marshal(struct {
+		JobTemplateKind string                  `json:"jobTemplateKind"`
+		JobTemplateName string                  `json:"jobTemplateName"`
+		Payload         string                  `json:"payload"`
+		Parameters      []v1.ExecutionParameter `json:"parameters"`
+		Overrides       *v1.ExecutionOverrides  `json:"overrides"`
+		Items           []string                `json:"items"`
+	}{
+		JobTemplateKind: kind,
+		JobTemplateName: je.Spec.JobTemplateName,
+		Payload:         je.Spec.Payload,
+		Parameters:      parameters,
+		Overrides:       je.Spec.Overrides,
+		Items:           je.Spec.Items,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:jobExecutionHashLength], nil
+}
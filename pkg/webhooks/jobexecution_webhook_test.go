@@ -0,0 +1,213 @@
+package webhooks
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newJobExecutionDefaulter(t *testing.T) *jobExecutionDefaulter {
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &jobExecutionDefaulter{
+		Reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&v1beta1.JobTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default", UID: "report-uid", Generation: 2},
+				Spec: v1beta1.JobTemplateSpec{
+					Parameters: []v1beta1.JobTemplateParameter{
+						{Name: "format", Default: "csv"},
+						{Name: "target", Required: true},
+					},
+					OverridableFields: []v1beta1.OverridableField{v1beta1.OverridableFieldImageTag},
+				},
+			},
+			&v1beta1.ClusterJobTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "backup", UID: "backup-uid"},
+			},
+		).Build(),
+	}
+}
+
+func newAdmissionContext(username string) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		},
+	})
+}
+
+func TestDefaultJobExecution(t *testing.T) {
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "report-"},
+		Spec: v1.JobExecutionSpec{
+			JobTemplateName: "report",
+			Parameters:      []v1.ExecutionParameter{{Name: "target", Value: "sales"}},
+			Requester:       &v1.ExecutionRequester{Username: "mallory"},
+		},
+	}
+	if err := newJobExecutionDefaulter(t).Default(newAdmissionContext("system:serviceaccount:ci:deployer"), je); err != nil {
+		t.Fatal(err)
+	}
+
+	if je.Namespace != "default" {
+		t.Errorf("Expected the request's namespace, got %q", je.Namespace)
+	}
+	if expected := []v1.ExecutionParameter{{Name: "target", Value: "sales"}, {Name: "format", Value: "csv"}}; !reflect.DeepEqual(je.Spec.Parameters, expected) {
+		t.Errorf("Mismatch in parameters, got %v expecting %v", je.Spec.Parameters, expected)
+	}
+	if je.Labels[v1.JobTemplateNameLabel] != "report" {
+		t.Errorf("Mismatch in the JobTemplate label, got %q", je.Labels[v1.JobTemplateNameLabel])
+	}
+	if je.Labels[v1.JobExecutionRequesterLabel] != "system.serviceaccount.ci.deployer" {
+		t.Errorf("Mismatch in the requester label, got %q", je.Labels[v1.JobExecutionRequesterLabel])
+	}
+	if je.Annotations[v1.JobExecutionRequesterAnnotation] != "system:serviceaccount:ci:deployer" {
+		t.Errorf("Mismatch in the requester annotation, got %q", je.Annotations[v1.JobExecutionRequesterAnnotation])
+	}
+	if expected := (&v1.ExecutionRequester{Username: "system:serviceaccount:ci:deployer"}); !reflect.DeepEqual(je.Spec.Requester, expected) {
+		t.Errorf("Mismatch in the requester, got %v expecting %v", je.Spec.Requester, expected)
+	}
+	if version := je.Spec.JobTemplateVersion; version == nil || len(version.ResourceVersion) == 0 || version.Generation != 2 {
+		t.Errorf("Mismatch in the JobTemplate version, got %v", version)
+	}
+	if len(je.Labels[v1.JobExecutionHashLabel]) != jobExecutionHashLength {
+		t.Errorf("Mismatch in the hash label, got %q", je.Labels[v1.JobExecutionHashLabel])
+	}
+	expectedOwner := metav1.OwnerReference{APIVersion: "dispatcher.ivan.vc/v1beta1", Kind: "JobTemplate", Name: "report", UID: "report-uid"}
+	if !reflect.DeepEqual(je.OwnerReferences, []metav1.OwnerReference{expectedOwner}) {
+		t.Errorf("Mismatch in owner references, got %v", je.OwnerReferences)
+	}
+}
+
+func TestDefaultJobExecutionReplacesTheRequester(t *testing.T) {
+	version := &v1.JobTemplateVersion{ResourceVersion: "1", Generation: 1}
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "backup-abcde",
+			Labels: map[string]string{
+				v1.JobTemplateNameLabel:       "backup",
+				v1.JobExecutionRequesterLabel: "alice",
+				v1.JobExecutionHashLabel:      "forged",
+			},
+			Annotations: map[string]string{v1.JobExecutionRequesterAnnotation: "alice"},
+		},
+		Spec: v1.JobExecutionSpec{
+			JobTemplateName:    "backup",
+			JobTemplateKind:    v1.ClusterJobTemplateKind,
+			JobTemplateVersion: version,
+		},
+	}
+	if err := newJobExecutionDefaulter(t).Default(newAdmissionContext("bob@example.com"), je); err != nil {
+		t.Fatal(err)
+	}
+	if je.Labels[v1.JobExecutionRequesterLabel] != "bob.example.com" {
+		t.Errorf("Expected to replace the requester label, got %q", je.Labels[v1.JobExecutionRequesterLabel])
+	}
+	if je.Annotations[v1.JobExecutionRequesterAnnotation] != "bob@example.com" {
+		t.Errorf("Expected to replace the requester annotation, got %q", je.Annotations[v1.JobExecutionRequesterAnnotation])
+	}
+	if hash := je.Labels[v1.JobExecutionHashLabel]; hash == "forged" || len(hash) != jobExecutionHashLength {
+		t.Errorf("Expected to replace the hash label, got %q", hash)
+	}
+	if je.Spec.JobTemplateVersion != version {
+		t.Errorf("Expected to keep the JobTemplate version, got %v", je.Spec.JobTemplateVersion)
+	}
+	if len(je.OwnerReferences) != 1 || je.OwnerReferences[0].Kind != v1.ClusterJobTemplateKind {
+		t.Errorf("Expected the ClusterJobTemplate as owner, got %v", je.OwnerReferences)
+	}
+}
+
+func TestDefaultJobExecutionRejectsInvalidExecutions(t *testing.T) {
+	defaulter := newJobExecutionDefaulter(t)
+	ctx := newAdmissionContext("alice")
+
+	je := &v1.JobExecution{Spec: v1.JobExecutionSpec{JobTemplateName: "report"}}
+	if err := defaulter.Default(ctx, je); !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "target") {
+		t.Errorf("Expecting an Invalid error for the missing parameter, got %v", err)
+	}
+
+	je = &v1.JobExecution{Spec: v1.JobExecutionSpec{
+		JobTemplateName: "report",
+		Parameters:      []v1.ExecutionParameter{{Name: "target", Value: "sales"}},
+		Overrides:       &v1.ExecutionOverrides{ImageTag: "v2", Args: []string{"--dry-run"}},
+	}}
+	if err := defaulter.Default(ctx, je); !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "args") {
+		t.Errorf("Expecting an Invalid error for the overridden args, got %v", err)
+	}
+
+	je = &v1.JobExecution{Spec: v1.JobExecutionSpec{JobTemplateName: "missing"}}
+	if err := defaulter.Default(ctx, je); !apierrors.IsNotFound(err) {
+		t.Errorf("Expecting a NotFound error, got %v", err)
+	}
+}
+
+func TestDefaultV1beta1JobExecution(t *testing.T) {
+	je := &v1beta1.JobExecution{
+		Spec: v1beta1.JobExecutionSpec{
+			JobTemplateName: "report",
+			Parameters:      map[string]string{"target": "sales"},
+		},
+	}
+	defaulter := &v1beta1JobExecutionDefaulter{newJobExecutionDefaulter(t)}
+	if err := defaulter.Default(newAdmissionContext("alice"), je); err != nil {
+		t.Fatal(err)
+	}
+	if je.Spec.Parameters["format"] != "csv" || je.Labels[v1.JobExecutionRequesterLabel] != "alice" {
+		t.Errorf("Expected the v1beta1 JobExecution to be defaulted, got %+v", je)
+	}
+	if !strings.Contains(je.Annotations[v1.JobExecutionConversionAnnotation], `"username":"alice"`) {
+		t.Errorf("Expected to keep the requester in the conversion annotation, got %v", je.Annotations)
+	}
+}
+
+func TestDefaultV1alpha1JobExecution(t *testing.T) {
+	je := &v1alpha1.JobExecution{
+		Spec: v1alpha1.JobExecutionSpec{
+			JobTemplateName: "report",
+			Parameters:      map[string]string{"target": "sales"},
+		},
+	}
+	defaulter := &v1alpha1JobExecutionDefaulter{newJobExecutionDefaulter(t)}
+	if err := defaulter.Default(newAdmissionContext("alice"), je); err != nil {
+		t.Fatal(err)
+	}
+	if je.Spec.Parameters["format"] != "csv" || je.Labels[v1.JobExecutionRequesterLabel] != "alice" {
+		t.Errorf("Expected the v1alpha1 JobExecution to be defaulted, got %+v", je)
+	}
+}
+
+func TestGetJobExecutionHash(t *testing.T) {
+	je := &v1.JobExecution{Spec: v1.JobExecutionSpec{JobTemplateName: "report", Payload: "a"}}
+	hash, _ := getJobExecutionHash(je)
+
+	je.Spec.JobTemplateKind = v1.JobTemplateKind
+	if other, _ := getJobExecutionHash(je); other != hash {
+		t.Error("Expected the default kind to have the same hash")
+	}
+	je.Spec.Payload = "b"
+	if other, _ := getJobExecutionHash(je); other == hash {
+		t.Error("Expected a different payload to have a different hash")
+	}
+}
+
+func TestGetLabelValue(t *testing.T) {
+	if value := getLabelValue("-" + strings.Repeat("a", 70)); value != strings.Repeat("a", 62) {
+		t.Errorf("Mismatch in the label value, got %q", value)
+	}
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks implements the admission webhooks of the dispatcher's
+// resources.
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+)
+
+//+kubebuilder:webhook:path=/validate-dispatcher-ivan-vc-v1beta1-jobtemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=jobtemplates,verbs=create;update,versions=v1beta1,name=vjobtemplate-v1beta1.dispatcher.ivan.vc,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-dispatcher-ivan-vc-v1alpha1-jobtemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=jobtemplates,verbs=create;update,versions=v1alpha1,name=vjobtemplate-v1alpha1.dispatcher.ivan.vc,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-dispatcher-ivan-vc-v1beta1-clusterjobtemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=clusterjobtemplates,verbs=create;update,versions=v1beta1,name=vclusterjobtemplate-v1beta1.dispatcher.ivan.vc,admissionReviewVersions=v1
+
+// SetupJobTemplateWebhookWithManager registers the validating webhooks of the
+// JobTemplates and ClusterJobTemplates.
+func SetupJobTemplateWebhookWithManager(mgr ctrl.Manager) error {
+	if err := ctrl.NewWebhookManagedBy(mgr, &v1beta1.JobTemplate{}).
+		WithValidator(&jobTemplateValidator{mgr.GetClient()}).
+		Complete(); err != nil {
+		return err
+	}
+	if err := ctrl.NewWebhookManagedBy(mgr, &v1alpha1.JobTemplate{}).
+		WithValidator(&v1alpha1JobTemplateValidator{mgr.GetClient()}).
+		Complete(); err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr, &v1beta1.ClusterJobTemplate{}).
+		WithValidator(&clusterJobTemplateValidator{mgr.GetClient()}).
+		Complete()
+}
+
+// Rejects JobTemplates whose templates don't parse, or don't render to a
+// valid Job, and the ones whose hooks trigger them back. It reads the
+// JobTemplates that the hooks trigger.
+type jobTemplateValidator struct {
+	client.Reader
+}
+
+func (v *jobTemplateValidator) ValidateCreate(ctx context.Context, jt *v1beta1.JobTemplate) (admission.Warnings, error) {
+	return nil, validateJobTemplate(ctx, v.Reader, v1beta1.GroupVersion.WithKind("JobTemplate").GroupKind(), jt.Namespace, jt.Name, &jt.Spec)
+}
+
+// Updates that don't change the spec are accepted, so the JobTemplates that
+// became invalid can still have their metadata updated, or be rewritten in the
+// storage version.
+func (v *jobTemplateValidator) ValidateUpdate(ctx context.Context, old, jt *v1beta1.JobTemplate) (admission.Warnings, error) {
+	if equality.Semantic.DeepEqual(old.Spec, jt.Spec) {
+		return nil, nil
+	}
+	return nil, validateJobTemplate(ctx, v.Reader, v1beta1.GroupVersion.WithKind("JobTemplate").GroupKind(), jt.Namespace, jt.Name, &jt.Spec)
+}
+
+func (v *jobTemplateValidator) ValidateDelete(context.Context, *v1beta1.JobTemplate) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// Validates v1alpha1 JobTemplates, once converted to v1beta1.
+type v1alpha1JobTemplateValidator struct {
+	client.Reader
+}
+
+func (v *v1alpha1JobTemplateValidator) ValidateCreate(ctx context.Context, jt *v1alpha1.JobTemplate) (admission.Warnings, error) {
+	return nil, v.validate(ctx, jt)
+}
+
+func (v *v1alpha1JobTemplateValidator) ValidateUpdate(ctx context.Context, old, jt *v1alpha1.JobTemplate) (admission.Warnings, error) {
+	if equality.Semantic.DeepEqual(old.Spec, jt.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, jt)
+}
+
+func (v *v1alpha1JobTemplateValidator) ValidateDelete(context.Context, *v1alpha1.JobTemplate) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *v1alpha1JobTemplateValidator) validate(ctx context.Context, jt *v1alpha1.JobTemplate) error {
+	dst := new(v1beta1.JobTemplate)
+	if err := jt.ConvertTo(dst); err != nil {
+		return err
+	}
+	return validateJobTemplate(ctx, v.Reader, v1alpha1.GroupVersion.WithKind("JobTemplate").GroupKind(), jt.Namespace, jt.Name, &dst.Spec)
+}
+
+// Rejects ClusterJobTemplates, like JobTemplates.
+type clusterJobTemplateValidator struct {
+	client.Reader
+}
+
+func (v *clusterJobTemplateValidator) ValidateCreate(ctx context.Context, cjt *v1beta1.ClusterJobTemplate) (admission.Warnings, error) {
+	return nil, validateJobTemplate(ctx, v.Reader, v1beta1.GroupVersion.WithKind("ClusterJobTemplate").GroupKind(), "", cjt.Name, &cjt.Spec.JobTemplateSpec)
+}
+
+func (v *clusterJobTemplateValidator) ValidateUpdate(ctx context.Context, old, cjt *v1beta1.ClusterJobTemplate) (admission.Warnings, error) {
+	if equality.Semantic.DeepEqual(old.Spec, cjt.Spec) {
+		return nil, nil
+	}
+	return nil, validateJobTemplate(ctx, v.Reader, v1beta1.GroupVersion.WithKind("ClusterJobTemplate").GroupKind(), "", cjt.Name, &cjt.Spec.JobTemplateSpec)
+}
+
+func (v *clusterJobTemplateValidator) ValidateDelete(context.Context, *v1beta1.ClusterJobTemplate) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// Returns an Invalid error if the JobTemplate's spec doesn't render to a
+// valid Job, or its hooks trigger it back. A JobTemplate with a base only has
+// its patch parsed, as its Job depends on the base.
+func validateJobTemplate(ctx context.Context, reader client.Reader, gk schema.GroupKind, namespace, name string, spec *v1beta1.JobTemplateSpec) error {
+	specPath := field.NewPath("spec")
+
+	var errs field.ErrorList
+	if spec.Base != nil {
+		if err := template.Parse(spec.Base.Patch); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("base", "patch"), spec.Base.Patch, err.Error()))
+		}
+	} else {
+		errs = validateJobTemplateSpec(specPath.Child("jobTemplate"), &spec.JobTemplateSpec, newSampleJobExecution(name, spec.Parameters))
+	}
+
+	if spec.Deduplication != nil {
+		if err := template.Parse(spec.Deduplication.Key); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("deduplication", "key"), spec.Deduplication.Key, err.Error()))
+		}
+	}
+
+	hookErrs, err := validateHooks(ctx, reader, gk, namespace, name, spec)
+	if err != nil {
+		return err
+	}
+	errs = append(errs, hookErrs...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(gk, name, errs)
+}
+
+// Returns the errors of the hooks that trigger the JobTemplate itself, or a
+// JobTemplate whose hooks trigger it back. The controller stops longer cycles
+// by limiting the depth of the follow-ups.
+func validateHooks(ctx context.Context, reader client.Reader, gk schema.GroupKind, namespace, name string, spec *v1beta1.JobTemplateSpec) (field.ErrorList, error) {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	for _, hooks := range []struct {
+		path  *field.Path
+		hooks []v1beta1.ExecutionHook
+	}{
+		{specPath.Child("onSuccess"), spec.OnSuccess},
+		{specPath.Child("onFailure"), spec.OnFailure},
+	} {
+		for i, hook := range hooks.hooks {
+			hookPath := hooks.path.Index(i).Child("jobTemplateName")
+			if hook.JobTemplateName == name {
+				errs = append(errs, field.Invalid(hookPath, hook.JobTemplateName, fmt.Sprintf("the %s can't trigger itself", gk.Kind)))
+				continue
+			}
+			triggered, err := getTriggeredJobTemplateSpec(ctx, reader, gk, namespace, hook.JobTemplateName)
+			if apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if triggersJobTemplate(triggered, name) {
+				errs = append(errs, field.Invalid(hookPath, hook.JobTemplateName, fmt.Sprintf("its hooks trigger this %s back", gk.Kind)))
+			}
+		}
+	}
+	return errs, nil
+}
+
+// Returns the spec of the JobTemplate triggered by a hook, which is a
+// ClusterJobTemplate for the hooks of a ClusterJobTemplate.
+func getTriggeredJobTemplateSpec(ctx context.Context, reader client.Reader, gk schema.GroupKind, namespace, name string) (*v1beta1.JobTemplateSpec, error) {
+	if gk.Kind == "ClusterJobTemplate" {
+		cjt := new(v1beta1.ClusterJobTemplate)
+		if err := reader.Get(ctx, client.ObjectKey{Name: name}, cjt); err != nil {
+			return nil, err
+		}
+		return &cjt.Spec.JobTemplateSpec, nil
+	}
+	jt := new(v1beta1.JobTemplate)
+	if err := reader.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, jt); err != nil {
+		return nil, err
+	}
+	return &jt.Spec, nil
+}
+
+// Returns true if any of the spec's hooks triggers the JobTemplate.
+func triggersJobTemplate(spec *v1beta1.JobTemplateSpec, name string) bool {
+	for _, hook := range append(slices.Clone(spec.OnSuccess), spec.OnFailure...) {
+		if hook.JobTemplateName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the errors of rendering the Job for the JobExecution.
+func validateJobTemplateSpec(path *field.Path, jobTemplateSpec *batchv1.JobTemplateSpec, jobExecution *v1.JobExecution) field.ErrorList {
+	job, err := template.BuildJob(jobTemplateSpec, jobExecution)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, "", "failed rendering: "+err.Error())}
+	}
+
+	var errs field.ErrorList
+	metadataPath := path.Child("metadata")
+	if len(job.Name) > 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(job.Name) {
+			errs = append(errs, field.Invalid(metadataPath.Child("name"), job.Name, msg))
+		}
+	}
+	errs = append(errs, metav1validation.ValidateLabels(job.Labels, metadataPath.Child("labels"))...)
+
+	podSpec := &job.Spec.Template.Spec
+	podSpecPath := path.Child("spec", "template", "spec")
+	errs = append(errs, metav1validation.ValidateLabels(job.Spec.Template.Labels, path.Child("spec", "template", "metadata", "labels"))...)
+	if podSpec.RestartPolicy != corev1.RestartPolicyNever && podSpec.RestartPolicy != corev1.RestartPolicyOnFailure {
+		errs = append(errs, field.NotSupported(podSpecPath.Child("restartPolicy"), podSpec.RestartPolicy, []string{
+			string(corev1.RestartPolicyNever),
+			string(corev1.RestartPolicyOnFailure),
+		}))
+	}
+	if len(podSpec.Containers) == 0 {
+		errs = append(errs, field.Required(podSpecPath.Child("containers"), ""))
+	}
+	errs = append(errs, validateContainers(podSpecPath.Child("initContainers"), podSpec.InitContainers)...)
+	errs = append(errs, validateContainers(podSpecPath.Child("containers"), podSpec.Containers)...)
+	return errs
+}
+
+// Returns the errors in the containers' names and images.
+func validateContainers(path *field.Path, containers []corev1.Container) field.ErrorList {
+	var errs field.ErrorList
+	for i, container := range containers {
+		containerPath := path.Index(i)
+		for _, msg := range validation.IsDNS1123Label(container.Name) {
+			errs = append(errs, field.Invalid(containerPath.Child("name"), container.Name, msg))
+		}
+		if len(container.Image) == 0 {
+			errs = append(errs, field.Required(containerPath.Child("image"), ""))
+		}
+	}
+	return errs
+}
+
+// Returns the JobExecution used for the trial render of a JobTemplate, with
+// the default of its parameters. It's a follow-up execution, so templates
+// using the trigger render too.
+func newSampleJobExecution(jobTemplateName string, parameters []v1beta1.JobTemplateParameter) *v1.JobExecution {
+	je := &v1.JobExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: jobTemplateName + "-sample"},
+		Spec: v1.JobExecutionSpec{
+			JobTemplateName: jobTemplateName,
+			Trigger: &v1.ExecutionTrigger{
+				JobExecutionName: jobTemplateName + "-trigger",
+				JobTemplateName:  jobTemplateName,
+				Outcome:          "Succeeded",
+			},
+		},
+	}
+	for _, parameter := range parameters {
+		je.Spec.Parameters = append(je.Spec.Parameters, v1.ExecutionParameter{Name: parameter.Name, Value: parameter.Default})
+	}
+	return je
+}
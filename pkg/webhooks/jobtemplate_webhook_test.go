@@ -0,0 +1,221 @@
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1alpha1"
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func newJobTemplate(container corev1.Container) *v1beta1.JobTemplate {
+	return &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1beta1.JobTemplateSpec{
+			JobTemplateSpec: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyNever,
+							Containers:    []corev1.Container{container},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateJobTemplate(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{
+		Name:    "main",
+		Image:   "alpine:{{ .Payload | default \"latest\" }}",
+		Command: []string{"echo", "{{ .Name }}", "{{ with .Trigger }}{{ .Outcome }}{{ end }}"},
+	})
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateJobTemplateWithABrokenTemplate(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{
+		Name:    "main",
+		Image:   "alpine",
+		Command: []string{"echo", "{{ .Payload "},
+	})
+	old := newJobTemplate(corev1.Container{Name: "main", Image: "alpine"})
+	_, err := (&jobTemplateValidator{}).ValidateUpdate(context.Background(), old, jt)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expecting an Invalid error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "spec.template.spec.containers[0].command[1]") {
+		t.Errorf("Expecting the error to have the template's path, got %q", err)
+	}
+}
+
+func TestValidateJobTemplateWithAnUnchangedSpec(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{
+		Name:    "main",
+		Image:   "alpine",
+		Command: []string{"echo", "{{ .Payload "},
+	})
+	updated := jt.DeepCopy()
+	updated.Labels = map[string]string{"team": "data"}
+	if _, err := (&jobTemplateValidator{}).ValidateUpdate(context.Background(), jt, updated); err != nil {
+		t.Errorf("Expecting the update without spec changes to be accepted, got %v", err)
+	}
+}
+
+func TestValidateJobTemplateWithAFailingRender(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{
+		Name:  "main",
+		Image: `{{ fail "no image" }}`,
+	})
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); !apierrors.IsInvalid(err) {
+		t.Errorf("Expecting an Invalid error, got %v", err)
+	}
+}
+
+func TestValidateJobTemplateWithAnInvalidJob(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{
+		Name: "{{ .Name }}_main",
+	})
+	jt.Spec.JobTemplateSpec.Spec.Template.Spec.RestartPolicy = ""
+	_, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expecting an Invalid error, got %v", err)
+	}
+
+	causes := err.(*apierrors.StatusError).ErrStatus.Details.Causes
+	var fields []string
+	for _, cause := range causes {
+		fields = append(fields, cause.Field)
+	}
+	expected := []string{
+		"spec.jobTemplate.spec.template.spec.restartPolicy",
+		"spec.jobTemplate.spec.template.spec.containers[0].name",
+		"spec.jobTemplate.spec.template.spec.containers[0].image",
+	}
+	if strings.Join(fields, ",") != strings.Join(expected, ",") {
+		t.Errorf("Mismatch in the invalid fields, got %v expecting %v", fields, expected)
+	}
+}
+
+func TestValidateJobTemplateWithABase(t *testing.T) {
+	jt := &v1beta1.JobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1beta1.JobTemplateSpec{
+			Base: &v1beta1.JobTemplateBase{Name: "base", Patch: "metadata:\n  name: '{{ .Name }}'"},
+		},
+	}
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); err != nil {
+		t.Error(err)
+	}
+
+	jt.Spec.Base.Patch = "metadata:\n  name: '{{ .Name '"
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); !apierrors.IsInvalid(err) {
+		t.Errorf("Expecting an Invalid error, got %v", err)
+	}
+}
+
+func TestValidateV1alpha1JobTemplate(t *testing.T) {
+	src := newJobTemplate(corev1.Container{Name: "main", Image: "{{ .Payload | notAFunction }}"})
+	jt := new(v1alpha1.JobTemplate)
+	if err := jt.ConvertFrom(src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&v1alpha1JobTemplateValidator{}).ValidateCreate(context.Background(), jt); !apierrors.IsInvalid(err) {
+		t.Errorf("Expecting an Invalid error, got %v", err)
+	}
+}
+
+func TestValidateClusterJobTemplate(t *testing.T) {
+	cjt := &v1beta1.ClusterJobTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       v1beta1.ClusterJobTemplateSpec{JobTemplateSpec: newJobTemplate(corev1.Container{Name: "main"}).Spec},
+	}
+	if _, err := (&clusterJobTemplateValidator{}).ValidateCreate(context.Background(), cjt); !apierrors.IsInvalid(err) {
+		t.Errorf("Expecting an Invalid error, got %v", err)
+	}
+}
+
+func TestValidateJobTemplateWithParameters(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{Name: "main", Image: `alpine:{{ if not .Parameters.tag }}{{ fail "missing tag" }}{{ end }}{{ .Parameters.tag }}`})
+	jt.Spec.Parameters = []v1beta1.JobTemplateParameter{{Name: "tag", Default: "latest"}}
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); err != nil {
+		t.Error(err)
+	}
+
+	jt.Spec.Parameters[0].Default = ""
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); !apierrors.IsInvalid(err) {
+		t.Errorf("Expecting an Invalid error, got %v", err)
+	}
+}
+
+func TestValidateJobTemplateWithADeduplicationKey(t *testing.T) {
+	jt := newJobTemplate(corev1.Container{Name: "main", Image: "alpine"})
+	jt.Spec.Deduplication = &v1beta1.Deduplication{Key: "{{ (fromJson .Payload).cache }}"}
+	if _, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt); err != nil {
+		t.Error(err)
+	}
+
+	jt.Spec.Deduplication.Key = "{{ .Payload "
+	_, err := (&jobTemplateValidator{}).ValidateCreate(context.Background(), jt)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.deduplication.key") {
+		t.Errorf("Expecting an Invalid error for the key, got %v", err)
+	}
+}
+
+func newJobTemplateReader(t *testing.T, objs ...client.Object) client.Reader {
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func TestValidateJobTemplateWithHooks(t *testing.T) {
+	cleanup := newJobTemplate(corev1.Container{Name: "main", Image: "alpine"})
+	cleanup.Name = "cleanup"
+	v := &jobTemplateValidator{newJobTemplateReader(t, cleanup)}
+
+	jt := newJobTemplate(corev1.Container{Name: "main", Image: "alpine"})
+	jt.Spec.OnSuccess = []v1beta1.ExecutionHook{{JobTemplateName: "cleanup"}, {JobTemplateName: "missing"}}
+	if _, err := v.ValidateCreate(context.Background(), jt); err != nil {
+		t.Error(err)
+	}
+
+	jt.Spec.OnFailure = []v1beta1.ExecutionHook{{JobTemplateName: "test"}}
+	_, err := v.ValidateCreate(context.Background(), jt)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.onFailure[0].jobTemplateName") {
+		t.Errorf("Expecting an Invalid error for the self-reference, got %v", err)
+	}
+}
+
+func TestValidateJobTemplateWithAHookCycle(t *testing.T) {
+	cleanup := newJobTemplate(corev1.Container{Name: "main", Image: "alpine"})
+	cleanup.Name = "cleanup"
+	cleanup.Spec.OnFailure = []v1beta1.ExecutionHook{{JobTemplateName: "test"}}
+	v := &jobTemplateValidator{newJobTemplateReader(t, cleanup)}
+
+	jt := newJobTemplate(corev1.Container{Name: "main", Image: "alpine"})
+	jt.Spec.OnSuccess = []v1beta1.ExecutionHook{{JobTemplateName: "cleanup"}}
+	_, err := v.ValidateUpdate(context.Background(), newJobTemplate(corev1.Container{Name: "main", Image: "alpine"}), jt)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.onSuccess[0].jobTemplateName") {
+		t.Errorf("Expecting an Invalid error for the cycle, got %v", err)
+	}
+
+	cjt := &v1beta1.ClusterJobTemplate{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: v1beta1.ClusterJobTemplateSpec{JobTemplateSpec: jt.Spec}}
+	if _, err := (&clusterJobTemplateValidator{newJobTemplateReader(t, cleanup)}).ValidateCreate(context.Background(), cjt); err != nil {
+		t.Errorf("Expecting the JobTemplate not to cycle with a ClusterJobTemplate, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022 Ivan Valdes.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+	"github.com/ivanvc/dispatcher/pkg/template"
+)
+
+//+kubebuilder:webhook:path=/validate-dispatcher-ivan-vc-v1beta1-workflow,mutating=false,failurePolicy=fail,sideEffects=None,groups=dispatcher.ivan.vc,resources=workflows,verbs=create;update,versions=v1beta1,name=vworkflow-v1beta1.dispatcher.ivan.vc,admissionReviewVersions=v1
+
+// SetupWorkflowWebhookWithManager registers the validating webhook of the
+// Workflows.
+func SetupWorkflowWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &v1beta1.Workflow{}).
+		WithValidator(&workflowValidator{}).
+		Complete()
+}
+
+// Rejects Workflows whose steps don't form a directed acyclic graph.
+type workflowValidator struct{}
+
+func (v *workflowValidator) ValidateCreate(_ context.Context, wf *v1beta1.Workflow) (admission.Warnings, error) {
+	return nil, validateWorkflow(wf)
+}
+
+func (v *workflowValidator) ValidateUpdate(_ context.Context, _, wf *v1beta1.Workflow) (admission.Warnings, error) {
+	return nil, validateWorkflow(wf)
+}
+
+func (v *workflowValidator) ValidateDelete(context.Context, *v1beta1.Workflow) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// Returns an Invalid error if the Workflow's steps have duplicated names,
+// unknown dependencies or cycles.
+func validateWorkflow(wf *v1beta1.Workflow) error {
+	if err := template.ValidateWorkflowSteps(wf.Spec.Steps); err != nil {
+		return apierrors.NewInvalid(v1beta1.GroupVersion.WithKind("Workflow").GroupKind(), wf.Name, field.ErrorList{
+			field.Invalid(field.NewPath("spec", "steps"), len(wf.Spec.Steps), err.Error()),
+		})
+	}
+	return nil
+}
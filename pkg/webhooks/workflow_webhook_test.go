@@ -0,0 +1,39 @@
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ivanvc/dispatcher/pkg/api/v1beta1"
+)
+
+func TestValidateWorkflow(t *testing.T) {
+	wf := &v1beta1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "etl", Namespace: "default"},
+		Spec: v1beta1.WorkflowSpec{
+			Steps: []v1beta1.WorkflowStep{
+				{Name: "extract", JobTemplateName: "extract"},
+				{Name: "load", JobTemplateName: "load", DependsOn: []string{"extract"}},
+			},
+		},
+	}
+	if _, err := (&workflowValidator{}).ValidateCreate(context.Background(), wf); err != nil {
+		t.Error(err)
+	}
+
+	wf.Spec.Steps[0].DependsOn = []string{"load"}
+	_, err := (&workflowValidator{}).ValidateUpdate(context.Background(), wf, wf)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "extract -> load -> extract") {
+		t.Errorf("Expecting an Invalid error for the cycle, got %v", err)
+	}
+
+	wf.Spec.Steps[0].DependsOn = []string{"transform"}
+	_, err = (&workflowValidator{}).ValidateCreate(context.Background(), wf)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), `unknown step "transform"`) {
+		t.Errorf("Expecting an Invalid error for the unknown step, got %v", err)
+	}
+}